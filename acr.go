@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	azureManagementScope = "https://management.azure.com/.default"
+	acrRefreshTokenUser  = "00000000-0000-0000-0000-000000000000"
+)
+
+// azureLoginEndpoint is the Azure AD endpoint service principals get tokens from
+var azureLoginEndpoint = "https://login.microsoftonline.com"
+
+// azureIdentityEndpoint is the endpoint of the Azure instance metadata service managed identities get tokens from
+var azureIdentityEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+// acrCredentials exchanges an Azure AD token of a service principal or managed identity for a refresh token of an Azure Container Registry,
+// false when the registry is not ACR or there is no Azure identity
+func acrCredentials(registry string) (registryCredentials, bool) {
+	if !strings.HasSuffix(registry, ".azurecr.io") {
+		return registryCredentials{}, false
+	}
+	tenant := os.Getenv("AZURE_TENANT_ID")
+	aadToken, err := azureADToken(tenant, os.Getenv("AZURE_CLIENT_ID"), os.Getenv("AZURE_CLIENT_SECRET"))
+	if err != nil {
+		logger.Warnf("Could not get an Azure AD token for %s, falling back to the docker credentials: %v", registry, err)
+		return registryCredentials{}, false
+	}
+	refreshToken, err := exchangeACRToken("https://"+registry, registry, tenant, aadToken)
+	if err != nil {
+		logger.Fatalf("Could not exchange the Azure AD token for a token of %s: %v", registry, err)
+	}
+	return registryCredentials{user: acrRefreshTokenUser, password: refreshToken}, true
+}
+
+// azureADToken gets an Azure AD access token of the service principal when its secret is given, else of the managed identity
+func azureADToken(tenant string, clientID string, clientSecret string) (string, error) {
+	client := &http.Client{Transport: newTransport()}
+	if clientSecret != "" {
+		if tenant == "" || clientID == "" {
+			return "", fmt.Errorf("a service principal requires AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET")
+		}
+		response, err := client.PostForm(azureLoginEndpoint+"/"+url.PathEscape(tenant)+"/oauth2/v2.0/token", url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {clientID},
+			"client_secret": {clientSecret},
+			"scope":         {azureManagementScope},
+		})
+		if err != nil {
+			return "", err
+		}
+		return decodeAccessToken(response)
+	}
+
+	query := url.Values{"api-version": {"2018-02-01"}, "resource": {"https://management.azure.com/"}}
+	if clientID != "" {
+		query.Set("client_id", clientID) // a user assigned managed identity
+	}
+	request, err := http.NewRequest("GET", azureIdentityEndpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Metadata", "true")
+	response, err := (&http.Client{Timeout: 2 * time.Second}).Do(request)
+	if err != nil {
+		return "", fmt.Errorf("no service principal given and the managed identity endpoint is not reachable: %v", err)
+	}
+	return decodeAccessToken(response)
+}
+
+// exchangeACRToken exchanges an Azure AD access token for a refresh token of the registry, as 'az acr login' does
+func exchangeACRToken(registryURL string, registry string, tenant string, aadToken string) (string, error) {
+	form := url.Values{"grant_type": {"access_token"}, "service": {registry}, "access_token": {aadToken}}
+	if tenant != "" {
+		form.Set("tenant", tenant)
+	}
+	response, err := (&http.Client{Transport: newTransport()}).PostForm(registryURL+"/oauth2/exchange", form)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(response.Body)
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry responded %d with message %s", response.StatusCode, string(body))
+	}
+	var token struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err = json.Unmarshal(body, &token); err != nil || token.RefreshToken == "" {
+		return "", fmt.Errorf("registry returned no refresh token: %s", string(body))
+	}
+	return token.RefreshToken, nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestACRTokenExchange(t *testing.T) {
+	initializeLogger("")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch {
+		case r.URL.Path == "/tenant/oauth2/v2.0/token" && r.Form.Get("client_secret") == "secret":
+			w.Write([]byte(`{"access_token": "aad"}`))
+		case r.URL.Path == "/oauth2/exchange" && r.Form.Get("access_token") == "aad" && r.Form.Get("service") == "myregistry.azurecr.io":
+			w.Write([]byte(`{"refresh_token": "acr"}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+	azureLoginEndpoint = server.URL
+
+	aadToken, err := azureADToken("tenant", "client", "secret")
+	if err != nil || aadToken != "aad" {
+		t.Fatalf("Expected the Azure AD token of the service principal, got %s %v", aadToken, err)
+	}
+	if refreshToken, err := exchangeACRToken(server.URL, "myregistry.azurecr.io", "tenant", aadToken); err != nil || refreshToken != "acr" {
+		t.Errorf("Expected the refresh token of the registry, got %s %v", refreshToken, err)
+	}
+}
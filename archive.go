@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const layerFileName = "layer.tar"
+
+// archivedLayers maps the paths of layer files that are not extracted to their position in the saved image archive
+var archivedLayers = make(map[string]archivedFile)
+
+type archivedFile struct {
+	archive string
+	offset  int64
+	size    int64
+}
+
+// layerFile is an opened layer file, extracted or a section of the image archive
+type layerFile struct {
+	*io.SectionReader
+	file *os.File
+}
+
+// Close closes the underlying file
+func (layer *layerFile) Close() error {
+	return layer.file.Close()
+}
+
+// openLayerFile opens the layer file in the temporary folder, or its section of the saved image archive
+func openLayerFile(path string) (*layerFile, error) {
+	if archived, exists := archivedLayers[filepath.Clean(path)]; exists {
+		file, err := os.Open(archived.archive)
+		if err != nil {
+			return nil, err
+		}
+		return &layerFile{io.NewSectionReader(file, archived.offset, archived.size), file}, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &layerFile{io.NewSectionReader(file, 0, info.Size()), file}, nil
+}
+
+// readImageFile reads a file of the saved image, extracted or archived
+func readImageFile(path string) ([]byte, error) {
+	file, err := openLayerFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ioutil.ReadAll(file)
+}
+
+// linkLayerFile makes the layer file at the path refer to the linked layer file, whether that is extracted, archived or only hashed
+func linkLayerFile(path string, linked string) error {
+	if archived, exists := archivedLayers[linked]; exists {
+		archivedLayers[path] = archived
+	}
+	if digest, exists := layerDigests[linked]; exists {
+		layerDigests[path] = digest
+	}
+	if _, err := os.Stat(linked); err == nil {
+		return os.Symlink(linked, path)
+	}
+	return nil
+}
+
+// countingReader counts the bytes read, to know the offset of the files in a tar
+type countingReader struct {
+	reader io.Reader
+	offset int64
+}
+
+func (counter *countingReader) Read(p []byte) (int, error) {
+	n, err := counter.reader.Read(p)
+	counter.offset += int64(n)
+	return n, err
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestUntarServesLayersFromArchive(t *testing.T) {
+	initializeLogger("")
+	image := imageTar(t)
+	tmpPath := createTmpPath("clair-archive")
+	defer os.RemoveAll(tmpPath)
+	if err := untar(ioutil.NopCloser(image), tmpPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(tmpPath + "/manifest.json"); err != nil {
+		t.Errorf("Expected manifest.json to be extracted, got %v", err)
+	}
+	if _, err := os.Stat(tmpPath + "/a/layer.tar"); !os.IsNotExist(err) {
+		t.Errorf("Expected the layer not to be extracted, got %v", err)
+	}
+	for _, layerID := range []string{"a", "b"} {
+		layer, err := openLayerFile(tmpPath + "/" + layerID + "/layer.tar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := ioutil.ReadAll(layer)
+		layer.Close()
+		if string(content) != "layer content" {
+			t.Errorf("Expected layer %s to be read from the archive, got %q", layerID, content)
+		}
+	}
+}
+
+func TestUntarStreamingSkipsKnownLayers(t *testing.T) {
+	initializeLogger("")
+	tmpPath := createTmpPath("clair-stream")
+	defer os.RemoveAll(tmpPath)
+	known := sha256.Sum256([]byte("layer content"))
+	if err := untarStreaming(imageTar(t), tmpPath, map[string]bool{"sha256:" + hex.EncodeToString(known[:]): true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(tmpPath + "/a/layer.tar"); !os.IsNotExist(err) {
+		t.Errorf("Expected the known layer not to be saved, got %v", err)
+	}
+	if layerDigest(tmpPath, "b") != "sha256:"+hex.EncodeToString(known[:]) {
+		t.Errorf("Expected the digest of the linked layer, got %s", layerDigest(tmpPath, "b"))
+	}
+}
+
+// imageTar returns a saved image with the layer a and the layer b linking to it
+func imageTar(t *testing.T) *bytes.Buffer {
+	var image bytes.Buffer
+	writer := tar.NewWriter(&image)
+	for _, file := range []struct {
+		header  tar.Header
+		content string
+	}{
+		{tar.Header{Name: "manifest.json", Typeflag: tar.TypeReg, Mode: 0644}, `[]`},
+		{tar.Header{Name: "a/", Typeflag: tar.TypeDir, Mode: 0755}, ""},
+		{tar.Header{Name: "a/layer.tar", Typeflag: tar.TypeReg, Mode: 0644}, "layer content"},
+		{tar.Header{Name: "b/", Typeflag: tar.TypeDir, Mode: 0755}, ""},
+		{tar.Header{Name: "b/layer.tar", Typeflag: tar.TypeSymlink, Linkname: "../a/layer.tar", Mode: 0644}, ""},
+	} {
+		file.header.Size = int64(len(file.content))
+		if err := writer.WriteHeader(&file.header); err != nil {
+			t.Fatal(err)
+		}
+		writer.Write([]byte(file.content))
+	}
+	writer.Close()
+	return &image
+}
@@ -0,0 +1,31 @@
+package main
+
+// markBaseImageVulnerabilities analyzes the base image layers and flags the vulnerabilities that are already present in the base image
+func markBaseImageVulnerabilities(config scannerConfig, tmpPath string, baseLayerIds []string, vulnerabilities []vulnerabilityInfo) {
+	logger.Infof("Analyzing base image [%s]", config.baseImage)
+	analyzeLayers(config, tmpPath, baseLayerIds)
+
+	config.exitWhenNoFeatures = false
+	config.ndjson = nil
+	_, baseVulnerabilities := getVulnerabilities(config, tmpPath, baseLayerIds)
+	inBaseImage := make(map[string]bool, len(baseVulnerabilities))
+	for _, vulnerability := range baseVulnerabilities {
+		inBaseImage[baseImageKey(vulnerability)] = true
+	}
+
+	for i := range vulnerabilities {
+		vulnerabilities[i].InBaseImage = inBaseImage[baseImageKey(vulnerabilities[i])]
+	}
+}
+
+// baseImageKey identifies a vulnerability of a specific package version, so an upgraded package is not seen as inherited
+func baseImageKey(vulnerability vulnerabilityInfo) string {
+	return vulnerability.Vulnerability + " " + vulnerability.FeatureName + " " + vulnerability.FeatureVersion
+}
+
+// Validate that the given way to handle base image vulnerabilities is supported
+func validateBaseImageMode(mode string) {
+	if mode != "group" && mode != "ignore" {
+		logger.Fatalf("Invalid base image vulnerabilities mode %s given", mode)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestBaseImageKey(t *testing.T) {
+	zlib := vulnerabilityInfo{Vulnerability: "CVE-2016-9840", FeatureName: "zlib", FeatureVersion: "1.2.8"}
+	if baseImageKey(zlib) != baseImageKey(vulnerabilityInfo{Vulnerability: "CVE-2016-9840", FeatureName: "zlib", FeatureVersion: "1.2.8", Severity: "High", AddedBy: "top"}) {
+		t.Error("Expected the same vulnerability of the same package version to have the same key, whatever layer added it")
+	}
+	for _, other := range []vulnerabilityInfo{
+		{Vulnerability: "CVE-2016-9840", FeatureName: "zlib", FeatureVersion: "1.2.11"},
+		{Vulnerability: "CVE-2016-9840", FeatureName: "zlib-dev", FeatureVersion: "1.2.8"},
+		{Vulnerability: "CVE-2016-9841", FeatureName: "zlib", FeatureVersion: "1.2.8"},
+	} {
+		if baseImageKey(zlib) == baseImageKey(other) {
+			t.Errorf("Expected %+v to have another key than %+v", other, zlib)
+		}
+	}
+}
+
+func TestMarkBaseImageVulnerabilities(t *testing.T) {
+	initializeLogger("")
+	tmpPath := createLayerFiles(t, map[string]string{"base": "base content"})
+	defer os.RemoveAll(tmpPath)
+	baseLayerName := clairLayerNames(tmpPath, []string{"base"})[0]
+
+	clair := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/v1/layers/"+baseLayerName {
+			t.Errorf("Expected only the base layer to be requested, got %s %s", r.Method, r.URL.Path)
+		}
+		io.WriteString(w, `{"Layer": {"Name": "`+baseLayerName+`", "NamespaceName": "alpine:v3.5", "Features": [
+			{"Name": "zlib", "Version": "1.2.8", "AddedBy": "`+baseLayerName+`", "Vulnerabilities": [{"Name": "CVE-2016-9840", "Severity": "High"}]},
+			{"Name": "openssl", "Version": "1.0.2k-r0", "AddedBy": "`+baseLayerName+`", "Vulnerabilities": [{"Name": "CVE-2017-3735", "Severity": "Medium"}]}
+		]}}`)
+	}))
+	defer clair.Close()
+
+	vulnerabilities := []vulnerabilityInfo{
+		{Vulnerability: "CVE-2016-9840", FeatureName: "zlib", FeatureVersion: "1.2.8", Severity: "High"},
+		{Vulnerability: "CVE-2017-3735", FeatureName: "openssl", FeatureVersion: "1.0.2m-r0", Severity: "Medium"},
+		{Vulnerability: "CVE-2017-15650", FeatureName: "musl", FeatureVersion: "1.1.15", Severity: "High"},
+	}
+	config := scannerConfig{clairURL: clair.URL, clairAPI: "v1", baseImage: "alpine:3.5", imageName: "myapp:1.0", exitWhenNoFeatures: true}
+	markBaseImageVulnerabilities(config, tmpPath, []string{"base"}, vulnerabilities)
+
+	if !vulnerabilities[0].InBaseImage {
+		t.Errorf("Expected the vulnerability of the package of the base image to be inherited, got %+v", vulnerabilities[0])
+	}
+	if vulnerabilities[1].InBaseImage {
+		t.Errorf("Expected the vulnerability of an upgraded package not to be inherited, got %+v", vulnerabilities[1])
+	}
+	if vulnerabilities[2].InBaseImage {
+		t.Errorf("Expected a vulnerability the base image doesn't have not to be inherited, got %+v", vulnerabilities[2])
+	}
+
+	config.whitelistThreshold = "Unknown"
+	if unapproved := checkForUnapprovedVulnerabilities(config, vulnerabilities); len(unapproved) != 2 || contains(unapproved, "CVE-2016-9840") {
+		t.Errorf("Expected only the vulnerabilities the image added to be unapproved, got %v", unapproved)
+	}
+}
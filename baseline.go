@@ -0,0 +1,30 @@
+package main
+
+// markBaselineVulnerabilities flags the vulnerabilities that are already present in the baseline report
+func markBaselineVulnerabilities(baselineFile string, vulnerabilities []vulnerabilityInfo) {
+	inBaseline := baselineKeys(baselineFile)
+	count := 0
+	for i := range vulnerabilities {
+		vulnerabilities[i].InBaseline = inBaseline[diffKey(vulnerabilities[i])]
+		if vulnerabilities[i].InBaseline {
+			count++
+		}
+	}
+	logger.Infof("%d of %d vulnerabilities are in baseline [%s]", count, len(vulnerabilities), baselineFile)
+}
+
+// baselineKeys returns the diff keys of the vulnerabilities of the baseline
+func baselineKeys(baselineFile string) map[string]bool {
+	baseline := loadReport(baselineFile)
+	keys := make(map[string]bool, len(baseline.Vulnerabilities))
+	for _, vulnerability := range baseline.Vulnerabilities {
+		keys[diffKey(vulnerability)] = true
+	}
+	return keys
+}
+
+// reportToBaselineFile writes the report to the baseline file, so the current findings are accepted by the next scans
+func reportToBaselineFile(report *vulnerabilityReport, file string) {
+	writeReportFile(report, file, "baseline", writeJSONReport)
+	logger.Infof("Baseline [%s] updated with %d vulnerabilities", file, len(report.Vulnerabilities))
+}
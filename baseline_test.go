@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMarkBaselineVulnerabilities(t *testing.T) {
+	initializeLogger("")
+	tmpPath, _ := ioutil.TempDir("", "clair-scanner-baseline")
+	defer os.RemoveAll(tmpPath)
+	baselineFile := filepath.Join(tmpPath, "baseline.json")
+
+	reportToBaselineFile(&vulnerabilityReport{Image: "myapp:1.0", Vulnerabilities: []vulnerabilityInfo{
+		{Vulnerability: "CVE-2016-9840", FeatureName: "zlib", FeatureVersion: "1.2.8", Severity: "High"},
+		{Vulnerability: "CVE-2017-3735", FeatureName: "openssl", FeatureVersion: "1.0.2k-r0", Severity: "Medium"},
+	}}, baselineFile)
+
+	vulnerabilities := []vulnerabilityInfo{
+		{Vulnerability: "CVE-2016-9840", FeatureName: "zlib", FeatureVersion: "1.2.11", Severity: "High"},
+		{Vulnerability: "CVE-2017-3735", FeatureName: "libssl1.0", FeatureVersion: "1.0.2k-r0", Severity: "Medium"},
+		{Vulnerability: "CVE-2017-15650", FeatureName: "musl", FeatureVersion: "1.1.15", Severity: "High"},
+	}
+	markBaselineVulnerabilities(baselineFile, vulnerabilities)
+	if !vulnerabilities[0].InBaseline {
+		t.Errorf("Expected a finding of the baseline to be accepted whatever the package version, got %+v", vulnerabilities[0])
+	}
+	if vulnerabilities[1].InBaseline || vulnerabilities[2].InBaseline {
+		t.Errorf("Expected the findings of other packages not to be in the baseline, got %+v", vulnerabilities[1:])
+	}
+
+	config := scannerConfig{imageName: "myapp:1.1", whitelistThreshold: "Unknown", baselineFile: baselineFile}
+	unapproved := checkForUnapprovedVulnerabilities(config, vulnerabilities)
+	if len(unapproved) != 2 || contains(unapproved, "CVE-2016-9840") {
+		t.Errorf("Expected only the new findings to be unapproved, got %v", unapproved)
+	}
+	if vulnerabilities[0].Status != "Approved" || formatTableData(vulnerabilities)[0][0] != formatStatus("Baseline") {
+		t.Errorf("Expected the finding of the baseline to be shown as approved by the baseline, got %+v", vulnerabilities[0])
+	}
+	if code := exitCode([]*vulnerabilityReport{{Unapproved: unapproved}}, false); code != exitCodeUnapproved {
+		t.Errorf("Expected the new findings to fail the scan, got exit code %d", code)
+	}
+
+	vulnerabilities = vulnerabilities[:1]
+	markBaselineVulnerabilities(baselineFile, vulnerabilities)
+	unapproved = checkForUnapprovedVulnerabilities(config, vulnerabilities)
+	if code := exitCode([]*vulnerabilityReport{{Unapproved: unapproved}}, false); code != exitCodeClean {
+		t.Errorf("Expected a scan with only findings of the baseline to pass, got exit code %d for %v", code, unapproved)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// cacheDir is the folder where downloaded vulnerability data is cached between scans
+var cacheDir = defaultCacheDir()
+
+// defaultCacheDir returns the clair-scanner folder in the user cache folder, or in the temporary folder when there is none
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "clair-scanner")
+}
+
+// cachedDownload returns the cached content of name when it is younger than ttl, otherwise it downloads the url and caches the content
+func cachedDownload(name string, ttl time.Duration, url string, headers map[string]string) ([]byte, error) {
+	return cachedFetch(name, ttl, func() ([]byte, error) {
+		return download(url, headers)
+	})
+}
+
+// cachedFetch returns the cached content of name when it is younger than ttl, otherwise it fetches the content and caches it
+func cachedFetch(name string, ttl time.Duration, fetch func() ([]byte, error)) ([]byte, error) {
+	path := filepath.Join(cacheDir, name)
+	if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) < ttl {
+		if content, err := ioutil.ReadFile(path); err == nil {
+			return content, nil
+		}
+	}
+
+	content, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+		err = ioutil.WriteFile(path, content, 0644)
+	}
+	if err != nil {
+		logger.Warnf("Could not cache %s: %v", name, err)
+	}
+	return content, nil
+}
+
+// download fetches the content of the url with the given request headers
+func download(url string, headers map[string]string) ([]byte, error) {
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+	client := &http.Client{Timeout: time.Minute, Transport: newTransport()}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	content, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, &responseError{response.StatusCode, url}
+	}
+	return content, nil
+}
+
+// responseError is the error of a download that got a response other than 200 OK
+type responseError struct {
+	statusCode int
+	url        string
+}
+
+func (err *responseError) Error() string {
+	return fmt.Sprintf("got response %d from %s", err.statusCode, err.url)
+}
+
+// upload sends the content to the url with the given request headers and returns the response, responses other than 2xx are an error
+func upload(method string, url string, content []byte, headers map[string]string) ([]byte, error) {
+	request, err := http.NewRequest(method, url, bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+	client := &http.Client{Timeout: time.Minute, Transport: newTransport()}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return nil, fmt.Errorf("got response %d from %s with message %s", response.StatusCode, request.URL.Host+request.URL.Path, string(body))
+	}
+	return body, nil
+}
@@ -1,11 +1,13 @@
 package main
 
 import (
-	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"net/http"
+	"strconv"
 
 	"github.com/coreos/clair/api/v1"
 )
@@ -13,40 +15,115 @@ import (
 const (
 	postLayerURI        = "/v1/layers"
 	getLayerFeaturesURI = "/v1/layers/%s?vulnerabilities"
+	getLayerURI         = "/v1/layers/%s"
+	deleteLayerURI      = "/v1/layers/%s"
+	getNamespacesURI    = "/v1/namespaces"
+	getIndexStateURI    = "/indexer/api/v1/index_state"
 )
 
 type vulnerabilityInfo struct {
-	FeatureName    string `json:"featurename"`
-	FeatureVersion string `json:"featureversion"`
-	Vulnerability  string `json:"vulnerability"`
-	Namespace      string `json:"namespace"`
-	Description    string `json:"description"`
-	Link           string `json:"link"`
-	Severity       string `json:"severity"`
-	FixedBy        string `json:"fixedby"`
+	FeatureName     string  `json:"featurename"`
+	FeatureVersion  string  `json:"featureversion"`
+	Vulnerability   string  `json:"vulnerability"`
+	Namespace       string  `json:"namespace"`
+	Description     string  `json:"description"`
+	Link            string  `json:"link"`
+	Severity        string  `json:"severity"`
+	FixedBy         string  `json:"fixedby"`
+	CVSSScore       float64 `json:"cvssscore,omitempty"`
+	CVSSVector      string  `json:"cvssvector,omitempty"`
+	CVSSVersion     string  `json:"cvssversion,omitempty"`
+	EPSS            float64 `json:"epss,omitempty"`
+	EPSSPercentile  float64 `json:"epsspercentile,omitempty"`
+	KnownExploited  bool    `json:"known_exploited"`
+	AddedBy         string  `json:"addedby"`
+	Instruction     string  `json:"instruction,omitempty"`
+	InstructionLine int     `json:"instructionline,omitempty"`
+	InBaseImage     bool    `json:"inbaseimage"`
+	InBaseline      bool    `json:"inbaseline"`
+	Status          string  `json:"status"`
+	Whitelisted     bool    `json:"whitelisted"`
+	WhitelistReason string  `json:"whitelistreason,omitempty"`
+	WhitelistOwner  string  `json:"whitelistowner,omitempty"`
 }
 
-// analyzeLayer tells Clair which layers to analyze
-func analyzeLayers(layerIds []string, clairURL string, scannerIP string) {
-	tmpPath := "http://" + scannerIP + ":" + httpPort
+type featureInfo struct {
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	Namespace string `json:"namespace"`
+	AddedBy   string `json:"addedby"`
+}
 
+// analyzeLayers tells Clair which layers to analyze, using the Clair API of the config
+func analyzeLayers(config scannerConfig, tmpPath string, layerIds []string) {
+	span := tracer.startSpan("analyze layers", spanKindInternal, map[string]string{"layers": strconv.Itoa(len(layerIds))})
+	defer span.finish(nil)
+	switch config.clairAPI {
+	case "v4":
+		indexManifest(config.clairURL, imageManifest(tmpPath, layerIds, config.serverURL))
+	case "v3":
+		analyzeAncestry(config.clairURL, layerIds, config.serverURL)
+	default:
+		analyzeLayersV1(layerIds, clairLayerNames(tmpPath, layerIds), config.clairURL, config.serverURL)
+	}
+}
+
+// analyzeLayersV1 tells Clair which layers to analyze, layers Clair already analyzed are skipped
+func analyzeLayersV1(layerIds []string, layerNames []string, clairURL string, serverURL string) {
 	for i := 0; i < len(layerIds); i++ {
+		if layerExists(clairURL, layerNames[i]) {
+			logger.Infof("Skipping %s, Clair already analyzed it", layerIds[i])
+			continue
+		}
 		logger.Infof("Analyzing %s", layerIds[i])
 
 		if i > 0 {
-			analyzeLayer(clairURL, tmpPath+"/"+layerIds[i]+"/layer.tar", layerIds[i], layerIds[i-1])
+			analyzeLayer(clairURL, layerURL(serverURL, layerIds[i]), layerHeaders(layerIds[i]), layerNames[i], layerNames[i-1])
 		} else {
-			analyzeLayer(clairURL, tmpPath+"/"+layerIds[i]+"/layer.tar", layerIds[i], "")
+			analyzeLayer(clairURL, layerURL(serverURL, layerIds[i]), layerHeaders(layerIds[i]), layerNames[i], "")
 		}
 	}
 }
 
+// clairLayerNames names the layers by their chain ID, a digest of the content of the layer and all its parents, so Clair recognizes layers it analyzed for other images
+func clairLayerNames(tmpPath string, layerIds []string) []string {
+	digests := make([]string, len(layerIds))
+	for i, layerID := range layerIds {
+		digests[i] = layerDigest(tmpPath, layerID)
+	}
+	return chainIDs(digests)
+}
+
+// chainIDs returns the chain ID of every layer, the digest of the first layer or else the digest of the chain ID of its parent and its own digest
+func chainIDs(digests []string) []string {
+	names := make([]string, len(digests))
+	for i, digest := range digests {
+		names[i] = digest
+		if i > 0 {
+			chainID := sha256.Sum256([]byte(names[i-1] + " " + digest))
+			names[i] = "sha256:" + hex.EncodeToString(chainID[:])
+		}
+	}
+	return names
+}
+
+// layerExists tells whether Clair already analyzed the layer
+func layerExists(clairURL string, layerName string) bool {
+	response, err := clairGet(clairURL + fmt.Sprintf(getLayerURI, layerName))
+	if err != nil {
+		logger.Fatalf("Could not analyze layer: GET from Clair failed %v", err)
+	}
+	response.Body.Close()
+	return response.StatusCode == 200
+}
+
 // analyzeLayer pushes the required information to Clair to scan the layer
-func analyzeLayer(clairURL, path, layerName, parentLayerName string) {
+func analyzeLayer(clairURL, path string, headers map[string]string, layerName, parentLayerName string) {
 	payload := v1.LayerEnvelope{
 		Layer: &v1.Layer{
 			Name:       layerName,
 			Path:       path,
+			Headers:    headers,
 			ParentName: parentLayerName,
 			Format:     "Docker",
 		},
@@ -56,14 +133,7 @@ func analyzeLayer(clairURL, path, layerName, parentLayerName string) {
 		logger.Fatalf("Could not analyze layer: payload is not JSON %v", err)
 	}
 
-	request, err := http.NewRequest("POST", clairURL+postLayerURI, bytes.NewBuffer(jsonPayload))
-	if err != nil {
-		logger.Fatalf("Could not analyze layer: could not prepare request for Clair %v", err)
-	}
-
-	request.Header.Set("Content-Type", "application/json")
-	client := &http.Client{}
-	response, err := client.Do(request)
+	response, err := clairPost(clairURL+postLayerURI, jsonPayload)
 	if err != nil {
 		logger.Fatalf("Could not analyze layer: POST to Clair failed %v", err)
 	}
@@ -75,32 +145,146 @@ func analyzeLayer(clairURL, path, layerName, parentLayerName string) {
 	}
 }
 
-// getVulnerabilities fetches vulnerabilities from Clair and extracts the required information
-func getVulnerabilities(config scannerConfig, layerIds []string) []vulnerabilityInfo {
+// deleteLayers deletes the analyzed layers from Clair, using the Clair API of the config, failures are logged as warnings
+func deleteLayers(config scannerConfig, tmpPath string, layerIds []string) {
+	switch config.clairAPI {
+	case "v4":
+		deleteFromClair(config.clairURL + fmt.Sprintf(deleteIndexReportURI, imageManifest(tmpPath, layerIds, config.serverURL).Hash))
+	case "v3":
+		logger.Warnf("Clair 3 can't delete ancestries, the layers are kept in Clair")
+	default:
+		// Children first, so no layer is deleted while layers based on it still exist
+		layerNames := clairLayerNames(tmpPath, layerIds)
+		for i := len(layerNames) - 1; i >= 0; i-- {
+			deleteFromClair(config.clairURL + fmt.Sprintf(deleteLayerURI, layerNames[i]))
+		}
+	}
+}
+
+// deleteFromClair sends a DELETE request to Clair, what doesn't exist (anymore) is not an error
+func deleteFromClair(url string) {
+	response, err := clairRequest("DELETE", url, nil)
+	if err != nil {
+		logger.Warnf("Could not delete %s from Clair: %v", url, err)
+		return
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 && response.StatusCode != 404 {
+		body, _ := ioutil.ReadAll(response.Body)
+		logger.Warnf("Could not delete %s from Clair: Got response %d with message %s", url, response.StatusCode, string(body))
+	}
+}
+
+// detectClairAPI probes the Clair URL for the API it serves, the indexer of Clair 4, the v1 API of Clair 2 or else the Clair v3 REST gateway
+func detectClairAPI(clairURL string) string {
+	probe := func(uri string) bool {
+		response, err := clairGet(clairURL + uri)
+		if err != nil {
+			logger.Fatalf("Could not detect the Clair API version, Clair is unreachable: %v", err)
+		}
+		response.Body.Close()
+		return response.StatusCode == 200
+	}
+
+	api := "v3"
+	if probe(getIndexStateURI) {
+		api = "v4"
+	} else if probe(getNamespacesURI) {
+		api = "v1"
+	}
+	logger.Infof("Detected Clair API %s", api)
+	return api
+}
+
+// getVulnerabilities fetches features and vulnerabilities from Clair and extracts the required information
+func getVulnerabilities(config scannerConfig, tmpPath string, layerIds []string) ([]featureInfo, []vulnerabilityInfo) {
+	span := tracer.startSpan("fetch vulnerabilities", spanKindInternal, nil)
+	defer span.finish(nil)
+	var features = make([]featureInfo, 0)
 	var vulnerabilities = make([]vulnerabilityInfo, 0)
-	//Last layer gives you all the vulnerabilities of all layers
-	rawVulnerabilities := fetchLayerVulnerabilities(config.clairURL, layerIds[len(layerIds)-1])
-	if len(rawVulnerabilities.Features) == 0 {
+	addFeature := func(feature v1.Feature) {
+		features = append(features, featureInfo{feature.Name, feature.Version, feature.NamespaceName, feature.AddedBy})
+		for _, rawVulnerability := range feature.Vulnerabilities {
+			vulnerability := vulnerabilityInfo{
+				FeatureName:    feature.Name,
+				FeatureVersion: feature.Version,
+				Vulnerability:  rawVulnerability.Name,
+				Namespace:      rawVulnerability.NamespaceName,
+				Description:    rawVulnerability.Description,
+				Link:           rawVulnerability.Link,
+				Severity:       rawVulnerability.Severity,
+				FixedBy:        rawVulnerability.FixedBy,
+				AddedBy:        feature.AddedBy,
+			}
+			vulnerability.CVSSVersion, vulnerability.CVSSScore, vulnerability.CVSSVector = cvssFromMetadata(rawVulnerability.Metadata)
+			vulnerabilities = append(vulnerabilities, vulnerability)
+			config.ndjson.write(config, vulnerability)
+		}
+	}
+
+	//Last layer gives you all the vulnerabilities of all layers, every feature tells which layer added it
+	switch config.clairAPI {
+	case "v4":
+		addFeatures(fetchVulnerabilityReport(config.clairURL, imageManifest(tmpPath, layerIds, config.serverURL), layerIds), addFeature)
+	case "v3":
+		addFeatures(fetchAncestry(config.clairURL, layerIds), addFeature)
+	default:
+		fetchLayersV1(config.clairURL, layerIds, clairLayerNames(tmpPath, layerIds), addFeature)
+	}
+	config.ndjson.finish()
+	if len(features) == 0 {
+		logger.Warn("Could not fetch vulnerabilities. No features have been detected in the image. This usually means that the image isn't supported by Clair")
 		if config.exitWhenNoFeatures {
-			logger.Fatal("Could not fetch vulnerabilities. No features have been detected in the image. This usually means that the image isn't supported by Clair")
+			return nil, nil
 		}
-		return nil
 	}
+	return features, vulnerabilities
+}
 
-	for _, feature := range rawVulnerabilities.Features {
-		if len(feature.Vulnerabilities) > 0 {
-			for _, vulnerability := range feature.Vulnerabilities {
-				vulnerability := vulnerabilityInfo{feature.Name, feature.Version, vulnerability.Name, vulnerability.NamespaceName, vulnerability.Description, vulnerability.Link, vulnerability.Severity, vulnerability.FixedBy}
-				vulnerabilities = append(vulnerabilities, vulnerability)
-			}
+// addFeatures passes every feature of the layer to addFeature
+func addFeatures(layer v1.Layer, addFeature func(v1.Feature)) {
+	for _, feature := range layer.Features {
+		addFeature(feature)
+	}
+}
+
+// cvssFromMetadata extracts the CVSS version, base score and vector from the NVD metadata of a vulnerability, CVSSv3 is preferred over CVSSv2
+func cvssFromMetadata(metadata map[string]interface{}) (string, float64, string) {
+	nvd, ok := metadata["NVD"].(map[string]interface{})
+	if !ok {
+		return "", 0, ""
+	}
+	for _, version := range []string{"3", "2"} {
+		cvss, ok := nvd["CVSSv"+version].(map[string]interface{})
+		if !ok {
+			continue
 		}
+		score, ok := cvss["Score"].(float64)
+		if !ok {
+			continue
+		}
+		vector, _ := cvss["Vectors"].(string)
+		return version, score, vector
 	}
-	return vulnerabilities
+	return "", 0, ""
 }
 
-// fetchLayerVulnerabilities fetches vulnerabilities from Clair
-func fetchLayerVulnerabilities(clairURL string, layerID string) v1.Layer {
-	response, err := http.Get(clairURL + fmt.Sprintf(getLayerFeaturesURI, layerID))
+// fetchLayersV1 fetches the vulnerabilities of the top layer from Clair and passes every feature to addFeature as soon as it is decoded,
+// the features tell the ID of the layer that added them
+func fetchLayersV1(clairURL string, layerIds []string, layerNames []string, addFeature func(v1.Feature)) {
+	layerIDs := make(map[string]string, len(layerNames))
+	for i, layerName := range layerNames {
+		layerIDs[layerName] = layerIds[i]
+	}
+	fetchLayerVulnerabilities(clairURL, layerNames[len(layerNames)-1], func(feature v1.Feature) {
+		feature.AddedBy = layerIDs[feature.AddedBy]
+		addFeature(feature)
+	})
+}
+
+// fetchLayerVulnerabilities fetches vulnerabilities from Clair, the features are passed to addFeature one at a time while the response is decoded
+func fetchLayerVulnerabilities(clairURL string, layerID string, addFeature func(v1.Feature)) {
+	response, err := clairGet(clairURL + fmt.Sprintf(getLayerFeaturesURI, layerID))
 	if err != nil {
 		logger.Fatalf("Fetch vulnerabilities, Clair responded with a failure %v", err)
 	}
@@ -111,12 +295,73 @@ func fetchLayerVulnerabilities(clairURL string, layerID string) v1.Layer {
 		logger.Fatalf("Fetch vulnerabilities, Clair responded with a failure: Got response %d with message %s", response.StatusCode, string(body))
 	}
 
-	var apiResponse v1.LayerEnvelope
-	if err = json.NewDecoder(response.Body).Decode(&apiResponse); err != nil {
+	clairError, err := decodeLayerEnvelope(response.Body, addFeature)
+	if err != nil {
 		logger.Fatalf("Fetch vulnerabilities, Could not decode response %v", err)
-	} else if apiResponse.Error != nil {
-		logger.Fatalf("Fetch vulnerabilities, Response contains errors %s", apiResponse.Error.Message)
+	} else if clairError != nil {
+		logger.Fatalf("Fetch vulnerabilities, Response contains errors %s", clairError.Message)
 	}
+}
+
+// decodeLayerEnvelope decodes a Clair v1 layer response and passes the features of the layer to addFeature one at a time,
+// so the vulnerabilities of large images are handled while the response is still read. The error of the response is returned.
+func decodeLayerEnvelope(reader io.Reader, addFeature func(v1.Feature)) (*v1.Error, error) {
+	var clairError *v1.Error
+	decoder := json.NewDecoder(reader)
+	err := decodeObject(decoder, func(key string) error {
+		switch key {
+		case "Error":
+			return decoder.Decode(&clairError)
+		case "Layer":
+			return decodeObject(decoder, func(key string) error {
+				if key != "Features" {
+					return decoder.Decode(&json.RawMessage{})
+				}
+				return decodeArray(decoder, func() error {
+					var feature v1.Feature
+					if err := decoder.Decode(&feature); err != nil {
+						return err
+					}
+					addFeature(feature)
+					return nil
+				})
+			})
+		}
+		return decoder.Decode(&json.RawMessage{})
+	})
+	return clairError, err
+}
+
+// decodeObject decodes the JSON object the decoder is at, decodeValue decodes the value of every key, null has no keys
+func decodeObject(decoder *json.Decoder, decodeValue func(key string) error) error {
+	return decodeDelimited(decoder, '{', func() error {
+		key, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		return decodeValue(key.(string))
+	})
+}
+
+// decodeArray decodes the JSON array the decoder is at, decodeElement decodes every element, null has no elements
+func decodeArray(decoder *json.Decoder, decodeElement func() error) error {
+	return decodeDelimited(decoder, '[', decodeElement)
+}
 
-	return *apiResponse.Layer
+// decodeDelimited decodes the JSON object or array the decoder is at, decodeNext decodes its next key and value or element
+func decodeDelimited(decoder *json.Decoder, delimiter json.Delim, decodeNext func() error) error {
+	token, err := decoder.Token()
+	if err != nil || token == nil {
+		return err
+	}
+	if token != delimiter {
+		return fmt.Errorf("expected %v, got %v", delimiter, token)
+	}
+	for decoder.More() {
+		if err = decodeNext(); err != nil {
+			return err
+		}
+	}
+	_, err = decoder.Token()
+	return err
 }
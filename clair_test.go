@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/clair/api/v1"
+)
+
+func TestCVSSFromMetadata(t *testing.T) {
+	tests := []struct {
+		metadata string
+		version  string
+		score    float64
+		vector   string
+	}{
+		{`{}`, "", 0, ""},
+		{`{"NVD": {"CVSSv2": {"Score": 5, "Vectors": "AV:N/AC:L/Au:N/C:N/I:N/A:P"}}}`, "2", 5, "AV:N/AC:L/Au:N/C:N/I:N/A:P"},
+		{`{"NVD": {"CVSSv2": {"Score": 5}, "CVSSv3": {"Score": 7.5, "Vectors": "AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:H"}}}`, "3", 7.5, "AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:H"},
+	}
+	for _, test := range tests {
+		var metadata map[string]interface{}
+		if err := json.Unmarshal([]byte(test.metadata), &metadata); err != nil {
+			t.Fatal(err)
+		}
+		version, score, vector := cvssFromMetadata(metadata)
+		if version != test.version || score != test.score || vector != test.vector {
+			t.Errorf("Expected %s %.1f %s for %s, got %s %.1f %s", test.version, test.score, test.vector, test.metadata, version, score, vector)
+		}
+	}
+}
+
+func TestDetectClairAPI(t *testing.T) {
+	initializeLogger("")
+	for served, expected := range map[string]string{getIndexStateURI: "v4", getNamespacesURI: "v1", "/ancestry": "v3"} {
+		served := served
+		clair := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != served {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		if api := detectClairAPI(clair.URL); api != expected {
+			t.Errorf("Expected Clair API %s when %s is served, got %s", expected, served, api)
+		}
+		clair.Close()
+	}
+}
+
+func TestDeleteLayers(t *testing.T) {
+	initializeLogger("")
+	tmpPath := createLayerFiles(t, map[string]string{"base": "base content", "top": "top content"})
+	defer os.RemoveAll(tmpPath)
+	layerNames := clairLayerNames(tmpPath, []string{"base", "top"})
+
+	var deleted []string
+	clair := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" {
+			t.Errorf("Expected DELETE requests, got %s", r.Method)
+		}
+		deleted = append(deleted, r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer clair.Close()
+
+	deleteLayers(scannerConfig{clairURL: clair.URL, clairAPI: "v1"}, tmpPath, []string{"base", "top"})
+	if len(deleted) != 2 || deleted[0] != "/v1/layers/"+layerNames[1] || deleted[1] != "/v1/layers/"+layerNames[0] {
+		t.Errorf("Expected the top layer to be deleted before the base layer, got %v", deleted)
+	}
+}
+
+func TestClairLayerNames(t *testing.T) {
+	initializeLogger("")
+	tmpPath := createLayerFiles(t, map[string]string{"a": "debian", "b": "alpine", "c": "app"})
+	defer os.RemoveAll(tmpPath)
+
+	onDebian := clairLayerNames(tmpPath, []string{"a", "c"})
+	onAlpine := clairLayerNames(tmpPath, []string{"b", "c"})
+	if onDebian[0] != layerDigest(tmpPath, "a") {
+		t.Errorf("Expected the base layer to be named by its digest, got %s", onDebian[0])
+	}
+	if onDebian[1] == onAlpine[1] || onDebian[1] != clairLayerNames(tmpPath, []string{"a", "c"})[1] {
+		t.Errorf("Expected the name of a layer to depend on its parents, got %s and %s", onDebian[1], onAlpine[1])
+	}
+}
+
+func TestDecodeLayerEnvelope(t *testing.T) {
+	body := `{"Layer": {"Name": "top", "NamespaceName": "alpine:v3.5", "Features": [
+		{"Name": "zlib", "Version": "1.2.8", "AddedBy": "base", "Vulnerabilities": [{"Name": "CVE-2016-9840", "Severity": "High"}]},
+		{"Name": "musl", "Version": "1.1.15", "AddedBy": "top"}
+	], "IndexedByVersion": 3}, "Error": {"Message": "partial"}}`
+	var features []v1.Feature
+	clairError, err := decodeLayerEnvelope(strings.NewReader(body), func(feature v1.Feature) {
+		features = append(features, feature)
+	})
+	if err != nil {
+		t.Fatalf("Could not decode the layer: %v", err)
+	}
+	if len(features) != 2 || features[0].Name != "zlib" || features[0].Vulnerabilities[0].Name != "CVE-2016-9840" || features[1].AddedBy != "top" {
+		t.Errorf("Expected both features in order, got %+v", features)
+	}
+	if clairError == nil || clairError.Message != "partial" {
+		t.Errorf("Expected the error of the response, got %+v", clairError)
+	}
+
+	if _, err := decodeLayerEnvelope(strings.NewReader(`{"Layer": null}`), func(v1.Feature) { t.Error("Expected no features") }); err != nil {
+		t.Errorf("Expected a null layer to have no features, got %v", err)
+	}
+	if _, err := decodeLayerEnvelope(strings.NewReader(`{"Layer": {"Features": {}}}`), func(v1.Feature) {}); err == nil {
+		t.Error("Expected features that are not an array to fail")
+	}
+}
+
+func TestGetVulnerabilitiesStreamsNDJSON(t *testing.T) {
+	initializeLogger("")
+	tmpPath := createLayerFiles(t, map[string]string{"top": "top content"})
+	defer os.RemoveAll(tmpPath)
+	layerName := clairLayerNames(tmpPath, []string{"top"})[0]
+
+	firstLineRead := make(chan struct{})
+	streamed := false
+	clair := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"Layer": {"Features": [{"Name": "zlib", "Version": "1.2.8", "AddedBy": "`+layerName+`",
+			"Vulnerabilities": [{"Name": "CVE-2016-9840", "Severity": "High"}, {"Name": "CVE-2016-9841", "Severity": "High"}]}`)
+		w.(http.Flusher).Flush()
+		select {
+		case <-firstLineRead:
+			streamed = true
+		case <-time.After(5 * time.Second):
+		}
+		io.WriteString(w, `, {"Name": "busybox", "Version": "1.25.1", "AddedBy": "`+layerName+`",
+			"Vulnerabilities": [{"Name": "CVE-2017-16544", "Severity": "High"}]}]}}`)
+	}))
+	defer clair.Close()
+
+	config := scannerConfig{
+		clairURL:           clair.URL,
+		clairAPI:           "v1",
+		imageName:          "alpine:3.5",
+		format:             "ndjson",
+		whitelistThreshold: "Unknown",
+		excludePackages:    []string{"busybox"},
+		whitelist:          vulnerabilitiesWhitelist{GeneralWhitelist: map[string]whitelistEntry{"CVE-2016-9841": {}}},
+	}
+	reader, writer := io.Pipe()
+	config.ndjson = newVulnerabilityStream(writer, config)
+	lines := make(chan []vulnerabilityInfo)
+	go func() {
+		var vulnerabilities []vulnerabilityInfo
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			var vulnerability vulnerabilityInfo
+			if err := json.Unmarshal(scanner.Bytes(), &vulnerability); err != nil {
+				t.Errorf("Line is not JSON: %v", err)
+			}
+			if len(vulnerabilities) == 0 {
+				close(firstLineRead)
+			}
+			vulnerabilities = append(vulnerabilities, vulnerability)
+		}
+		lines <- vulnerabilities
+	}()
+
+	_, vulnerabilities := getVulnerabilities(config, tmpPath, []string{"top"})
+	writer.Close()
+	written := <-lines
+
+	if !streamed {
+		t.Error("Expected the first line to be written before Clair finished its response")
+	}
+	if len(vulnerabilities) != 3 || vulnerabilities[0].AddedBy != "top" {
+		t.Errorf("Expected all vulnerabilities to be returned with their layer ID, got %+v", vulnerabilities)
+	}
+	if len(written) != 2 || written[0].Status != "Unapproved" || written[1].Status != "Approved" {
+		t.Errorf("Expected the excluded package to be left out and the whitelist to be applied, got %+v", written)
+	}
+	if !config.ndjson.streamed {
+		t.Error("Expected the stream to be marked as written")
+	}
+}
+
+// createLayerFiles creates a temporary folder with a layer.tar file of the given content for every layer ID
+func createLayerFiles(t *testing.T, layers map[string]string) string {
+	tmpPath := createTmpPath("clair-layers")
+	for layerID, content := range layers {
+		if err := os.MkdirAll(tmpPath+"/"+layerID, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(tmpPath+"/"+layerID+"/layer.tar", []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tmpPath
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	clairTokenLifetime = 5 * time.Minute
+	unixSocketScheme   = "unix://"
+	unixSocketURL      = "http://clair"
+	debugBodyLimit     = 4096
+)
+
+// clairClient is the HTTP client of every request to Clair
+var clairClient = &http.Client{}
+
+// clairAuth holds the credentials sent with every request to Clair
+var clairAuth = clairCredentials{}
+
+// clairRetry is the retry policy of requests to Clair
+var clairRetry = retryPolicy{attempts: 1}
+
+type retryPolicy struct {
+	attempts    int
+	backoff     time.Duration
+	statusCodes map[int]bool
+}
+
+// clairEndpoints are the URLs of the Clair instances requests fail over between, scans are configured with the first one
+var clairEndpoints []string
+
+// activeClairEndpoint is the index of the Clair endpoint requests are sent to
+var activeClairEndpoint int
+
+// clairHeaders holds the extra HTTP headers sent with every request to Clair
+var clairHeaders = map[string]string{}
+
+type clairCredentials struct {
+	psk      []byte
+	issuer   string
+	user     string
+	password string
+	token    string
+}
+
+type jwtClaims struct {
+	Issuer    string `json:"iss"`
+	IssuedAt  int64  `json:"iat"`
+	NotBefore int64  `json:"nbf"`
+	Expires   int64  `json:"exp"`
+}
+
+// clairGet sends a GET request to Clair
+func clairGet(url string) (*http.Response, error) {
+	return clairRequest("GET", url, nil)
+}
+
+// clairPost sends a POST request with a JSON payload to Clair
+func clairPost(url string, jsonPayload []byte) (*http.Response, error) {
+	return clairRequest("POST", url, jsonPayload)
+}
+
+// clairRequest sends a request to Clair with the configured credentials, failed requests are retried with exponential backoff on the next Clair endpoint
+func clairRequest(method string, url string, jsonPayload []byte) (*http.Response, error) {
+	backoff := clairRetry.backoff
+	for attempt := 1; ; attempt++ {
+		endpointURL := clairEndpointURL(url)
+		response, err := sendClairRequest(method, endpointURL, jsonPayload)
+		if attempt >= clairRetry.attempts || (err == nil && !clairRetry.statusCodes[response.StatusCode]) {
+			return response, err
+		}
+		if err == nil {
+			response.Body.Close()
+			err = fmt.Errorf("got response %d", response.StatusCode)
+		}
+		if len(clairEndpoints) > 1 {
+			activeClairEndpoint = (activeClairEndpoint + 1) % len(clairEndpoints)
+			logger.Warnf("%s %s failed: %v, failing over to %s in %v (attempt %d of %d)", method, endpointURL, err, clairEndpoints[activeClairEndpoint], backoff, attempt+1, clairRetry.attempts)
+		} else {
+			logger.Warnf("%s %s failed: %v, retrying in %v (attempt %d of %d)", method, endpointURL, err, backoff, attempt+1, clairRetry.attempts)
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// clairEndpointURL returns the URL of the request on the active Clair endpoint
+func clairEndpointURL(url string) string {
+	for _, endpoint := range clairEndpoints {
+		if strings.HasPrefix(url, endpoint) {
+			return clairEndpoints[activeClairEndpoint] + strings.TrimPrefix(url, endpoint)
+		}
+	}
+	return url
+}
+
+// sendClairRequest sends a single request to Clair with the configured headers and credentials
+func sendClairRequest(method string, url string, jsonPayload []byte) (*http.Response, error) {
+	var body io.Reader
+	if jsonPayload != nil {
+		body = bytes.NewBuffer(jsonPayload)
+	}
+	request, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range clairHeaders {
+		request.Header.Set(key, value)
+	}
+	if jsonPayload != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	authorizeClairRequest(request, clairAuth)
+	started := time.Now()
+	span := tracer.startSpan("clair "+method+" "+request.URL.Path, spanKindClient, map[string]string{"http.method": method, "http.url": request.URL.Path, "http.request_content_length": strconv.Itoa(len(jsonPayload))})
+	logger.Debugf("Clair request %s %s with a %d bytes payload, headers %s", method, redactURL(request.URL), len(jsonPayload), redactHeaders(request.Header))
+	response, err := clairClient.Do(request)
+	observeClairRequest(method, started, response, err)
+	debugClairResponse(method, request.URL, started, response, err)
+	if err == nil {
+		span.setAttribute("http.status_code", strconv.Itoa(response.StatusCode))
+	}
+	span.finish(err)
+	return response, err
+}
+
+// debugClairResponse logs the response code and body of a request to Clair at the debug level, the body is read and replaced to be still readable
+func debugClairResponse(method string, requestURL *url.URL, started time.Time, response *http.Response, err error) {
+	if logger.Level < logrus.DebugLevel {
+		return
+	}
+	duration := time.Since(started).Round(time.Millisecond)
+	if err != nil {
+		logger.Debugf("Clair request %s %s failed after %v: %v", method, redactURL(requestURL), duration, err)
+		return
+	}
+	body, _ := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	logged := redactBody(body, response.StatusCode)
+	if len(logged) > debugBodyLimit {
+		logged = fmt.Sprintf("%s... (%d bytes)", logged[:debugBodyLimit], len(body))
+	}
+	logger.Debugf("Clair response %s %s: %s after %v, body %s", method, redactURL(requestURL), response.Status, duration, logged)
+}
+
+// redactURL returns the URL without the password of its user info and with the values of query parameters that are credentials redacted
+func redactURL(requestURL *url.URL) string {
+	redacted := *requestURL
+	if _, ok := redacted.User.Password(); ok {
+		redacted.User = url.UserPassword(redacted.User.Username(), "xxxxx")
+	}
+	query := redacted.Query()
+	for key := range query {
+		if isCredential(key) {
+			query.Set(key, "xxxxx")
+			redacted.RawQuery = query.Encode()
+		}
+	}
+	return redacted.String()
+}
+
+// layerServerToken matches the token of the layer server in the path of a layer URL
+var layerServerToken = regexp.MustCompile(`/[0-9a-f]{32}/`)
+
+// redactBody returns the JSON body with the headers Clair echoes, like the registry token of a v1 layer, and the layer server token
+// in layer URLs redacted. Other bodies are only returned for failed requests, their content is unknown.
+func redactBody(body []byte, statusCode int) string {
+	var decoded interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		if statusCode >= 400 {
+			return string(body)
+		}
+		return fmt.Sprintf("of %d bytes that is not JSON", len(body))
+	}
+	redacted, _ := json.Marshal(redactJSON("", decoded))
+	return string(redacted)
+}
+
+// redactJSON redacts the values of headers and credentials and the layer server token of URLs in the decoded JSON value of the key
+func redactJSON(key string, value interface{}) interface{} {
+	lowerKey := strings.ToLower(key)
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for name, nested := range value {
+			if lowerKey == "headers" {
+				value[name] = "xxxxx"
+			} else {
+				value[name] = redactJSON(name, nested)
+			}
+		}
+	case []interface{}:
+		for i, nested := range value {
+			value[i] = redactJSON(key, nested)
+		}
+	case string:
+		if isCredential(lowerKey) {
+			return "xxxxx"
+		}
+		if lowerKey == "path" || lowerKey == "uri" {
+			if location, err := url.Parse(value); err == nil && location.Host != "" {
+				value = redactURL(location)
+			}
+			return layerServerToken.ReplaceAllString(value, "/xxxxx/")
+		}
+	}
+	return value
+}
+
+// redactHeaders returns the headers as 'Key: value' list with the values of the headers that are credentials redacted
+func redactHeaders(headers http.Header) string {
+	formatted := make([]string, 0, len(headers))
+	for key, values := range headers {
+		value := strings.Join(values, ", ")
+		if isCredential(key) {
+			value = "xxxxx"
+		}
+		formatted = append(formatted, key+": "+value)
+	}
+	sort.Strings(formatted)
+	return "[" + strings.Join(formatted, "; ") + "]"
+}
+
+// isCredential returns whether the header or query parameter holds a credential, like the Authorization header or an API key
+func isCredential(name string) bool {
+	name = strings.ToLower(name)
+	for _, part := range []string{"authorization", "cookie", "token", "key", "secret", "password", "signature"} {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorizeClairRequest adds the Authorization header of the credentials to the request
+func authorizeClairRequest(request *http.Request, credentials clairCredentials) {
+	if credentials.psk != nil {
+		request.Header.Set("Authorization", "Bearer "+signClairToken(credentials.psk, credentials.issuer, time.Now()))
+	} else if credentials.token != "" {
+		request.Header.Set("Authorization", "Bearer "+credentials.token)
+	} else if credentials.user != "" {
+		request.SetBasicAuth(credentials.user, credentials.password)
+	}
+}
+
+// validateClairCredentials validates that only one way of authenticating to Clair is given
+func validateClairCredentials(credentials clairCredentials) {
+	methods := 0
+	for _, given := range []bool{credentials.psk != nil, credentials.token != "", credentials.user != ""} {
+		if given {
+			methods++
+		}
+	}
+	if methods > 1 {
+		logger.Fatalf("Use only one of --clair-psk, --clair-token and --clair-user to authenticate to Clair")
+	}
+	if credentials.password != "" && credentials.user == "" {
+		logger.Fatalf("A Clair password requires a user, use --clair-user")
+	}
+}
+
+// signClairToken creates a JWT signed with the pre-shared key of Clair, as expected by the psk authentication of Clair 4
+func signClairToken(psk []byte, issuer string, now time.Time) string {
+	header, _ := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	claims, _ := json.Marshal(jwtClaims{
+		Issuer:    issuer,
+		IssuedAt:  now.Unix(),
+		NotBefore: now.Unix(),
+		Expires:   now.Add(clairTokenLifetime).Unix(),
+	})
+	token := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	mac := hmac.New(sha256.New, psk)
+	mac.Write([]byte(token))
+	return token + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// newClairClient creates the HTTP client for Clair, trusting the CA file and presenting the client certificate when given
+func newClairClient(caFile string, certFile string, keyFile string, insecure bool) *http.Client {
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecure}
+	if caFile != "" {
+		ca, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			logger.Fatalf("Could not read Clair CA file: %v", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			logger.Fatalf("Could not read Clair CA file: %s contains no PEM certificates", caFile)
+		}
+	}
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			logger.Fatalf("A Clair client certificate requires both --clair-cert and --clair-key")
+		}
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			logger.Fatalf("Could not load Clair client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	if insecure {
+		logger.Warnf("TLS certificate verification of Clair is disabled")
+	}
+
+	transport := newTransport()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}
+}
+
+// dialUnixSocket makes the client connect to the Unix domain socket of a unix:// Clair URL and returns the HTTP URL to request Clair with, other URLs are returned unchanged
+func dialUnixSocket(client *http.Client, clairURL string) string {
+	if !strings.HasPrefix(clairURL, unixSocketScheme) {
+		return clairURL
+	}
+	socket := strings.TrimPrefix(clairURL, unixSocketScheme)
+	if socket == "" {
+		logger.Fatalf("Invalid Clair URL %s given, use unix:///path/to/clair.sock", clairURL)
+	}
+
+	transport := client.Transport.(*http.Transport)
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+	}
+	return unixSocketURL
+}
+
+// parseClairEndpoints parses the comma separated Clair URLs
+func parseClairEndpoints(clairURLs string) []string {
+	var endpoints []string
+	for _, clairURL := range parseList(clairURLs) {
+		if strings.HasPrefix(clairURL, unixSocketScheme) && strings.Contains(clairURLs, ",") {
+			logger.Fatalf("Invalid Clair URL %s given, a Unix domain socket can't be combined with other Clair URLs", clairURL)
+		}
+		endpoints = append(endpoints, strings.TrimSuffix(clairURL, "/"))
+	}
+	if len(endpoints) == 0 {
+		logger.Fatalf("No Clair URL given, use --clair")
+	}
+	return endpoints
+}
+
+// parseHeaders parses the HTTP request headers given as "Name: value"
+func parseHeaders(headers []string) map[string]string {
+	parsed := make(map[string]string, len(headers))
+	for _, header := range headers {
+		for key, value := range parseHeader(header) {
+			parsed[key] = value
+		}
+	}
+	return parsed
+}
+
+// parseRetryPolicy parses and validates the retry options
+func parseRetryPolicy(attempts int, backoff string, statusCodes string) retryPolicy {
+	if attempts < 1 {
+		logger.Fatalf("Invalid number of Clair request attempts %d given, must be at least 1", attempts)
+	}
+	policy := retryPolicy{attempts: attempts, statusCodes: make(map[int]bool)}
+	var err error
+	if policy.backoff, err = time.ParseDuration(backoff); err != nil || policy.backoff < 0 {
+		logger.Fatalf("Invalid Clair retry backoff %s given, use a duration like '2s'", backoff)
+	}
+	for _, code := range parseList(statusCodes) {
+		statusCode, err := strconv.Atoi(code)
+		if err != nil || statusCode < 100 || statusCode > 599 {
+			logger.Fatalf("Invalid HTTP status code %s given to retry", code)
+		}
+		policy.statusCodes[statusCode] = true
+	}
+	return policy
+}
+
+// parseClairPSK decodes the base64 encoded pre-shared key, as it is written in the Clair configuration
+func parseClairPSK(psk string) []byte {
+	if psk == "" {
+		return nil
+	}
+	key, err := base64.StdEncoding.DecodeString(psk)
+	if err != nil {
+		logger.Fatalf("Invalid Clair pre-shared key given, must be base64 encoded: %v", err)
+	}
+	return key
+}
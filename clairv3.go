@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/coreos/clair/api/v1"
+)
+
+const (
+	postAncestryURI = "/ancestry"
+	getAncestryURI  = "/ancestry/%s?with_features=true&with_vulnerabilities=true"
+)
+
+// Clair v3 serves its gRPC AncestryService as JSON through a REST gateway, these types follow the JSON names of the gateway
+type ancestryLayer struct {
+	Hash    string            `json:"hash"`
+	Path    string            `json:"path,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+type postAncestryRequest struct {
+	AncestryName string          `json:"ancestry_name"`
+	Format       string          `json:"format"`
+	Layers       []ancestryLayer `json:"layers"`
+}
+
+type ancestryVulnerability struct {
+	Name          string `json:"name"`
+	NamespaceName string `json:"namespace_name"`
+	Description   string `json:"description"`
+	Link          string `json:"link"`
+	Severity      string `json:"severity"`
+	Metadata      string `json:"metadata"`
+	FixedBy       string `json:"fixed_by"`
+}
+
+type ancestryFeature struct {
+	Name            string                  `json:"name"`
+	NamespaceName   string                  `json:"namespace_name"`
+	Version         string                  `json:"version"`
+	AddedBy         ancestryLayer           `json:"added_by"`
+	Vulnerabilities []ancestryVulnerability `json:"vulnerabilities"`
+}
+
+type getAncestryResponse struct {
+	Ancestry struct {
+		Name     string            `json:"name"`
+		Features []ancestryFeature `json:"features"`
+	} `json:"ancestry"`
+}
+
+// analyzeAncestry posts all layers of the image as one ancestry to the Clair v3 REST gateway, the ancestry is named after the top layer
+func analyzeAncestry(clairURL string, layerIds []string, serverURL string) {
+	request := postAncestryRequest{AncestryName: layerIds[len(layerIds)-1], Format: "Docker"}
+	for _, layerID := range layerIds {
+		logger.Infof("Analyzing %s", layerID)
+		request.Layers = append(request.Layers, ancestryLayer{Hash: layerID, Path: layerURL(serverURL, layerID), Headers: layerHeaders(layerID)})
+	}
+	jsonPayload, err := json.Marshal(request)
+	if err != nil {
+		logger.Fatalf("Could not analyze ancestry: payload is not JSON %v", err)
+	}
+
+	response, err := clairPost(clairURL+postAncestryURI, jsonPayload)
+	if err != nil {
+		logger.Fatalf("Could not analyze ancestry: POST to Clair failed %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != 200 && response.StatusCode != 201 {
+		body, _ := ioutil.ReadAll(response.Body)
+		logger.Fatalf("Could not analyze ancestry: Clair responded with a failure: Got response %d with message %s", response.StatusCode, string(body))
+	}
+}
+
+// fetchAncestry fetches the features and vulnerabilities of the ancestry from the Clair v3 REST gateway, converted to a Clair v1 layer
+func fetchAncestry(clairURL string, layerIds []string) v1.Layer {
+	response, err := clairGet(clairURL + fmt.Sprintf(getAncestryURI, layerIds[len(layerIds)-1]))
+	if err != nil {
+		logger.Fatalf("Fetch vulnerabilities, Clair responded with a failure %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != 200 {
+		body, _ := ioutil.ReadAll(response.Body)
+		logger.Fatalf("Fetch vulnerabilities, Clair responded with a failure: Got response %d with message %s", response.StatusCode, string(body))
+	}
+
+	var apiResponse getAncestryResponse
+	if err = json.NewDecoder(response.Body).Decode(&apiResponse); err != nil {
+		logger.Fatalf("Fetch vulnerabilities, Could not decode response %v", err)
+	}
+	return ancestryToLayer(apiResponse, layerIds)
+}
+
+// ancestryToLayer converts a Clair v3 ancestry to a Clair v1 layer, features without the layer that added them are attributed to the top layer
+func ancestryToLayer(ancestry getAncestryResponse, layerIds []string) v1.Layer {
+	layer := v1.Layer{Name: ancestry.Ancestry.Name}
+	for _, feature := range ancestry.Ancestry.Features {
+		addedBy := feature.AddedBy.Hash
+		if addedBy == "" {
+			addedBy = layerIds[len(layerIds)-1]
+		}
+		converted := v1.Feature{
+			Name:          feature.Name,
+			NamespaceName: feature.NamespaceName,
+			Version:       feature.Version,
+			AddedBy:       addedBy,
+		}
+		for _, vulnerability := range feature.Vulnerabilities {
+			var metadata map[string]interface{}
+			if vulnerability.Metadata != "" {
+				json.Unmarshal([]byte(vulnerability.Metadata), &metadata)
+			}
+			converted.Vulnerabilities = append(converted.Vulnerabilities, v1.Vulnerability{
+				Name:          vulnerability.Name,
+				NamespaceName: vulnerability.NamespaceName,
+				Description:   vulnerability.Description,
+				Link:          vulnerability.Link,
+				Severity:      vulnerability.Severity,
+				Metadata:      metadata,
+				FixedBy:       vulnerability.FixedBy,
+			})
+		}
+		layer.Features = append(layer.Features, converted)
+	}
+	return layer
+}
+
+// Validate that the given Clair API version is supported
+func validateClairAPI(api string) {
+	if api != "auto" && api != "v1" && api != "v3" && api != "v4" {
+		logger.Fatalf("Invalid Clair API %s given", api)
+	}
+}
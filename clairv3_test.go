@@ -0,0 +1,27 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAncestryToLayer(t *testing.T) {
+	var ancestry getAncestryResponse
+	err := json.Unmarshal([]byte(`{"ancestry": {"name": "top", "features": [
+		{"name": "openssl", "namespace_name": "debian:8", "version": "1.0.1t", "added_by": {"hash": "base"},
+		 "vulnerabilities": [{"name": "CVE-1", "severity": "High", "fixed_by": "1.0.1u", "metadata": "{\"NVD\": {\"CVSSv3\": {\"Score\": 7.5}}}"}]},
+		{"name": "zlib", "namespace_name": "debian:8", "version": "1.2.8"}
+	]}}`), &ancestry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	layer := ancestryToLayer(ancestry, []string{"base", "top"})
+	if len(layer.Features) != 2 || layer.Features[0].AddedBy != "base" || layer.Features[1].AddedBy != "top" {
+		t.Errorf("Expected features added by base and top, got %v", layer.Features)
+	}
+	vulnerability := layer.Features[0].Vulnerabilities[0]
+	if _, score, _ := cvssFromMetadata(vulnerability.Metadata); vulnerability.FixedBy != "1.0.1u" || score != 7.5 {
+		t.Errorf("Expected the vulnerability with its fixed version and CVSS score, got %v", vulnerability)
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coreos/clair/api/v1"
+)
+
+const (
+	postIndexReportURI         = "/indexer/api/v1/index_report"
+	getIndexReportURI          = "/indexer/api/v1/index_report/%s"
+	getVulnerabilityReportURI  = "/matcher/api/v1/vulnerability_report/%s"
+	deleteIndexReportURI       = "/indexer/api/v1/index_report/%s"
+	indexPollInterval          = 2 * time.Second
+	indexTimeout               = 30 * time.Minute
+	indexStateFinished         = "IndexFinished"
+	indexStateError            = "IndexError"
+	clairV4DefaultSeverityName = "Unknown"
+)
+
+type clairV4Layer struct {
+	Hash    string              `json:"hash"`
+	URI     string              `json:"uri"`
+	Headers map[string][]string `json:"headers"`
+}
+
+type clairV4Manifest struct {
+	Hash   string         `json:"hash"`
+	Layers []clairV4Layer `json:"layers"`
+}
+
+type clairV4IndexReport struct {
+	ManifestHash string `json:"manifest_hash"`
+	State        string `json:"state"`
+	Success      bool   `json:"success"`
+	Err          string `json:"err"`
+}
+
+type clairV4Package struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type clairV4Distribution struct {
+	DID       string `json:"did"`
+	Name      string `json:"name"`
+	VersionID string `json:"version_id"`
+}
+
+type clairV4Environment struct {
+	IntroducedIn   string `json:"introduced_in"`
+	DistributionID string `json:"distribution_id"`
+}
+
+type clairV4Vulnerability struct {
+	ID                 string `json:"id"`
+	Name               string `json:"name"`
+	Description        string `json:"description"`
+	Links              string `json:"links"`
+	NormalizedSeverity string `json:"normalized_severity"`
+	FixedInVersion     string `json:"fixed_in_version"`
+}
+
+type clairV4VulnerabilityReport struct {
+	ManifestHash           string                          `json:"manifest_hash"`
+	Packages               map[string]clairV4Package       `json:"packages"`
+	Distributions          map[string]clairV4Distribution  `json:"distributions"`
+	Environments           map[string][]clairV4Environment `json:"environments"`
+	Vulnerabilities        map[string]clairV4Vulnerability `json:"vulnerabilities"`
+	PackageVulnerabilities map[string][]string             `json:"package_vulnerabilities"`
+}
+
+// layerDigests caches the digest of every layer file, the layers are hashed once per scan
+var layerDigests = make(map[string]string)
+
+// layerDigest returns the sha256 digest of the layer file in the temporary folder
+func layerDigest(tmpPath string, layerID string) string {
+	file := tmpPath + "/" + layerID + "/" + layerFileName
+	if digest, exists := layerDigests[file]; exists {
+		return digest
+	}
+	f, err := openLayerFile(file)
+	if err != nil {
+		logger.Fatalf("Could not hash layer [%s]: %v", layerID, err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err = io.Copy(hash, f); err != nil {
+		logger.Fatalf("Could not hash layer [%s]: %v", layerID, err)
+	}
+	layerDigests[file] = "sha256:" + hex.EncodeToString(hash.Sum(nil))
+	return layerDigests[file]
+}
+
+// imageManifest builds the Clair v4 manifest of the image layers, the manifest hash is derived from the layer digests
+func imageManifest(tmpPath string, layerIds []string, serverURL string) clairV4Manifest {
+	manifest := clairV4Manifest{}
+	var digests []string
+	for _, layerID := range layerIds {
+		digest := layerDigest(tmpPath, layerID)
+		digests = append(digests, digest)
+		headers := map[string][]string{}
+		for name, value := range layerHeaders(layerID) {
+			headers[name] = []string{value}
+		}
+		manifest.Layers = append(manifest.Layers, clairV4Layer{Hash: digest, URI: layerURL(serverURL, layerID), Headers: headers})
+	}
+	manifest.Hash = manifestHash(digests)
+	return manifest
+}
+
+// manifestHash derives the hash of a manifest from its layer digests
+func manifestHash(digests []string) string {
+	hash := sha256.New()
+	for _, digest := range digests {
+		hash.Write([]byte(digest))
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil))
+}
+
+// indexManifest asks the Clair v4 indexer to index the image and waits until indexing is finished
+func indexManifest(clairURL string, manifest clairV4Manifest) {
+	if manifestIndexed(clairURL, manifest) {
+		logger.Infof("Skipping %s, Clair already indexed it", manifest.Hash)
+		return
+	}
+	for _, layer := range manifest.Layers {
+		logger.Infof("Analyzing %s", layer.Hash)
+	}
+	jsonPayload, err := json.Marshal(manifest)
+	if err != nil {
+		logger.Fatalf("Could not index image: payload is not JSON %v", err)
+	}
+
+	response, err := clairPost(clairURL+postIndexReportURI, jsonPayload)
+	if err != nil {
+		logger.Fatalf("Could not index image: POST to Clair failed %v", err)
+	}
+	report := decodeIndexReport(response)
+
+	deadline := time.Now().Add(indexTimeout)
+	for report.State != indexStateFinished && report.State != indexStateError && !report.Success {
+		if time.Now().After(deadline) {
+			logger.Fatalf("Could not index image: Clair did not finish indexing within %v, last state %s", indexTimeout, report.State)
+		}
+		time.Sleep(indexPollInterval)
+		response, err = clairGet(clairURL + fmt.Sprintf(getIndexReportURI, manifest.Hash))
+		if err != nil {
+			logger.Fatalf("Could not index image: GET from Clair failed %v", err)
+		}
+		report = decodeIndexReport(response)
+	}
+	if report.State == indexStateError {
+		logger.Fatalf("Could not index image: Clair failed to index the image: %s", report.Err)
+	}
+}
+
+// manifestIndexed tells whether Clair already indexed the manifest
+func manifestIndexed(clairURL string, manifest clairV4Manifest) bool {
+	response, err := clairGet(clairURL + fmt.Sprintf(getIndexReportURI, manifest.Hash))
+	if err != nil {
+		logger.Fatalf("Could not index image: GET from Clair failed %v", err)
+	}
+	defer response.Body.Close()
+
+	var report clairV4IndexReport
+	if response.StatusCode != 200 || json.NewDecoder(response.Body).Decode(&report) != nil {
+		return false
+	}
+	return report.State == indexStateFinished
+}
+
+func decodeIndexReport(response *http.Response) clairV4IndexReport {
+	defer response.Body.Close()
+	if response.StatusCode != 200 && response.StatusCode != 201 {
+		body, _ := ioutil.ReadAll(response.Body)
+		logger.Fatalf("Could not index image: Clair responded with a failure: Got response %d with message %s", response.StatusCode, string(body))
+	}
+	var report clairV4IndexReport
+	if err := json.NewDecoder(response.Body).Decode(&report); err != nil {
+		logger.Fatalf("Could not index image: Could not decode response %v", err)
+	}
+	return report
+}
+
+// fetchVulnerabilityReport fetches the vulnerability report of the image from the Clair v4 matcher, converted to a Clair v1 layer
+func fetchVulnerabilityReport(clairURL string, manifest clairV4Manifest, layerIds []string) v1.Layer {
+	response, err := clairGet(clairURL + fmt.Sprintf(getVulnerabilityReportURI, manifest.Hash))
+	if err != nil {
+		logger.Fatalf("Fetch vulnerabilities, Clair responded with a failure %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != 200 {
+		body, _ := ioutil.ReadAll(response.Body)
+		logger.Fatalf("Fetch vulnerabilities, Clair responded with a failure: Got response %d with message %s", response.StatusCode, string(body))
+	}
+
+	var report clairV4VulnerabilityReport
+	if err = json.NewDecoder(response.Body).Decode(&report); err != nil {
+		logger.Fatalf("Fetch vulnerabilities, Could not decode response %v", err)
+	}
+
+	layerIDs := make(map[string]string, len(manifest.Layers))
+	for i, layer := range manifest.Layers {
+		layerIDs[layer.Hash] = layerIds[i]
+	}
+	return vulnerabilityReportToLayer(report, layerIDs)
+}
+
+// vulnerabilityReportToLayer converts a Clair v4 vulnerability report to a Clair v1 layer, layerIDs maps the layer digests to the layer IDs
+func vulnerabilityReportToLayer(report clairV4VulnerabilityReport, layerIDs map[string]string) v1.Layer {
+	layer := v1.Layer{Name: report.ManifestHash}
+	for id, pkg := range report.Packages {
+		feature := v1.Feature{Name: pkg.Name, Version: pkg.Version}
+		if environments := report.Environments[id]; len(environments) > 0 {
+			feature.AddedBy = layerIDs[environments[0].IntroducedIn]
+			if distribution, exists := report.Distributions[environments[0].DistributionID]; exists {
+				feature.NamespaceName = distribution.DID + ":" + distribution.VersionID
+			}
+		}
+		for _, vulnerabilityID := range report.PackageVulnerabilities[id] {
+			vulnerability := report.Vulnerabilities[vulnerabilityID]
+			severity := vulnerability.NormalizedSeverity
+			if _, exists := SeverityMap[severity]; !exists {
+				severity = clairV4DefaultSeverityName
+			}
+			feature.Vulnerabilities = append(feature.Vulnerabilities, v1.Vulnerability{
+				Name:          vulnerability.Name,
+				NamespaceName: feature.NamespaceName,
+				Description:   vulnerability.Description,
+				Link:          strings.SplitN(vulnerability.Links, " ", 2)[0],
+				Severity:      severity,
+				FixedBy:       vulnerability.FixedInVersion,
+			})
+		}
+		layer.Features = append(layer.Features, feature)
+	}
+	return layer
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestVulnerabilityReportToLayer(t *testing.T) {
+	var report clairV4VulnerabilityReport
+	err := json.Unmarshal([]byte(`{"manifest_hash": "sha256:m",
+		"packages": {"1": {"id": "1", "name": "openssl", "version": "1.0.1t"}},
+		"distributions": {"d": {"did": "debian", "version_id": "8"}},
+		"environments": {"1": [{"introduced_in": "sha256:a", "distribution_id": "d"}]},
+		"vulnerabilities": {"v": {"name": "CVE-1", "links": "https://a https://b", "normalized_severity": "High", "fixed_in_version": "1.0.1u"}},
+		"package_vulnerabilities": {"1": ["v"]}}`), &report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	layer := vulnerabilityReportToLayer(report, map[string]string{"sha256:a": "base"})
+	if len(layer.Features) != 1 || layer.Features[0].AddedBy != "base" || layer.Features[0].NamespaceName != "debian:8" {
+		t.Fatalf("Expected openssl added by base in debian:8, got %v", layer.Features)
+	}
+	vulnerability := layer.Features[0].Vulnerabilities[0]
+	if vulnerability.Link != "https://a" || vulnerability.Severity != "High" || vulnerability.FixedBy != "1.0.1u" {
+		t.Errorf("Expected the vulnerability with its first link, severity and fixed version, got %v", vulnerability)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/olekukonko/tablewriter"
+)
+
+// affectedVulnerability is a vulnerability with the containers running an image it is found in
+type affectedVulnerability struct {
+	Vulnerability string
+	Severity      string
+	Containers    []string
+}
+
+// listContainerImages returns the images of the running containers of the Docker daemon, of all containers when asked for, with the containers of every image
+func listContainerImages(all bool) ([]string, map[string][]string) {
+	containers, err := createDockerClient().ContainerList(context.Background(), types.ContainerListOptions{All: all})
+	if err != nil {
+		logger.Fatalf("Could not list Docker containers: %v%s", err, explainDockerError("", err))
+	}
+	return containerImages(containers)
+}
+
+// containerImages returns every image of the containers once, with the names of the containers of every image,
+// Docker gives the image ID instead of the image name when the name now refers to another image, so the image the container runs is scanned
+func containerImages(containers []types.Container) ([]string, map[string][]string) {
+	var images []string
+	imageContainers := make(map[string][]string)
+	for _, container := range containers {
+		if _, exists := imageContainers[container.Image]; !exists {
+			images = append(images, container.Image)
+		}
+		name := container.ID
+		if len(name) > 12 {
+			name = name[:12]
+		}
+		if len(container.Names) > 0 {
+			name = strings.TrimPrefix(container.Names[0], "/")
+		}
+		imageContainers[container.Image] = append(imageContainers[container.Image], name)
+	}
+	return images, imageContainers
+}
+
+// affectedContainers returns the containers every vulnerability of the reports is found in, from the most severe vulnerability,
+// only the unapproved vulnerabilities unless all vulnerabilities are reported
+func affectedContainers(reports []*vulnerabilityReport, reportAll bool) []affectedVulnerability {
+	affected := make(map[string]*affectedVulnerability)
+	var order []string
+	for _, report := range reports {
+		if report == nil {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, vulnerability := range report.Vulnerabilities {
+			if seen[vulnerability.Vulnerability] || (!reportAll && vulnerability.Status == "Approved") {
+				continue
+			}
+			seen[vulnerability.Vulnerability] = true
+			if _, exists := affected[vulnerability.Vulnerability]; !exists {
+				affected[vulnerability.Vulnerability] = &affectedVulnerability{Vulnerability: vulnerability.Vulnerability, Severity: vulnerability.Severity}
+				order = append(order, vulnerability.Vulnerability)
+			}
+			affected[vulnerability.Vulnerability].Containers = append(affected[vulnerability.Vulnerability].Containers, report.Containers...)
+		}
+	}
+	vulnerabilities := make([]affectedVulnerability, 0, len(order))
+	for _, cve := range order {
+		sort.Strings(affected[cve].Containers)
+		vulnerabilities = append(vulnerabilities, *affected[cve])
+	}
+	sort.SliceStable(vulnerabilities, func(i, j int) bool {
+		return SeverityMap[vulnerabilities[i].Severity] < SeverityMap[vulnerabilities[j].Severity]
+	})
+	return vulnerabilities
+}
+
+// affectedToConsole prints the containers, or workloads, every vulnerability is found in
+func affectedToConsole(vulnerabilities []affectedVulnerability, header string) {
+	if len(vulnerabilities) == 0 {
+		logger.Infof("No vulnerabilities found in the images of the %s", strings.ToLower(header))
+		return
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"CVE", "Severity", header})
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetRowLine(true)
+	for _, vulnerability := range vulnerabilities {
+		table.Append([]string{vulnerability.Vulnerability, vulnerability.Severity, strings.Join(vulnerability.Containers, "\n")})
+	}
+	table.Render()
+}
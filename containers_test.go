@@ -0,0 +1,44 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestContainerImages(t *testing.T) {
+	containers := []types.Container{
+		{ID: "aaaaaaaaaaaaaaaa", Names: []string{"/api-1"}, Image: "myorg/api:1.0"},
+		{ID: "bbbbbbbbbbbbbbbb", Names: []string{"/api-2"}, Image: "myorg/api:1.0"},
+		{ID: "cccccccccccccccc", Image: "sha256:3f57d9401f8d"},
+	}
+	images, imageContainers := containerImages(containers)
+	if !reflect.DeepEqual(images, []string{"myorg/api:1.0", "sha256:3f57d9401f8d"}) {
+		t.Errorf("Expected every image once, got %v", images)
+	}
+	if !reflect.DeepEqual(imageContainers["myorg/api:1.0"], []string{"api-1", "api-2"}) || !reflect.DeepEqual(imageContainers["sha256:3f57d9401f8d"], []string{"cccccccccccc"}) {
+		t.Errorf("Expected the containers of every image, got %v", imageContainers)
+	}
+}
+
+func TestAffectedContainers(t *testing.T) {
+	reports := []*vulnerabilityReport{
+		{Containers: []string{"api-1", "api-2"}, Vulnerabilities: []vulnerabilityInfo{
+			{Vulnerability: "CVE-2021-1", Severity: "Low", Status: "Unapproved"},
+			{Vulnerability: "CVE-2021-1", Severity: "Low", Status: "Unapproved"},
+			{Vulnerability: "CVE-2021-2", Severity: "High", Status: "Approved"},
+		}},
+		{Containers: []string{"worker"}, Vulnerabilities: []vulnerabilityInfo{
+			{Vulnerability: "CVE-2021-1", Severity: "Low", Status: "Unapproved"},
+		}},
+		nil,
+	}
+	expected := []affectedVulnerability{{Vulnerability: "CVE-2021-1", Severity: "Low", Containers: []string{"api-1", "api-2", "worker"}}}
+	if affected := affectedContainers(reports, false); !reflect.DeepEqual(affected, expected) {
+		t.Errorf("Expected the containers of the unapproved vulnerability, got %+v", affected)
+	}
+	if affected := affectedContainers(reports, true); len(affected) != 2 || affected[0].Vulnerability != "CVE-2021-2" {
+		t.Errorf("Expected all vulnerabilities from the most severe, got %+v", affected)
+	}
+}
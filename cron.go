@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a cron schedule of minute, hour, day of month, month and day of week, or a fixed interval
+type cronSchedule struct {
+	fields [5]uint64 // bit set of the allowed values of every field
+	anyDay [2]bool   // day of month and day of week are *, cron matches either of the days when both are restricted
+	every  time.Duration
+}
+
+// cronFields are the ranges of the fields of a cron schedule
+var cronFields = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+
+// cronAliases are the shorthands of cron schedules
+var cronAliases = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+// parseSchedule parses a cron schedule like '0 3 * * *', an alias like '@daily' or an interval like '@every 6h'
+func parseSchedule(spec string) (cronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		every, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil || every < time.Minute {
+			return cronSchedule{}, fmt.Errorf("invalid interval in %q, use e.g. '@every 6h', at least a minute", spec)
+		}
+		return cronSchedule{every: every}, nil
+	}
+	if alias, exists := cronAliases[spec]; exists {
+		spec = alias
+	}
+	parts := strings.Fields(spec)
+	if len(parts) != 5 {
+		return cronSchedule{}, fmt.Errorf("schedule %q must have 5 fields: minute hour day-of-month month day-of-week", spec)
+	}
+	var schedule cronSchedule
+	for i, part := range parts {
+		bits, err := parseCronField(part, cronFields[i][0], cronFields[i][1])
+		if err != nil {
+			return cronSchedule{}, fmt.Errorf("invalid schedule %q: %v", spec, err)
+		}
+		schedule.fields[i] = bits
+	}
+	schedule.anyDay = [2]bool{strings.HasPrefix(parts[2], "*"), strings.HasPrefix(parts[4], "*")}
+	if schedule.fields[4]&(1<<7) != 0 {
+		schedule.fields[4] |= 1 // 7 is Sunday as well
+	}
+	return schedule, nil
+}
+
+// parseCronField parses a comma separated list of *, values, ranges and steps like */15 or 1-5 into a bit set
+func parseCronField(field string, min int, max int) (uint64, error) {
+	if max == 6 {
+		max = 7 // day of week allows 7 for Sunday
+	}
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(item[i+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %s", item)
+			}
+			item = item[:i]
+		}
+		low, high := min, max
+		if item != "*" {
+			bounds := strings.SplitN(item, "-", 2)
+			var err error
+			if low, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid value %s", item)
+			}
+			high = low
+			if len(bounds) == 2 {
+				if high, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid range %s", item)
+				}
+			} else if step > 1 {
+				high = max
+			}
+		}
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("%s is out of range %d-%d", item, min, max)
+		}
+		for value := low; value <= high; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+// next returns the first time of the schedule after the time
+func (schedule cronSchedule) next(after time.Time) time.Time {
+	if schedule.every > 0 {
+		return after.Add(schedule.every)
+	}
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !schedule.matches(3, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !schedule.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !schedule.matches(1, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !schedule.matches(0, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return limit // a schedule like 0 0 30 2 * never matches
+}
+
+func (schedule cronSchedule) matches(field int, value int) bool {
+	return schedule.fields[field]&(1<<uint(value)) != 0
+}
+
+// matchesDay tells whether the day matches the day of month and day of week, when both are restricted either of them has to match, as cron does
+func (schedule cronSchedule) matchesDay(t time.Time) bool {
+	dayOfMonth, dayOfWeek := schedule.matches(2, t.Day()), schedule.matches(4, int(t.Weekday()))
+	if schedule.anyDay[0] || schedule.anyDay[1] {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	after := time.Date(2024, time.January, 31, 10, 7, 30, 0, time.UTC) // a Wednesday
+	tests := map[string]time.Time{
+		"*/15 * * * *":     time.Date(2024, time.January, 31, 10, 15, 0, 0, time.UTC),
+		"0 3 * * *":        time.Date(2024, time.February, 1, 3, 0, 0, 0, time.UTC),
+		"@daily":           time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC),
+		"30 9 * * 1-5":     time.Date(2024, time.February, 1, 9, 30, 0, 0, time.UTC),
+		"0 0 * * 7":        time.Date(2024, time.February, 4, 0, 0, 0, 0, time.UTC),
+		"0 0 29 2 *":       time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC),
+		"0 12 15 * 3":      time.Date(2024, time.January, 31, 12, 0, 0, 0, time.UTC),
+		"@every 6h":        time.Date(2024, time.January, 31, 16, 7, 30, 0, time.UTC),
+		"5,10 10,11 * * *": time.Date(2024, time.January, 31, 10, 10, 0, 0, time.UTC),
+	}
+	for spec, expected := range tests {
+		schedule, err := parseSchedule(spec)
+		if err != nil {
+			t.Errorf("Expected %s to be a valid schedule, got %v", spec, err)
+			continue
+		}
+		if next := schedule.next(after); !next.Equal(expected) {
+			t.Errorf("Expected %s to run next at %s, got %s", spec, expected, next)
+		}
+	}
+}
+
+func TestParseInvalidSchedule(t *testing.T) {
+	for _, spec := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "a * * * *", "5-1 * * * *", "@every 10s", "@yearly"} {
+		if _, err := parseSchedule(spec); err == nil {
+			t.Errorf("Expected %s to be an invalid schedule", spec)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pborman/uuid"
+)
+
+const cyclonedxSpecVersion = "1.4"
+
+type cyclonedxBOM struct {
+	BOMFormat       string                   `json:"bomFormat"`
+	SpecVersion     string                   `json:"specVersion"`
+	SerialNumber    string                   `json:"serialNumber"`
+	Version         int                      `json:"version"`
+	Metadata        cyclonedxMetadata        `json:"metadata"`
+	Components      []cyclonedxComponent     `json:"components"`
+	Vulnerabilities []cyclonedxVulnerability `json:"vulnerabilities"`
+}
+
+type cyclonedxMetadata struct {
+	Timestamp string             `json:"timestamp"`
+	Tools     []cyclonedxTool    `json:"tools"`
+	Component cyclonedxComponent `json:"component"`
+}
+
+type cyclonedxTool struct {
+	Vendor  string `json:"vendor"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type cyclonedxComponent struct {
+	BOMRef  string `json:"bom-ref"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	PURL    string `json:"purl,omitempty"`
+}
+
+type cyclonedxVulnerability struct {
+	ID             string              `json:"id"`
+	Source         *cyclonedxSource    `json:"source,omitempty"`
+	Ratings        []cyclonedxRating   `json:"ratings"`
+	Description    string              `json:"description,omitempty"`
+	Recommendation string              `json:"recommendation,omitempty"`
+	Advisories     []cyclonedxAdvisory `json:"advisories,omitempty"`
+	Affects        []cyclonedxAffect   `json:"affects"`
+}
+
+type cyclonedxSource struct {
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+type cyclonedxRating struct {
+	Source   *cyclonedxSource `json:"source,omitempty"`
+	Score    float64          `json:"score,omitempty"`
+	Severity string           `json:"severity"`
+	Method   string           `json:"method"`
+	Vector   string           `json:"vector,omitempty"`
+}
+
+type cyclonedxAdvisory struct {
+	URL string `json:"url"`
+}
+
+type cyclonedxAffect struct {
+	Ref string `json:"ref"`
+}
+
+// cyclonedxSeverities maps a CVE severity to a CycloneDX severity
+var cyclonedxSeverities = map[string]string{
+	"Defcon1":    "critical",
+	"Critical":   "critical",
+	"High":       "high",
+	"Medium":     "medium",
+	"Low":        "low",
+	"Negligible": "info",
+	"Unknown":    "unknown",
+}
+
+// purlTypes maps the distribution of a Clair namespace to the package URL type of its packages
+var purlTypes = map[string]string{
+	"debian": "deb",
+	"ubuntu": "deb",
+	"alpine": "apk",
+	"centos": "rpm",
+	"rhel":   "rpm",
+	"oracle": "rpm",
+	"amzn":   "rpm",
+}
+
+// packageURL creates the package URL (purl) of a feature, namespaces look like debian:9 or alpine:v3.5
+func packageURL(name string, version string, namespace string) string {
+	name, version = url.PathEscape(name), url.PathEscape(version)
+	distro := strings.SplitN(namespace, ":", 2)
+	purlType, exists := purlTypes[distro[0]]
+	if !exists {
+		return "pkg:generic/" + name + "@" + version
+	}
+	purl := "pkg:" + purlType + "/" + distro[0] + "/" + name + "@" + version
+	if len(distro) == 2 {
+		purl += "?distro=" + distro[0] + "-" + distro[1]
+	}
+	return purl
+}
+
+// writeCycloneDXReport writes the features and their vulnerabilities as a CycloneDX BOM
+func writeCycloneDXReport(w io.Writer, report *vulnerabilityReport) error {
+	bom := cyclonedxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  cyclonedxSpecVersion,
+		SerialNumber: "urn:uuid:" + uuid.New(),
+		Version:      1,
+		Metadata: cyclonedxMetadata{
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Tools:     []cyclonedxTool{{Vendor: toolName, Name: toolName, Version: version}},
+			Component: cyclonedxComponent{BOMRef: report.Image, Type: "container", Name: report.Image},
+		},
+		Components:      []cyclonedxComponent{},
+		Vulnerabilities: []cyclonedxVulnerability{},
+	}
+
+	refs := make(map[string]bool)
+	for _, feature := range report.Features {
+		purl := packageURL(feature.Name, feature.Version, feature.Namespace)
+		if refs[purl] {
+			continue
+		}
+		refs[purl] = true
+		bom.Components = append(bom.Components, cyclonedxComponent{
+			BOMRef:  purl,
+			Type:    "library",
+			Name:    feature.Name,
+			Version: feature.Version,
+			PURL:    purl,
+		})
+	}
+
+	vulnerabilityIndexes := make(map[string]int)
+	for _, vulnerability := range report.Vulnerabilities {
+		ref := cyclonedxAffect{Ref: packageURL(vulnerability.FeatureName, vulnerability.FeatureVersion, vulnerability.Namespace)}
+		if index, exists := vulnerabilityIndexes[vulnerability.Vulnerability]; exists {
+			bom.Vulnerabilities[index].Affects = append(bom.Vulnerabilities[index].Affects, ref)
+			continue
+		}
+		vulnerabilityIndexes[vulnerability.Vulnerability] = len(bom.Vulnerabilities)
+
+		severity, exists := cyclonedxSeverities[vulnerability.Severity]
+		if !exists {
+			severity = "unknown"
+		}
+		cyclonedx := cyclonedxVulnerability{
+			ID:          vulnerability.Vulnerability,
+			Ratings:     []cyclonedxRating{{Severity: severity, Method: "other"}},
+			Description: vulnerability.Description,
+			Affects:     []cyclonedxAffect{ref},
+		}
+		if vulnerability.Namespace != "" {
+			cyclonedx.Source = &cyclonedxSource{Name: vulnerability.Namespace, URL: vulnerability.Link}
+		}
+		if vulnerability.Link != "" {
+			cyclonedx.Advisories = []cyclonedxAdvisory{{URL: vulnerability.Link}}
+		}
+		if vulnerability.FixedBy != "" {
+			cyclonedx.Recommendation = "Upgrade " + vulnerability.FeatureName + " to " + vulnerability.FixedBy
+		}
+		if vulnerability.CVSSScore > 0 {
+			cyclonedx.Ratings = append(cyclonedx.Ratings, cyclonedxRating{
+				Source:   &cyclonedxSource{Name: "NVD", URL: nvdURL + vulnerability.Vulnerability},
+				Score:    vulnerability.CVSSScore,
+				Severity: severity,
+				Method:   "CVSSv" + vulnerability.CVSSVersion,
+				Vector:   vulnerability.CVSSVector,
+			})
+		}
+		bom.Vulnerabilities = append(bom.Vulnerabilities, cyclonedx)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(bom)
+}
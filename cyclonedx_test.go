@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"testing"
+)
+
+func TestPackageURL(t *testing.T) {
+	tests := map[[3]string]string{
+		{"zlib", "1.2.8-r2", "alpine:v3.5"}:     "pkg:apk/alpine/zlib@1.2.8-r2?distro=alpine-v3.5",
+		{"libc6", "2.24-11+deb9u1", "debian:9"}: "pkg:deb/debian/libc6@2.24-11+deb9u1?distro=debian-9",
+		{"left pad", "1.0", ""}:                 "pkg:generic/left%20pad@1.0",
+	}
+	for feature, expected := range tests {
+		if purl := packageURL(feature[0], feature[1], feature[2]); purl != expected {
+			t.Errorf("Expected the package URL of %v to be %s, got %s", feature, expected, purl)
+		}
+	}
+}
+
+func TestWriteCycloneDXReport(t *testing.T) {
+	report := &vulnerabilityReport{
+		Image: "alpine:3.5",
+		Features: []featureInfo{
+			{Name: "zlib", Version: "1.2.8-r2", Namespace: "alpine:v3.5"},
+			{Name: "zlib", Version: "1.2.8-r2", Namespace: "alpine:v3.5"},
+			{Name: "musl", Version: "1.1.15-r5", Namespace: "alpine:v3.5"},
+		},
+		Vulnerabilities: []vulnerabilityInfo{
+			{FeatureName: "zlib", FeatureVersion: "1.2.8-r2", Namespace: "alpine:v3.5", Vulnerability: "CVE-2016-9840", Severity: "High", FixedBy: "1.2.11-r0", CVSSVersion: "3", CVSSScore: 8.8, CVSSVector: "CVSS:3.0/AV:N/AC:L/PR:N/UI:R/S:U/C:H/I:H/A:H"},
+			{FeatureName: "musl", FeatureVersion: "1.1.15-r5", Namespace: "alpine:v3.5", Vulnerability: "CVE-2016-9840", Severity: "High"},
+		},
+	}
+
+	var buffer bytes.Buffer
+	if err := writeCycloneDXReport(&buffer, report); err != nil {
+		t.Fatalf("Could not write CycloneDX report: %v", err)
+	}
+	var bom cyclonedxBOM
+	if err := json.Unmarshal(buffer.Bytes(), &bom); err != nil {
+		t.Fatalf("CycloneDX report is not JSON: %v", err)
+	}
+	if bom.BOMFormat != "CycloneDX" || bom.SpecVersion != cyclonedxSpecVersion || bom.Version != 1 {
+		t.Errorf("Expected a CycloneDX %s BOM, got %+v", cyclonedxSpecVersion, bom)
+	}
+	if !regexp.MustCompile(`^urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`).MatchString(bom.SerialNumber) {
+		t.Errorf("Expected a urn:uuid serial number, got %s", bom.SerialNumber)
+	}
+	if bom.Metadata.Component.Type != "container" || bom.Metadata.Component.Name != "alpine:3.5" {
+		t.Errorf("Expected the image as container component, got %+v", bom.Metadata.Component)
+	}
+
+	refs := make(map[string]bool)
+	for _, component := range bom.Components {
+		if refs[component.BOMRef] {
+			t.Errorf("Expected unique bom-refs, got %s twice", component.BOMRef)
+		}
+		refs[component.BOMRef] = true
+	}
+	if len(bom.Components) != 2 || len(bom.Vulnerabilities) != 1 {
+		t.Fatalf("Expected 2 components and 1 vulnerability, got %+v", bom)
+	}
+	vulnerability := bom.Vulnerabilities[0]
+	if len(vulnerability.Affects) != 2 {
+		t.Errorf("Expected the vulnerability to affect both packages, got %+v", vulnerability.Affects)
+	}
+	for _, affect := range vulnerability.Affects {
+		if !refs[affect.Ref] {
+			t.Errorf("Expected the affected %s to be a component of the BOM", affect.Ref)
+		}
+	}
+	methods := map[string]bool{"CVSSv2": true, "CVSSv3": true, "CVSSv31": true, "OWASP": true, "other": true}
+	for _, rating := range vulnerability.Ratings {
+		if !methods[rating.Method] || rating.Severity != "high" {
+			t.Errorf("Expected a rating of a method of the schema, got %+v", rating)
+		}
+	}
+	if vulnerability.Recommendation != "Upgrade zlib to 1.2.11-r0" {
+		t.Errorf("Expected the upgrade as recommendation, got %s", vulnerability.Recommendation)
+	}
+}
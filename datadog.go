@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	datadogEventsURI = "/api/v1/events"
+	datadogSeriesURI = "/api/v1/series"
+	datadogMetric    = "clair_scanner"
+)
+
+// datadogConfig is where the events and metrics of every scan are sent to, the Datadog API with an API key or a DogStatsD agent
+type datadogConfig struct {
+	apiKey    string
+	site      string
+	dogstatsd string
+}
+
+type datadogEvent struct {
+	Title     string   `json:"title"`
+	Text      string   `json:"text"`
+	AlertType string   `json:"alert_type"`
+	Source    string   `json:"source_type_name"`
+	Tags      []string `json:"tags"`
+}
+
+// datadogMetricPoint is a gauge metric of a scan
+type datadogMetricPoint struct {
+	name  string
+	value float64
+	tags  []string
+}
+
+type datadogSeries struct {
+	Metric string       `json:"metric"`
+	Type   string       `json:"type"`
+	Points [][2]float64 `json:"points"`
+	Tags   []string     `json:"tags"`
+}
+
+// datadogTags returns the tags of the scan of the report
+func datadogTags(report *vulnerabilityReport) []string {
+	tags := []string{"image:" + report.Image}
+	if report.Platform != "" {
+		tags = append(tags, "platform:"+report.Platform)
+	}
+	return tags
+}
+
+// datadogScanEvent returns the event of the scan, an error event when it has unapproved vulnerabilities
+func datadogScanEvent(report *vulnerabilityReport) datadogEvent {
+	event := datadogEvent{
+		Title:     fmt.Sprintf("clair-scanner found %d vulnerabilities in %s", len(report.Vulnerabilities), report.Image),
+		Text:      fmt.Sprintf("%d vulnerabilities are unapproved", len(report.Unapproved)),
+		AlertType: "success",
+		Source:    "clair-scanner",
+		Tags:      datadogTags(report),
+	}
+	if len(report.Unapproved) > 0 {
+		event.AlertType = "error"
+		event.Text += ": " + strings.Join(report.Unapproved, ", ")
+	}
+	return event
+}
+
+// datadogMetrics returns the gauges of the scan: the vulnerabilities by severity, the unapproved vulnerabilities and the duration of the scan
+func datadogMetrics(report *vulnerabilityReport, duration time.Duration) []datadogMetricPoint {
+	tags := datadogTags(report)
+	entry := newHistoryEntry(report, time.Now())
+	severities := make([]string, 0, len(entry.Severities))
+	for severity := range entry.Severities {
+		severities = append(severities, severity)
+	}
+	sort.Strings(severities)
+
+	metrics := []datadogMetricPoint{}
+	for _, severity := range severities {
+		metrics = append(metrics, datadogMetricPoint{datadogMetric + ".vulnerabilities", float64(entry.Severities[severity]), append([]string{"severity:" + strings.ToLower(severity)}, tags...)})
+	}
+	return append(metrics,
+		datadogMetricPoint{datadogMetric + ".vulnerabilities.total", float64(entry.Total), tags},
+		datadogMetricPoint{datadogMetric + ".vulnerabilities.unapproved", float64(entry.Unapproved), tags},
+		datadogMetricPoint{datadogMetric + ".scan.duration", duration.Seconds(), tags},
+	)
+}
+
+// exportToDatadog sends the event and the metrics of the scan to Datadog, failures are logged as warnings
+func exportToDatadog(report *vulnerabilityReport, datadog datadogConfig) {
+	if datadog.apiKey == "" && datadog.dogstatsd == "" {
+		return
+	}
+	var duration time.Duration
+	if !report.started.IsZero() {
+		duration = time.Since(report.started)
+	}
+	event, metrics := datadogScanEvent(report), datadogMetrics(report, duration)
+
+	var err error
+	if datadog.dogstatsd != "" {
+		err = sendDogStatsD(datadog.dogstatsd, event, metrics)
+	} else {
+		err = sendDatadogAPI(datadog, event, metrics, time.Now())
+	}
+	if err != nil {
+		logger.Warnf("Could not send the scan of image [%s] to Datadog: %v", report.Image, err)
+	}
+}
+
+// sendDatadogAPI posts the event and the metrics to the Datadog API of the site
+func sendDatadogAPI(datadog datadogConfig, event datadogEvent, metrics []datadogMetricPoint, now time.Time) error {
+	apiURL := "https://api." + datadog.site
+	if strings.Contains(datadog.site, "://") {
+		apiURL = strings.TrimSuffix(datadog.site, "/")
+	}
+	headers := map[string]string{"DD-API-KEY": datadog.apiKey, "Content-Type": "application/json"}
+
+	content, _ := json.Marshal(event)
+	if _, err := upload("POST", apiURL+datadogEventsURI, content, headers); err != nil {
+		return err
+	}
+	series := make([]datadogSeries, len(metrics))
+	for i, metric := range metrics {
+		series[i] = datadogSeries{Metric: metric.name, Type: "gauge", Points: [][2]float64{{float64(now.Unix()), metric.value}}, Tags: metric.tags}
+	}
+	content, _ = json.Marshal(map[string][]datadogSeries{"series": series})
+	_, err := upload("POST", apiURL+datadogSeriesURI, content, headers)
+	return err
+}
+
+// sendDogStatsD sends the event and the metrics to a DogStatsD agent over UDP, a datagram per event and metric
+func sendDogStatsD(address string, event datadogEvent, metrics []datadogMetricPoint) error {
+	connection, err := net.Dial("udp", address)
+	if err != nil {
+		return err
+	}
+	defer connection.Close()
+
+	datagrams := []string{dogStatsDEvent(event)}
+	for _, metric := range metrics {
+		datagrams = append(datagrams, fmt.Sprintf("%s:%g|g|#%s", metric.name, metric.value, strings.Join(metric.tags, ",")))
+	}
+	for _, datagram := range datagrams {
+		if _, err = connection.Write([]byte(datagram)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dogStatsDEvent formats the event as DogStatsD datagram
+func dogStatsDEvent(event datadogEvent) string {
+	text := strings.Replace(event.Text, "\n", "\\n", -1)
+	return fmt.Sprintf("_e{%d,%d}:%s|%s|t:%s|s:%s|#%s", len(event.Title), len(text), event.Title, text, event.AlertType, event.Source, strings.Join(event.Tags, ","))
+}
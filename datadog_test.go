@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDatadogScan(t *testing.T) {
+	report := &vulnerabilityReport{Image: "alpine:3.5", Unapproved: []string{"CVE-2017-1"}, Vulnerabilities: []vulnerabilityInfo{
+		{Vulnerability: "CVE-2017-1", Severity: "High"},
+		{Vulnerability: "CVE-2017-2", Severity: "Low"},
+		{Vulnerability: "CVE-2017-3", Severity: "Low"},
+	}}
+	event := datadogScanEvent(report)
+	if event.AlertType != "error" || !strings.Contains(event.Text, "CVE-2017-1") || event.Tags[0] != "image:alpine:3.5" {
+		t.Errorf("Expected an error event with the unapproved vulnerability, got %v", event)
+	}
+
+	metrics := datadogMetrics(report, 3*time.Second)
+	values := map[string]float64{}
+	for _, metric := range metrics {
+		values[metric.name+" "+metric.tags[0]] = metric.value
+	}
+	expected := map[string]float64{
+		"clair_scanner.vulnerabilities severity:high":               1,
+		"clair_scanner.vulnerabilities severity:low":                2,
+		"clair_scanner.vulnerabilities.total image:alpine:3.5":      3,
+		"clair_scanner.vulnerabilities.unapproved image:alpine:3.5": 1,
+		"clair_scanner.scan.duration image:alpine:3.5":              3,
+	}
+	for name, value := range expected {
+		if values[name] != value {
+			t.Errorf("Expected %s to be %v, got %v", name, value, values[name])
+		}
+	}
+}
+
+func TestSendDatadogAPI(t *testing.T) {
+	requests := map[string][]byte{}
+	datadog := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("DD-API-KEY") != "secret" {
+			t.Errorf("Expected the API key to be sent, got %s", r.Header.Get("DD-API-KEY"))
+		}
+		var body json.RawMessage
+		json.NewDecoder(r.Body).Decode(&body)
+		requests[r.URL.Path] = body
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer datadog.Close()
+
+	metrics := []datadogMetricPoint{{"clair_scanner.vulnerabilities.total", 3, []string{"image:alpine:3.5"}}}
+	err := sendDatadogAPI(datadogConfig{apiKey: "secret", site: datadog.URL}, datadogEvent{Title: "scan"}, metrics, time.Unix(1506247001, 0))
+	if err != nil {
+		t.Fatalf("Expected the event and metrics to be sent, got %v", err)
+	}
+	if !strings.Contains(string(requests[datadogEventsURI]), `"title":"scan"`) {
+		t.Errorf("Expected the event to be sent, got %s", requests[datadogEventsURI])
+	}
+	if !strings.Contains(string(requests[datadogSeriesURI]), `"points":[[1506247001,3]]`) {
+		t.Errorf("Expected the metric to be sent, got %s", requests[datadogSeriesURI])
+	}
+}
+
+func TestSendDogStatsD(t *testing.T) {
+	agent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer agent.Close()
+
+	metrics := []datadogMetricPoint{{"clair_scanner.vulnerabilities.total", 3, []string{"image:alpine:3.5"}}}
+	if err = sendDogStatsD(agent.LocalAddr().String(), datadogEvent{Title: "scan", Text: "0 vulnerabilities", AlertType: "success", Source: "clair-scanner"}, metrics); err != nil {
+		t.Fatalf("Expected the datagrams to be sent, got %v", err)
+	}
+	expected := []string{"_e{4,17}:scan|0 vulnerabilities|t:success|s:clair-scanner|#", "clair_scanner.vulnerabilities.total:3|g|#image:alpine:3.5"}
+	buffer := make([]byte, 1024)
+	for _, datagram := range expected {
+		agent.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := agent.ReadFrom(buffer)
+		if err != nil || string(buffer[:n]) != datagram {
+			t.Errorf("Expected datagram %s, got %s %v", datagram, buffer[:n], err)
+		}
+	}
+}
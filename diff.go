@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+type reportDiff struct {
+	Added     []vulnerabilityInfo `json:"added"`
+	Removed   []vulnerabilityInfo `json:"removed"`
+	Unchanged []vulnerabilityInfo `json:"unchanged"`
+}
+
+// diffReports compares the vulnerabilities of two reports, a vulnerability is identified by its CVE and package name
+func diffReports(old *vulnerabilityReport, new *vulnerabilityReport) reportDiff {
+	diff := reportDiff{Added: []vulnerabilityInfo{}, Removed: []vulnerabilityInfo{}, Unchanged: []vulnerabilityInfo{}}
+
+	oldVulnerabilities := make(map[string]bool)
+	for _, vulnerability := range old.Vulnerabilities {
+		oldVulnerabilities[diffKey(vulnerability)] = true
+	}
+	newVulnerabilities := make(map[string]bool)
+	for _, vulnerability := range new.Vulnerabilities {
+		newVulnerabilities[diffKey(vulnerability)] = true
+		if oldVulnerabilities[diffKey(vulnerability)] {
+			diff.Unchanged = append(diff.Unchanged, vulnerability)
+		} else {
+			diff.Added = append(diff.Added, vulnerability)
+		}
+	}
+	for _, vulnerability := range old.Vulnerabilities {
+		if !newVulnerabilities[diffKey(vulnerability)] {
+			diff.Removed = append(diff.Removed, vulnerability)
+		}
+	}
+
+	sortBySeverity(diff.Added)
+	sortBySeverity(diff.Removed)
+	sortBySeverity(diff.Unchanged)
+	return diff
+}
+
+func diffKey(vulnerability vulnerabilityInfo) string {
+	return vulnerability.Vulnerability + " " + vulnerability.FeatureName
+}
+
+// loadReport reads a JSON report written with --report
+func loadReport(path string) *vulnerabilityReport {
+	file, err := os.Open(path)
+	if err != nil {
+		logger.Fatalf("Could not read report [%s]: %v", path, err)
+	}
+	defer file.Close()
+
+	var report vulnerabilityReport
+	if err = json.NewDecoder(file).Decode(&report); err != nil {
+		logger.Fatalf("Could not read report [%s]: report is not JSON %v", path, err)
+	}
+	return &report
+}
+
+// reportOrScan loads the report when the reference is an existing file, otherwise the reference is scanned as an image
+func reportOrScan(reference string, config scannerConfig) *vulnerabilityReport {
+	if info, err := os.Stat(reference); err == nil && !info.IsDir() {
+		return loadReport(reference)
+	}
+	config.imageName = reference
+	config.exitWhenNoFeatures = false
+	return scanImage(config)
+}
+
+// printDiff prints the added, removed and unchanged vulnerabilities
+func printDiff(old string, new string, diff reportDiff, format string) {
+	if format == "json" {
+		if err := json.NewEncoder(os.Stdout).Encode(diff); err != nil {
+			logger.Fatalf("Could not create a diff: %v", err)
+		}
+		return
+	}
+
+	logger.Infof("Comparing [%s] with [%s]: %d added, %d removed, %d unchanged vulnerabilities", old, new, len(diff.Added), len(diff.Removed), len(diff.Unchanged))
+	printDiffLines("+", ErrorColor, diff.Added)
+	printDiffLines("-", NoticeColor, diff.Removed)
+	printDiffLines(" ", "%s", diff.Unchanged)
+}
+
+// printDiffLines prints a line for every vulnerability, prefixed with the sign of the change
+func printDiffLines(sign string, color string, vulnerabilities []vulnerabilityInfo) {
+	for _, vulnerability := range vulnerabilities {
+		line := fmt.Sprintf("%s %s %s %s %s", sign, vulnerability.Severity, vulnerability.Vulnerability, vulnerability.FeatureName, vulnerability.FeatureVersion)
+		fmt.Printf(color+"\n", line)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestDiffReports(t *testing.T) {
+	old := &vulnerabilityReport{Vulnerabilities: []vulnerabilityInfo{
+		{FeatureName: "openssl", Vulnerability: "CVE-1", Severity: "High"},
+		{FeatureName: "zlib", Vulnerability: "CVE-2", Severity: "Low"},
+	}}
+	new := &vulnerabilityReport{Vulnerabilities: []vulnerabilityInfo{
+		{FeatureName: "openssl", Vulnerability: "CVE-1", Severity: "High"},
+		{FeatureName: "openssl", Vulnerability: "CVE-2", Severity: "Low"},
+	}}
+
+	diff := diffReports(old, new)
+	if len(diff.Added) != 1 || diff.Added[0].FeatureName != "openssl" || diff.Added[0].Vulnerability != "CVE-2" {
+		t.Errorf("Expected CVE-2 in openssl to be added, got %v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].FeatureName != "zlib" {
+		t.Errorf("Expected CVE-2 in zlib to be removed, got %v", diff.Removed)
+	}
+	if len(diff.Unchanged) != 1 || diff.Unchanged[0].Vulnerability != "CVE-1" {
+		t.Errorf("Expected CVE-1 to be unchanged, got %v", diff.Unchanged)
+	}
+}
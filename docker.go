@@ -3,33 +3,66 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"os"
 	"strings"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
 )
 
 // TODO Add support for older version of docker
 
 type manifestJSON struct {
-	Layers []string
+	Config   string
+	RepoTags []string `json:",omitempty"`
+	Layers   []string
 }
 
-// saveDockerImage saves Docker image to temorary folder
-func saveDockerImage(imageName string, tmpPath string) {
+type imageConfigJSON struct {
+	History []imageHistory `json:"history"`
+}
+
+type imageHistory struct {
+	CreatedBy  string `json:"created_by"`
+	EmptyLayer bool   `json:"empty_layer"`
+}
+
+// saveDockerImage saves Docker image to temorary folder, when known layers are given it is streamed and only the layers Clair still needs are saved
+func saveDockerImage(imageName string, tmpPath string, knownLayers map[string]bool) {
+	inspectDockerImage(imageName) // fails with a clear message when the image is not present
 	docker := createDockerClient()
 
 	imageReader, err := docker.ImageSave(context.Background(), []string{imageName})
 	if err != nil {
-		logger.Fatalf("Could not save Docker image [%s]: %v", imageName, err)
+		logger.Fatalf("Could not save Docker image [%s]: %v%s", imageName, err, explainDockerError(imageName, err))
 	}
 
 	defer imageReader.Close()
 
-	if err = untar(imageReader, tmpPath); err != nil {
+	if knownLayers != nil {
+		err = untarStreaming(imageReader, tmpPath, knownLayers)
+	} else {
+		err = untar(imageReader, tmpPath)
+	}
+	if err != nil {
 		logger.Fatalf("Could not save Docker image: could not untar [%s]: %v", imageName, err)
 	}
+	// Docker with the containerd image store saves the layers as OCI blobs instead of <id>/layer.tar
+	normalizeImageLayers(tmpPath)
+}
+
+// configureDockerDaemon sets the Docker daemon the client connects to, options that are not given keep the DOCKER_HOST, DOCKER_TLS_VERIFY and DOCKER_CERT_PATH environment
+func configureDockerDaemon(host string, tlsVerify bool, certPath string) {
+	for key, value := range map[string]string{"DOCKER_HOST": host, "DOCKER_CERT_PATH": certPath} {
+		if value != "" {
+			os.Setenv(key, value)
+		}
+	}
+	if tlsVerify {
+		os.Setenv("DOCKER_TLS_VERIFY", "1")
+	}
 }
 
 func createDockerClient() client.APIClient {
@@ -40,6 +73,64 @@ func createDockerClient() client.APIClient {
 	return docker
 }
 
+// inspectDockerImage inspects a local image with the Docker Engine API
+func inspectDockerImage(imageName string) types.ImageInspect {
+	image, _, err := createDockerClient().ImageInspectWithRaw(context.Background(), imageName)
+	if err != nil {
+		logger.Fatalf("Could not inspect Docker image [%s]: %v%s", imageName, err, explainDockerError(imageName, err))
+	}
+	return image
+}
+
+// explainDockerError explains how to solve common Docker errors, empty when there is no explanation
+func explainDockerError(imageName string, err error) string {
+	switch {
+	case client.IsErrImageNotFound(err):
+		return fmt.Sprintf(", the image is not present locally, pull it first with 'docker pull %s'", imageName)
+	case client.IsErrConnectionFailed(err):
+		return ", the Docker daemon is not reachable, check that it is running and that DOCKER_HOST is correct"
+	}
+	return ""
+}
+
+// getImageDigests returns the image ID and the registry digests of a local image
+func getImageDigests(imageName string) []string {
+	image := inspectDockerImage(imageName)
+	digests := []string{image.ID}
+	for _, repoDigest := range image.RepoDigests {
+		if i := strings.Index(repoDigest, "@"); i >= 0 {
+			digests = append(digests, repoDigest[i+1:])
+		}
+	}
+	return digests
+}
+
+// isDockerDesktop tells whether the Docker daemon is Docker Desktop, where containers reach the host by a host name
+func isDockerDesktop() bool {
+	info, err := createDockerClient().Info(context.Background())
+	if err != nil {
+		logger.Warnf("Could not get Docker info: %v", err)
+		return false
+	}
+	return strings.Contains(info.OperatingSystem, "Docker Desktop") || strings.Contains(info.OperatingSystem, "Docker for Mac") || strings.Contains(info.OperatingSystem, "Docker for Windows")
+}
+
+// validateDockerPlatform validates that the local image is of the platform, Docker saves only the platform that was pulled
+func validateDockerPlatform(imageName string, platform string) {
+	if platform == "" {
+		return
+	}
+	image := inspectDockerImage(imageName)
+	if !matchesPlatform(&ociPlatform{OS: image.Os, Architecture: image.Architecture}, strings.Join(strings.Split(platform, "/")[:2], "/")) {
+		logger.Fatalf("Could not save Docker image [%s]: the local image is %s/%s, pull the %s image first with 'docker pull --platform %s %s'", imageName, image.Os, image.Architecture, platform, platform, imageName)
+	}
+}
+
+// getImageDiffIDs returns the digests of the uncompressed layers of a local image
+func getImageDiffIDs(imageName string) []string {
+	return inspectDockerImage(imageName).RootFS.Layers
+}
+
 // getImageLayerIds reads LayerIDs from the manifest.json file
 func getImageLayerIds(path string) []string {
 	manifest := readManifestFile(path)
@@ -51,6 +142,23 @@ func getImageLayerIds(path string) []string {
 	return layers
 }
 
+// getImageHistory reads the build history from the image configuration file
+func getImageHistory(path string) []imageHistory {
+	manifest := readManifestFile(path)
+	configFile := path + "/" + manifest[0].Config
+	cf, err := openLayerFile(configFile)
+	if err != nil {
+		logger.Fatalf("Could not read Docker image history: could not open [%s]: %v", configFile, err)
+	}
+	defer cf.Close()
+
+	var config imageConfigJSON
+	if err = json.NewDecoder(cf).Decode(&config); err != nil {
+		logger.Fatalf("Could not read Docker image history: %s is not json: %v", manifest[0].Config, err)
+	}
+	return config.History
+}
+
 // readManifestFile reads the local manifest.json
 func readManifestFile(path string) []manifestJSON {
 	manifestFile := path + "/manifest.json"
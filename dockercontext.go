@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+type dockerConfigJSON struct {
+	CurrentContext string `json:"currentContext"`
+}
+
+type dockerContextMeta struct {
+	Name      string `json:"Name"`
+	Endpoints map[string]struct {
+		Host          string `json:"Host"`
+		SkipTLSVerify bool   `json:"SkipTLSVerify"`
+	} `json:"Endpoints"`
+}
+
+// dockerConfigDir returns the configuration folder of the docker CLI
+func dockerConfigDir() string {
+	if configDir := os.Getenv("DOCKER_CONFIG"); configDir != "" {
+		return configDir
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".docker")
+}
+
+// currentDockerContext returns the docker context selected with DOCKER_CONTEXT or 'docker context use', empty for the default context
+func currentDockerContext(configDir string) string {
+	if name := os.Getenv("DOCKER_CONTEXT"); name != "" {
+		return name
+	}
+	content, err := ioutil.ReadFile(filepath.Join(configDir, "config.json"))
+	if err != nil {
+		return ""
+	}
+	var config dockerConfigJSON
+	if err = json.Unmarshal(content, &config); err != nil {
+		logger.Warnf("Could not read the docker context, %s/config.json is not json: %v", configDir, err)
+		return ""
+	}
+	return config.CurrentContext
+}
+
+// configureDockerContext connects to the Docker daemon of the docker context, unless DOCKER_HOST is set or it is the default context
+func configureDockerContext(configDir string, name string) {
+	if name == "" || name == "default" || os.Getenv("DOCKER_HOST") != "" {
+		return
+	}
+	host, certPath, skipTLSVerify := readDockerContext(configDir, name)
+	logger.Infof("Using the Docker daemon %s of docker context %s", host, name)
+	os.Setenv("DOCKER_HOST", host)
+	if certPath != "" {
+		os.Setenv("DOCKER_CERT_PATH", certPath)
+		if !skipTLSVerify {
+			os.Setenv("DOCKER_TLS_VERIFY", "1")
+		}
+	}
+}
+
+// readDockerContext reads the Docker endpoint of the docker context from the context store, and the folder with its TLS files when it has any
+func readDockerContext(configDir string, name string) (string, string, bool) {
+	id := sha256.Sum256([]byte(name))
+	contextID := hex.EncodeToString(id[:])
+	content, err := ioutil.ReadFile(filepath.Join(configDir, "contexts", "meta", contextID, "meta.json"))
+	if err != nil {
+		logger.Fatalf("Could not read docker context %s: %v", name, err)
+	}
+	var meta dockerContextMeta
+	if err = json.Unmarshal(content, &meta); err != nil {
+		logger.Fatalf("Could not read docker context %s: meta.json is not json: %v", name, err)
+	}
+	endpoint, exists := meta.Endpoints["docker"]
+	if !exists || endpoint.Host == "" {
+		logger.Fatalf("Could not read docker context %s: it has no Docker endpoint", name)
+	}
+
+	certPath := filepath.Join(configDir, "contexts", "tls", contextID, "docker")
+	if _, err = os.Stat(certPath); err != nil {
+		certPath = ""
+	}
+	return endpoint.Host, certPath, endpoint.SkipTLSVerify
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadDockerContext(t *testing.T) {
+	initializeLogger("")
+	configDir := createTmpPath("docker-config")
+	defer os.RemoveAll(configDir)
+	id := sha256.Sum256([]byte("remote"))
+	contextID := hex.EncodeToString(id[:])
+	metaPath := filepath.Join(configDir, "contexts", "meta", contextID)
+	tlsPath := filepath.Join(configDir, "contexts", "tls", contextID, "docker")
+	for _, path := range []string{metaPath, tlsPath} {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	meta := `{"Name": "remote", "Endpoints": {"docker": {"Host": "tcp://docker:2376", "SkipTLSVerify": true}}}`
+	if err := ioutil.WriteFile(filepath.Join(metaPath, "meta.json"), []byte(meta), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	host, certPath, skipTLSVerify := readDockerContext(configDir, "remote")
+	if host != "tcp://docker:2376" || certPath != tlsPath || !skipTLSVerify {
+		t.Errorf("Expected tcp://docker:2376 %s true, got %s %s %v", tlsPath, host, certPath, skipTLSVerify)
+	}
+}
+
+func TestCurrentDockerContext(t *testing.T) {
+	initializeLogger("")
+	configDir := createTmpPath("docker-config")
+	defer os.RemoveAll(configDir)
+	os.Unsetenv("DOCKER_CONTEXT")
+	if name := currentDockerContext(configDir); name != "" {
+		t.Errorf("Expected no docker context without config.json, got %s", name)
+	}
+	if err := ioutil.WriteFile(filepath.Join(configDir, "config.json"), []byte(`{"currentContext": "remote"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if name := currentDockerContext(configDir); name != "remote" {
+		t.Errorf("Expected docker context remote, got %s", name)
+	}
+	os.Setenv("DOCKER_CONTEXT", "other")
+	defer os.Unsetenv("DOCKER_CONTEXT")
+	if name := currentDockerContext(configDir); name != "other" {
+		t.Errorf("Expected DOCKER_CONTEXT to override the current context, got %s", name)
+	}
+}
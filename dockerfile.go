@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type dockerfileInstruction struct {
+	Line        int
+	Instruction string
+	Text        string
+}
+
+// String formats the instruction with its line number, e.g. Dockerfile:12 RUN apt-get install -y curl
+func (instruction dockerfileInstruction) String() string {
+	return "Dockerfile:" + strconv.Itoa(instruction.Line) + " " + instruction.Text
+}
+
+// layerInstructions are the Dockerfile instructions that create a layer
+var layerInstructions = map[string]bool{"RUN": true, "COPY": true, "ADD": true}
+
+// parseDockerfile reads the instructions of a Dockerfile, joining continuation lines
+func parseDockerfile(path string) []dockerfileInstruction {
+	file, err := os.Open(path)
+	if err != nil {
+		logger.Fatalf("Could not read Dockerfile: %v", err)
+	}
+	defer file.Close()
+
+	var instructions []dockerfileInstruction
+	var current *dockerfileInstruction
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		continues := strings.HasSuffix(line, "\\")
+		line = strings.TrimSpace(strings.TrimSuffix(line, "\\"))
+
+		if current == nil {
+			fields := strings.Fields(line)
+			current = &dockerfileInstruction{Line: lineNumber, Instruction: strings.ToUpper(fields[0]), Text: line}
+		} else if line != "" {
+			current.Text += " " + line
+		}
+		if !continues {
+			instructions = append(instructions, *current)
+			current = nil
+		}
+	}
+	if current != nil {
+		instructions = append(instructions, *current)
+	}
+	if err = scanner.Err(); err != nil {
+		logger.Fatalf("Could not read Dockerfile: %v", err)
+	}
+	return instructions
+}
+
+// attributeLayersToDockerfile maps every layer to the Dockerfile instruction that created it.
+// The last layers of the image are created by the RUN, COPY and ADD instructions of the final build stage,
+// all layers before those come from the base image and are attributed to the final FROM instruction.
+func attributeLayersToDockerfile(layers []string, history []imageHistory, instructions []dockerfileInstruction) map[string]dockerfileInstruction {
+	var from dockerfileInstruction
+	var stage []dockerfileInstruction
+	for _, instruction := range instructions {
+		if instruction.Instruction == "FROM" {
+			from = instruction
+			stage = nil
+		} else if layerInstructions[instruction.Instruction] {
+			stage = append(stage, instruction)
+		}
+	}
+
+	createdBy := make([]string, 0, len(layers))
+	for _, entry := range history {
+		if !entry.EmptyLayer {
+			createdBy = append(createdBy, entry.CreatedBy)
+		}
+	}
+	if len(stage) > len(layers) || (len(createdBy) > 0 && len(createdBy) != len(layers)) {
+		logger.Warnf("Could not map the image layers to the Dockerfile, the image has %d layers for %d Dockerfile instructions", len(layers), len(stage))
+		return nil
+	}
+
+	attribution := make(map[string]dockerfileInstruction, len(layers))
+	base := len(layers) - len(stage)
+	for i, layer := range layers {
+		if i < base {
+			attribution[layer] = from
+			continue
+		}
+		instruction := stage[i-base]
+		if len(createdBy) > 0 && !createdByInstruction(createdBy[i], instruction.Instruction) {
+			logger.Warnf("Could not map the image layers to the Dockerfile, layer %s was not created by %s", shortLayerID(layer), instruction)
+			return nil
+		}
+		attribution[layer] = instruction
+	}
+	return attribution
+}
+
+// markDockerfileInstructions sets the Dockerfile instruction that created the layer of every vulnerability
+func markDockerfileInstructions(vulnerabilities []vulnerabilityInfo, attribution map[string]dockerfileInstruction) {
+	for i := range vulnerabilities {
+		if instruction, exists := attribution[vulnerabilities[i].AddedBy]; exists {
+			vulnerabilities[i].Instruction, vulnerabilities[i].InstructionLine = instruction.Text, instruction.Line
+		}
+	}
+}
+
+// createdByInstruction tells if the created_by history of a layer matches the Dockerfile instruction.
+// The classic builder records RUN as "/bin/sh -c <command>" and COPY/ADD as "/bin/sh -c #(nop) COPY ...",
+// BuildKit records the instruction itself followed by "# buildkit".
+func createdByInstruction(createdBy string, instruction string) bool {
+	createdBy = strings.TrimSpace(strings.TrimPrefix(createdBy, "/bin/sh -c"))
+	createdBy = strings.TrimSpace(strings.TrimPrefix(createdBy, "#(nop)"))
+	fields := strings.Fields(createdBy)
+	if len(fields) > 0 && (fields[0] == "COPY" || fields[0] == "ADD") {
+		return fields[0] == instruction
+	}
+	return instruction == "RUN"
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestAttributeLayersToDockerfile(t *testing.T) {
+	initializeLogger("")
+	dockerfile, err := ioutil.TempFile("", "Dockerfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(dockerfile.Name())
+	dockerfile.WriteString(`FROM golang:1.14 AS build
+RUN go build
+
+# final stage
+FROM debian:jessie
+ENV DEBIAN_FRONTEND noninteractive
+RUN apt-get update && \
+    apt-get install -y curl
+COPY --from=build /app /app
+`)
+	dockerfile.Close()
+
+	instructions := parseDockerfile(dockerfile.Name())
+	if len(instructions) != 6 || instructions[4].Line != 7 || instructions[4].Text != "RUN apt-get update && apt-get install -y curl" {
+		t.Fatalf("Unexpected Dockerfile instructions %+v", instructions)
+	}
+
+	history := []imageHistory{
+		{CreatedBy: "/bin/sh -c #(nop) ADD file:4eedf861fb567ff in / "},
+		{CreatedBy: "/bin/sh -c #(nop)  CMD [\"bash\"]", EmptyLayer: true},
+		{CreatedBy: "/bin/sh -c #(nop)  ENV DEBIAN_FRONTEND=noninteractive", EmptyLayer: true},
+		{CreatedBy: "/bin/sh -c apt-get update && apt-get install -y curl"},
+		{CreatedBy: "COPY /app /app # buildkit"},
+	}
+	attribution := attributeLayersToDockerfile([]string{"base", "run", "copy"}, history, instructions)
+	expected := map[string]int{"base": 5, "run": 7, "copy": 9}
+	for layer, line := range expected {
+		if attribution[layer].Line != line {
+			t.Errorf("Expected layer %s to be created on line %d, got %+v", layer, line, attribution[layer])
+		}
+	}
+
+	history[3].CreatedBy = "/bin/sh -c #(nop) COPY file:abc in /"
+	if attributeLayersToDockerfile([]string{"base", "run", "copy"}, history, instructions) != nil {
+		t.Errorf("Expected no attribution when the history does not match the Dockerfile")
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+const (
+	ecrTarget = "AmazonEC2ContainerRegistry_V20150921.GetAuthorizationToken"
+	// awsCredentialsTimeout bounds looking up the AWS credentials, so the instance metadata service isn't waited for outside of EC2
+	awsCredentialsTimeout = 30 * time.Second
+)
+
+// ecrEndpoint is the API endpoint of ECR in a region
+var ecrEndpoint = "https://api.ecr.%s.amazonaws.com"
+
+var ecrRegistryPattern = regexp.MustCompile(`^(\d{12})\.dkr\.ecr(-fips)?\.([a-z0-9-]+)\.amazonaws\.com(\.cn)?$`)
+
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// ecrCredentials exchanges the AWS credentials of the standard credential chain for credentials of an ECR registry, false when the registry is not ECR or there are no AWS credentials
+func ecrCredentials(registry string) (registryCredentials, bool) {
+	match := ecrRegistryPattern.FindStringSubmatch(registry)
+	if match == nil {
+		return registryCredentials{}, false
+	}
+	credentials, found := awsCredentialChain(match[3])
+	if !found {
+		logger.Warnf("Could not find AWS credentials for ECR registry %s, falling back to the docker credentials", registry)
+		return registryCredentials{}, false
+	}
+
+	endpoint := fmt.Sprintf(ecrEndpoint, match[3])
+	if match[4] != "" {
+		endpoint += ".cn"
+	}
+	token, err := getECRAuthorizationToken(endpoint, match[3], match[1], credentials, time.Now().UTC())
+	if err != nil {
+		logger.Fatalf("Could not get an ECR authorization token for %s: %v", registry, err)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(token)
+	parts := strings.SplitN(string(decoded), ":", 2)
+	if err != nil || len(parts) != 2 {
+		logger.Fatalf("Could not get an ECR authorization token for %s: the token is not base64 encoded user:password", registry)
+	}
+	return registryCredentials{user: parts[0], password: parts[1]}, true
+}
+
+// getECRAuthorizationToken calls GetAuthorizationToken of the ECR API for the registry of the account, signed with AWS Signature Version 4
+func getECRAuthorizationToken(endpoint string, region string, account string, credentials awsCredentials, now time.Time) (string, error) {
+	payload := []byte(`{"registryIds":["` + account + `"]}`)
+	request, err := http.NewRequest("POST", endpoint+"/", strings.NewReader(string(payload)))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	request.Header.Set("X-Amz-Target", ecrTarget)
+	signAWSRequest(request, payload, region, "ecr", credentials, now)
+
+	response, err := (&http.Client{Transport: newTransport()}).Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(response.Body)
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("ECR responded %d with message %s", response.StatusCode, string(body))
+	}
+	var result struct {
+		AuthorizationData []struct {
+			AuthorizationToken string `json:"authorizationToken"`
+		} `json:"authorizationData"`
+	}
+	if err = json.Unmarshal(body, &result); err != nil || len(result.AuthorizationData) == 0 {
+		return "", fmt.Errorf("ECR returned no authorization data: %s", string(body))
+	}
+	return result.AuthorizationData[0].AuthorizationToken, nil
+}
+
+// signAWSRequest adds the AWS Signature Version 4 headers to the request
+func signAWSRequest(request *http.Request, payload []byte, region string, service string, credentials awsCredentials, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	request.Header.Set("X-Amz-Date", amzDate)
+	if credentials.SessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	headers := map[string]string{"host": request.URL.Host}
+	for key := range request.Header {
+		headers[strings.ToLower(key)] = strings.TrimSpace(request.Header.Get(key))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := request.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{request.Method, path, request.URL.RawQuery, canonicalHeaders.String(), signedHeaders, sha256Hex(payload)}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := []byte("AWS4" + credentials.SecretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	request.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+credentials.AccessKeyID+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// awsCredentialChain finds AWS credentials with the default credential chain of the AWS SDK: environment variables, the profile of the shared config and
+// credentials files with its assumed role, SSO session or credential process, web identity token, ECS container credentials and EC2 instance metadata.
+// The region is used when the configuration has none, false when there are no AWS credentials
+func awsCredentialChain(region string) (awsCredentials, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), awsCredentialsTimeout)
+	defer cancel()
+	//The buildable client keeps the CA bundle of AWS_CA_BUNDLE or the profile working, with the proxy of the scanner
+	client := awshttp.NewBuildableClient().WithTransportOptions(func(transport *http.Transport) {
+		transport.Proxy = newTransport().Proxy
+	})
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithDefaultRegion(region), awsconfig.WithHTTPClient(client))
+	if err != nil {
+		logger.Fatalf("Could not load the AWS configuration: %v", err)
+	}
+	credentials, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		//A profile that is asked for must resolve, without profile there may be no AWS credentials at all
+		if profile := os.Getenv("AWS_PROFILE"); profile != "" {
+			logger.Fatalf("Could not get the AWS credentials of profile %s: %v", profile, err)
+		}
+		logger.Debugf("No AWS credentials found: %v", err)
+		return awsCredentials{}, false
+	}
+	return awsCredentials{AccessKeyID: credentials.AccessKeyID, SecretAccessKey: credentials.SecretAccessKey, SessionToken: credentials.SessionToken}, true
+}
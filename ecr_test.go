@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAWSRequest(t *testing.T) {
+	// get-vanilla of the AWS Signature Version 4 test suite
+	request, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	credentials := awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signAWSRequest(request, nil, "us-east-1", "service", credentials, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if authorization := request.Header.Get("Authorization"); authorization != expected {
+		t.Errorf("Expected authorization %s, got %s", expected, authorization)
+	}
+}
+
+func TestGetECRAuthorizationToken(t *testing.T) {
+	initializeLogger("")
+	token := base64.StdEncoding.EncodeToString([]byte("AWS:password"))
+	ecr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get("X-Amz-Target") != ecrTarget || !strings.Contains(string(body), "123456789012") || !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"authorizationData": [{"authorizationToken": "` + token + `"}]}`))
+	}))
+	defer ecr.Close()
+
+	result, err := getECRAuthorizationToken(ecr.URL, "eu-west-1", "123456789012", awsCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, time.Now())
+	if err != nil || result != token {
+		t.Errorf("Expected the authorization token, got %s %v", result, err)
+	}
+	if match := ecrRegistryPattern.FindStringSubmatch("123456789012.dkr.ecr.eu-west-1.amazonaws.com"); match == nil || match[1] != "123456789012" || match[3] != "eu-west-1" {
+		t.Errorf("Expected the account and region of the ECR registry, got %v", match)
+	}
+}
+
+func TestAWSCredentialChain(t *testing.T) {
+	initializeLogger("")
+	tmpPath := createTmpPath("aws")
+	defer os.RemoveAll(tmpPath)
+	for _, variable := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_PROFILE", "AWS_REGION", "AWS_WEB_IDENTITY_TOKEN_FILE",
+		"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "AWS_CONTAINER_CREDENTIALS_FULL_URI", "AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"} {
+		t.Setenv(variable, "")
+	}
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(tmpPath, "credentials"))
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(tmpPath, "config"))
+	ioutil.WriteFile(filepath.Join(tmpPath, "credentials"), []byte("[default]\naws_access_key_id = DEFAULT\naws_secret_access_key = secret\n"), 0600)
+	ioutil.WriteFile(filepath.Join(tmpPath, "config"), []byte(`[profile ci]
+credential_process = echo '{"Version": 1, "AccessKeyId": "PROCESS", "SecretAccessKey": "process-secret", "SessionToken": "session"}'
+`), 0600)
+
+	if credentials, found := awsCredentialChain("eu-west-1"); !found || credentials.AccessKeyID != "DEFAULT" {
+		t.Errorf("Expected the credentials of the default profile, got %v", credentials)
+	}
+	t.Setenv("AWS_PROFILE", "ci")
+	if credentials, found := awsCredentialChain("eu-west-1"); !found || credentials != (awsCredentials{AccessKeyID: "PROCESS", SecretAccessKey: "process-secret", SessionToken: "session"}) {
+		t.Errorf("Expected the credentials of the credential process of the ci profile, got %v", credentials)
+	}
+
+	t.Setenv("AWS_PROFILE", "missing")
+	logger.recoverFatal = true
+	func() {
+		defer func() {
+			logger.recoverFatal = false
+			if _, failed := recover().(scanFailure); !failed {
+				t.Errorf("Expected a profile that doesn't exist to fail the scan")
+			}
+		}()
+		awsCredentialChain("eu-west-1")
+	}()
+
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(tmpPath, "missing"))
+	ioutil.WriteFile(filepath.Join(tmpPath, "token"), []byte("container-token"), 0600)
+	container := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "container-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"AccessKeyId": "CONTAINER", "SecretAccessKey": "container-secret", "Token": "session", "Expiration": "` + time.Now().Add(time.Hour).UTC().Format(time.RFC3339) + `"}`))
+	}))
+	defer container.Close()
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", container.URL+"/credentials")
+	t.Setenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE", filepath.Join(tmpPath, "token"))
+	if credentials, found := awsCredentialChain("eu-west-1"); !found || credentials.AccessKeyID != "CONTAINER" {
+		t.Errorf("Expected the container credentials, got %v", credentials)
+	}
+
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", "")
+	if _, found := awsCredentialChain("eu-west-1"); found {
+		t.Errorf("Expected no credentials without any source")
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pborman/uuid"
+)
+
+// elasticsearchBatchSize is the number of findings indexed per bulk request
+const elasticsearchBatchSize = 500
+
+// elasticsearchConfig is the Elasticsearch or OpenSearch cluster the findings of every scan are indexed in
+type elasticsearchConfig struct {
+	url    string
+	index  string
+	apiKey string
+}
+
+type elasticsearchBulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// exportToElasticsearch indexes every vulnerability of the report as a document with the bulk API, failures are logged as warnings
+func exportToElasticsearch(report *vulnerabilityReport, elasticsearch elasticsearchConfig) {
+	if elasticsearch.url == "" {
+		return
+	}
+	findings := reportFindings(report, uuid.New(), time.Now())
+	for start := 0; start < len(findings); start += elasticsearchBatchSize {
+		end := start + elasticsearchBatchSize
+		if end > len(findings) {
+			end = len(findings)
+		}
+		if err := bulkIndex(elasticsearch, findings[start:end]); err != nil {
+			logger.Warnf("Could not index the vulnerabilities of image [%s] in Elasticsearch: %v", report.Image, err)
+			return
+		}
+	}
+	logger.Infof("Indexed %d vulnerabilities of image [%s] in Elasticsearch index %s", len(findings), report.Image, elasticsearch.index)
+}
+
+// bulkIndex indexes the findings with a single request to the bulk API
+func bulkIndex(elasticsearch elasticsearchConfig, findings []finding) error {
+	var body bytes.Buffer
+	action, _ := json.Marshal(map[string]map[string]string{"index": {"_index": elasticsearch.index}})
+	for _, finding := range findings {
+		document, err := json.Marshal(finding)
+		if err != nil {
+			return err
+		}
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(document)
+		body.WriteByte('\n')
+	}
+
+	headers := map[string]string{"Content-Type": "application/x-ndjson"}
+	if elasticsearch.apiKey != "" {
+		headers["Authorization"] = "ApiKey " + elasticsearch.apiKey
+	}
+	content, err := upload("POST", strings.TrimSuffix(elasticsearch.url, "/")+"/_bulk", body.Bytes(), headers)
+	if err != nil {
+		return err
+	}
+	var response elasticsearchBulkResponse
+	if err = json.Unmarshal(content, &response); err != nil {
+		return fmt.Errorf("bulk response is not JSON: %v", err)
+	}
+	if !response.Errors {
+		return nil
+	}
+	failed := 0
+	reason := ""
+	for _, item := range response.Items {
+		for _, result := range item {
+			if result.Status >= 300 {
+				failed++
+				reason = result.Error.Type + ": " + result.Error.Reason
+			}
+		}
+	}
+	return fmt.Errorf("%d of %d documents failed, e.g. %s", failed, len(findings), reason)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExportToElasticsearch(t *testing.T) {
+	initializeLogger("")
+	var lines []string
+	var authorization string
+	elasticsearch := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+			t.Errorf("Expected a bulk request, got %s %s", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		authorization = r.Header.Get("Authorization")
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		w.Write([]byte(`{"errors": false, "items": []}`))
+	}))
+	defer elasticsearch.Close()
+
+	report := &vulnerabilityReport{Image: "alpine:3.5", Digest: "sha256:abc", Vulnerabilities: []vulnerabilityInfo{
+		{Vulnerability: "CVE-2017-1", FeatureName: "openssl", Severity: "High", Status: "Unapproved"},
+		{Vulnerability: "CVE-2017-2", FeatureName: "musl", Severity: "Low", Status: "Approved"},
+	}}
+	exportToElasticsearch(report, elasticsearchConfig{url: elasticsearch.URL + "/", index: "scans", apiKey: "secret"})
+
+	if authorization != "ApiKey secret" {
+		t.Errorf("Expected the API key to be sent, got %s", authorization)
+	}
+	if len(lines) != 4 || lines[0] != `{"index":{"_index":"scans"}}` {
+		t.Fatalf("Expected an index action and a document for both vulnerabilities, got %v", lines)
+	}
+	var first, second map[string]interface{}
+	json.Unmarshal([]byte(lines[1]), &first)
+	json.Unmarshal([]byte(lines[3]), &second)
+	if first["image"] != "alpine:3.5" || first["vulnerability"] != "CVE-2017-1" || first["status"] != "Unapproved" || first["@timestamp"] == nil {
+		t.Errorf("Expected the document of CVE-2017-1 in alpine:3.5, got %v", first)
+	}
+	if first["scanid"] == "" || first["scanid"] != second["scanid"] {
+		t.Errorf("Expected the documents to share a scan ID, got %v and %v", first["scanid"], second["scanid"])
+	}
+}
+
+func TestBulkIndexErrors(t *testing.T) {
+	elasticsearch := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errors": true, "items": [{"index": {"status": 201}}, {"index": {"status": 400, "error": {"type": "mapper_parsing_exception", "reason": "failed to parse"}}}]}`))
+	}))
+	defer elasticsearch.Close()
+
+	err := bulkIndex(elasticsearchConfig{url: elasticsearch.URL, index: "scans"}, make([]finding, 2))
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 documents failed") || !strings.Contains(err.Error(), "mapper_parsing_exception") {
+		t.Errorf("Expected the failed document to be reported, got %v", err)
+	}
+}
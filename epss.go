@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// epssBatchSize is the number of CVEs whose scores are fetched in one request
+const epssBatchSize = 100
+
+// epssAPIURL is the EPSS API of FIRST the comma separated CVE IDs are appended to
+var epssAPIURL = "https://api.first.org/data/v1/epss?cve="
+
+type epssResponse struct {
+	Data []struct {
+		CVE        string `json:"cve"`
+		EPSS       string `json:"epss"`
+		Percentile string `json:"percentile"`
+	} `json:"data"`
+}
+
+// enrichWithEPSS adds the EPSS exploit probability and percentile to every CVE
+func enrichWithEPSS(vulnerabilities []vulnerabilityInfo) {
+	cves := []string{}
+	for _, vulnerability := range vulnerabilities {
+		if strings.HasPrefix(vulnerability.Vulnerability, "CVE-") && !contains(cves, vulnerability.Vulnerability) {
+			cves = append(cves, vulnerability.Vulnerability)
+		}
+	}
+
+	scores := make(map[string][2]float64)
+	for start := 0; start < len(cves); start += epssBatchSize {
+		end := start + epssBatchSize
+		if end > len(cves) {
+			end = len(cves)
+		}
+		if err := fetchEPSSScores(cves[start:end], scores); err != nil {
+			logger.Warnf("Could not fetch EPSS scores: %v", err)
+			return
+		}
+	}
+
+	for i := range vulnerabilities {
+		if score, exists := scores[vulnerabilities[i].Vulnerability]; exists {
+			vulnerabilities[i].EPSS, vulnerabilities[i].EPSSPercentile = score[0], score[1]
+		}
+	}
+}
+
+// fetchEPSSScores fetches the EPSS score and percentile of the CVEs from the FIRST API
+func fetchEPSSScores(cves []string, scores map[string][2]float64) error {
+	client := &http.Client{Timeout: time.Minute, Transport: newTransport()}
+	response, err := client.Get(epssAPIURL + strings.Join(cves, ","))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(response.Body)
+		return fmt.Errorf("got response %d with message %s", response.StatusCode, string(body))
+	}
+
+	var epss epssResponse
+	if err = json.NewDecoder(response.Body).Decode(&epss); err != nil {
+		return err
+	}
+	for _, data := range epss.Data {
+		score, _ := strconv.ParseFloat(data.EPSS, 64)
+		percentile, _ := strconv.ParseFloat(data.Percentile, 64)
+		scores[data.CVE] = [2]float64{score, percentile}
+	}
+	return nil
+}
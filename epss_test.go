@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEnrichWithEPSS(t *testing.T) {
+	initializeLogger("")
+	var batches [][]string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cves := strings.Split(r.URL.Query().Get("cve"), ",")
+		batches = append(batches, cves)
+		data := []string{}
+		for _, cve := range cves {
+			if cve != "CVE-2017-0002" {
+				data = append(data, fmt.Sprintf(`{"cve": %q, "epss": "0.00123", "percentile": "0.45678", "date": "2017-09-24"}`, cve))
+			}
+		}
+		if cves[0] == "CVE-2017-0001" {
+			data[0] = `{"cve": "CVE-2017-0001", "epss": "0.97465", "percentile": "0.99971", "date": "2017-09-24"}`
+		}
+		fmt.Fprintf(w, `{"status": "OK", "status-code": 200, "total": %d, "data": [%s]}`, len(data), strings.Join(data, ","))
+	}))
+	defer api.Close()
+	defer func(url string) { epssAPIURL = url }(epssAPIURL)
+	epssAPIURL = api.URL + "/?cve="
+
+	vulnerabilities := []vulnerabilityInfo{
+		{Vulnerability: "CVE-2017-0001", FeatureName: "openssl", Severity: "High"},
+		{Vulnerability: "CVE-2017-0001", FeatureName: "libssl1.0.0", Severity: "High"},
+		{Vulnerability: "CVE-2017-0002", FeatureName: "zlib", Severity: "High"},
+		{Vulnerability: "ALPINE-13661", FeatureName: "busybox", Severity: "High"},
+	}
+	for i := 3; i <= epssBatchSize+1; i++ {
+		vulnerabilities = append(vulnerabilities, vulnerabilityInfo{Vulnerability: fmt.Sprintf("CVE-2017-%04d", i), FeatureName: "musl", Severity: "High"})
+	}
+	enrichWithEPSS(vulnerabilities)
+
+	if len(batches) != 2 || len(batches[0]) != epssBatchSize || len(batches[1]) != 1 {
+		t.Fatalf("Expected the %d CVEs to be fetched in a full batch and a batch of 1, got batches of %v", epssBatchSize+1, batches)
+	}
+	for _, batch := range batches {
+		for _, cve := range batch {
+			if !strings.HasPrefix(cve, "CVE-") {
+				t.Errorf("Expected only CVEs to be fetched, got %s", cve)
+			}
+		}
+	}
+	if vulnerabilities[0].EPSS != 0.97465 || vulnerabilities[0].EPSSPercentile != 0.99971 || vulnerabilities[1].EPSS != 0.97465 {
+		t.Errorf("Expected the score and percentile of CVE-2017-0001 in both packages, got %+v and %+v", vulnerabilities[0], vulnerabilities[1])
+	}
+	if last := vulnerabilities[len(vulnerabilities)-1]; last.EPSS != 0.00123 || last.EPSSPercentile != 0.45678 {
+		t.Errorf("Expected the score of the CVE of the second batch, got %+v", last)
+	}
+	if vulnerabilities[2].EPSS != 0 || vulnerabilities[3].EPSS != 0 {
+		t.Errorf("Expected no score for a CVE FIRST doesn't know or a vulnerability without CVE ID, got %+v and %+v", vulnerabilities[2], vulnerabilities[3])
+	}
+
+	config := scannerConfig{imageName: "alpine:3.5", whitelistThreshold: "Unknown", minEPSS: 0.5}
+	unapproved := checkForUnapprovedVulnerabilities(config, vulnerabilities)
+	if !contains(unapproved, "CVE-2017-0001") || !contains(unapproved, "CVE-2017-0002") || contains(unapproved, "CVE-2017-0003") {
+		t.Errorf("Expected --min-epss to approve only the CVEs with a lower score, got %v", unapproved)
+	}
+	if vulnerabilities[2].Status != "Unapproved" {
+		t.Errorf("Expected the CVE without EPSS score not to be approved by --min-epss, got %+v", vulnerabilities[2])
+	}
+}
+
+func TestEnrichWithEPSSFailure(t *testing.T) {
+	initializeLogger("")
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+	}))
+	defer api.Close()
+	defer func(url string) { epssAPIURL = url }(epssAPIURL)
+	epssAPIURL = api.URL + "/?cve="
+
+	vulnerabilities := []vulnerabilityInfo{{Vulnerability: "CVE-2017-0001", FeatureName: "openssl"}}
+	enrichWithEPSS(vulnerabilities)
+	if vulnerabilities[0].EPSS != 0 {
+		t.Errorf("Expected a failed request to leave the vulnerabilities without score, got %+v", vulnerabilities[0])
+	}
+}
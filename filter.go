@@ -0,0 +1,60 @@
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+// filterVulnerabilities drops the vulnerabilities that are excluded from both reporting and gating
+func filterVulnerabilities(config scannerConfig, vulnerabilities []vulnerabilityInfo) []vulnerabilityInfo {
+	filtered := make([]vulnerabilityInfo, 0, len(vulnerabilities))
+	for _, vulnerability := range vulnerabilities {
+		if !excludedByFilters(config, vulnerability) {
+			filtered = append(filtered, vulnerability)
+		}
+	}
+	if dropped := len(vulnerabilities) - len(filtered); dropped > 0 {
+		logger.Infof("Ignoring %d vulnerabilities excluded by filters", dropped)
+	}
+	return filtered
+}
+
+// excludedByFilters tells whether the vulnerability is left out by the severity, base image, package or namespace filters
+func excludedByFilters(config scannerConfig, vulnerability vulnerabilityInfo) bool {
+	return contains(config.ignoreSeverities, vulnerability.Severity) ||
+		(config.baseImageMode == "ignore" && vulnerability.InBaseImage) ||
+		(len(config.includePackages) > 0 && !matchesAny(config.includePackages, vulnerability.FeatureName)) ||
+		matchesAny(config.excludePackages, vulnerability.FeatureName) ||
+		(len(config.namespaces) > 0 && !matchesAny(config.namespaces, vulnerability.Namespace))
+}
+
+// parseList splits a comma separated option value, empty values are left out
+func parseList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// contains tells if the value is part of the list
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAny tells if the value matches one of the glob patterns, e.g. linux-* matches linux-libc-dev
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseList(t *testing.T) {
+	list := parseList(" Negligible, Low,,Unknown ")
+	expected := []string{"Negligible", "Low", "Unknown"}
+	if !reflect.DeepEqual(list, expected) {
+		t.Errorf("Expected %v, got %v", expected, list)
+	}
+	if len(parseList("")) != 0 {
+		t.Errorf("Expected an empty list")
+	}
+}
+
+func TestFilterVulnerabilities(t *testing.T) {
+	initializeLogger("")
+	vulnerabilities := []vulnerabilityInfo{
+		{FeatureName: "openssl", Vulnerability: "CVE-1", Namespace: "debian:11", Severity: "High"},
+		{FeatureName: "linux-libc-dev", Vulnerability: "CVE-2", Namespace: "debian:11", Severity: "High"},
+		{FeatureName: "zlib", Vulnerability: "CVE-3", Namespace: "debian:11", Severity: "Negligible"},
+		{FeatureName: "musl", Vulnerability: "CVE-4", Namespace: "alpine:v3.5", Severity: "Medium"},
+	}
+
+	tests := []struct {
+		name     string
+		config   scannerConfig
+		expected []string
+	}{
+		{"no filters", scannerConfig{}, []string{"CVE-1", "CVE-2", "CVE-3", "CVE-4"}},
+		{"ignore severity", scannerConfig{ignoreSeverities: []string{"Negligible", "Medium"}}, []string{"CVE-1", "CVE-2"}},
+		{"exclude package", scannerConfig{excludePackages: []string{"linux-*"}}, []string{"CVE-1", "CVE-3", "CVE-4"}},
+		{"include package", scannerConfig{includePackages: []string{"openssl", "musl"}}, []string{"CVE-1", "CVE-4"}},
+		{"namespace", scannerConfig{namespaces: []string{"alpine:*"}}, []string{"CVE-4"}},
+	}
+	for _, test := range tests {
+		filtered := []string{}
+		for _, vulnerability := range filterVulnerabilities(test.config, vulnerabilities) {
+			filtered = append(filtered, vulnerability.Vulnerability)
+		}
+		if !reflect.DeepEqual(filtered, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, filtered)
+		}
+	}
+}
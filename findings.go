@@ -0,0 +1,31 @@
+package main
+
+import (
+	"time"
+)
+
+// finding is a vulnerability found in a scanned image, as exported to log and search platforms
+type finding struct {
+	Timestamp string `json:"@timestamp"`
+	ScanID    string `json:"scanid"`
+	Image     string `json:"image"`
+	Digest    string `json:"digest,omitempty"`
+	Platform  string `json:"platform,omitempty"`
+	vulnerabilityInfo
+}
+
+// reportFindings returns a finding for every vulnerability of the report, the findings of one scan share its scan ID
+func reportFindings(report *vulnerabilityReport, scanID string, scanned time.Time) []finding {
+	findings := make([]finding, 0, len(report.Vulnerabilities))
+	for _, vulnerability := range report.Vulnerabilities {
+		findings = append(findings, finding{
+			Timestamp:         scanned.UTC().Format(time.RFC3339),
+			ScanID:            scanID,
+			Image:             report.Image,
+			Digest:            report.Digest,
+			Platform:          report.Platform,
+			vulnerabilityInfo: vulnerability,
+		})
+	}
+	return findings
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	googleTokenURL      = "https://oauth2.googleapis.com/token"
+	googleScope         = "https://www.googleapis.com/auth/cloud-platform"
+	googleRegistryUser  = "oauth2accesstoken"
+	googleTokenLifetime = time.Hour
+)
+
+// gceMetadataEndpoint is the endpoint of the GCE metadata server for the token of the default service account
+var gceMetadataEndpoint = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+type googleCredentialsFile struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// isGoogleRegistry tells whether the registry is Google Container Registry or Artifact Registry
+func isGoogleRegistry(registry string) bool {
+	return registry == "gcr.io" || strings.HasSuffix(registry, ".gcr.io") || strings.HasSuffix(registry, "-docker.pkg.dev")
+}
+
+// googleCredentials gets an access token with the Google Application Default Credentials for a GCR or Artifact Registry registry,
+// false when the registry is not of Google or there are no credentials
+func googleCredentials(registry string) (registryCredentials, bool) {
+	if !isGoogleRegistry(registry) {
+		return registryCredentials{}, false
+	}
+	token, err := applicationDefaultToken()
+	if err != nil {
+		logger.Warnf("Could not get a Google access token for %s, falling back to the docker credentials: %v", registry, err)
+		return registryCredentials{}, false
+	}
+	return registryCredentials{user: googleRegistryUser, password: token}, true
+}
+
+// applicationDefaultToken gets an access token like the Google Application Default Credentials do:
+// the GOOGLE_APPLICATION_CREDENTIALS file, the credentials of 'gcloud auth application-default login' or the GCE metadata server
+func applicationDefaultToken() (string, error) {
+	if file := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); file != "" {
+		return credentialsFileToken(file, time.Now())
+	}
+	if _, err := os.Stat(gcloudCredentialsFile()); err == nil {
+		return credentialsFileToken(gcloudCredentialsFile(), time.Now())
+	}
+	return metadataToken()
+}
+
+func gcloudCredentialsFile() string {
+	configDir := os.Getenv("CLOUDSDK_CONFIG")
+	if configDir == "" {
+		home, _ := os.UserHomeDir()
+		configDir = filepath.Join(home, ".config", "gcloud")
+	}
+	return filepath.Join(configDir, "application_default_credentials.json")
+}
+
+// credentialsFileToken exchanges the service account key or user refresh token of a credentials file for an access token
+func credentialsFileToken(file string, now time.Time) (string, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	var credentials googleCredentialsFile
+	if err = json.Unmarshal(content, &credentials); err != nil {
+		return "", fmt.Errorf("%s is not json: %v", file, err)
+	}
+	if credentials.TokenURI == "" {
+		credentials.TokenURI = googleTokenURL
+	}
+
+	form := url.Values{}
+	switch credentials.Type {
+	case "service_account":
+		assertion, err := signGoogleAssertion(credentials, now)
+		if err != nil {
+			return "", err
+		}
+		form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+		form.Set("assertion", assertion)
+	case "authorized_user":
+		form.Set("grant_type", "refresh_token")
+		form.Set("client_id", credentials.ClientID)
+		form.Set("client_secret", credentials.ClientSecret)
+		form.Set("refresh_token", credentials.RefreshToken)
+	default:
+		return "", fmt.Errorf("credentials of type %q in %s are not supported", credentials.Type, file)
+	}
+	response, err := (&http.Client{Transport: newTransport()}).PostForm(credentials.TokenURI, form)
+	if err != nil {
+		return "", err
+	}
+	return decodeAccessToken(response)
+}
+
+// signGoogleAssertion creates the JWT of a service account to request an access token with, signed with its private key
+func signGoogleAssertion(credentials googleCredentialsFile, now time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(credentials.PrivateKey))
+	if block == nil {
+		return "", fmt.Errorf("private key of %s is not PEM encoded", credentials.ClientEmail)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("could not parse private key of %s: %v", credentials.ClientEmail, err)
+	}
+	rsaKey, isRSA := key.(*rsa.PrivateKey)
+	if !isRSA {
+		return "", fmt.Errorf("private key of %s is not an RSA key", credentials.ClientEmail)
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   credentials.ClientEmail,
+		"scope": googleScope,
+		"aud":   credentials.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(googleTokenLifetime).Unix(),
+	})
+	token := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(token))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+	return token + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// metadataToken gets an access token of the default service account from the GCE metadata server
+func metadataToken() (string, error) {
+	request, err := http.NewRequest("GET", gceMetadataEndpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Metadata-Flavor", "Google")
+	response, err := (&http.Client{Timeout: 2 * time.Second}).Do(request)
+	if err != nil {
+		return "", fmt.Errorf("no Application Default Credentials found and the GCE metadata server is not reachable: %v", err)
+	}
+	return decodeAccessToken(response)
+}
+
+// decodeAccessToken decodes the access token of an OAuth 2 token response
+func decodeAccessToken(response *http.Response) (string, error) {
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(response.Body)
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request responded %d with message %s", response.StatusCode, string(body))
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("token response contains no access token: %s", string(body))
+	}
+	return token.AccessToken, nil
+}
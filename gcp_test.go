@@ -0,0 +1,60 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCredentialsFileToken(t *testing.T) {
+	initializeLogger("")
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	tokenService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || len(strings.Split(r.Form.Get("assertion"), ".")) != 3 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"access_token": "ya29.token", "expires_in": 3600}`))
+	}))
+	defer tokenService.Close()
+
+	tmpPath := createTmpPath("gcp")
+	defer os.RemoveAll(tmpPath)
+	file := filepath.Join(tmpPath, "key.json")
+	content, _ := json.Marshal(googleCredentialsFile{
+		Type:        "service_account",
+		ClientEmail: "scanner@project.iam.gserviceaccount.com",
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		TokenURI:    tokenService.URL,
+	})
+	if err = ioutil.WriteFile(file, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if token, err := credentialsFileToken(file, time.Now()); err != nil || token != "ya29.token" {
+		t.Errorf("Expected the access token of the service account, got %s %v", token, err)
+	}
+}
+
+func TestIsGoogleRegistry(t *testing.T) {
+	for registry, expected := range map[string]bool{"gcr.io": true, "eu.gcr.io": true, "europe-west1-docker.pkg.dev": true, "ghcr.io": false, "docker.pkg.dev.example.com": false} {
+		if isGoogleRegistry(registry) != expected {
+			t.Errorf("Expected %s to be a Google registry: %v", registry, expected)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+const (
+	gitlabSchemaVersion = "15.0.4"
+	gitlabTimeFormat    = "2006-01-02T15:04:05"
+)
+
+type gitlabReport struct {
+	Version         string                `json:"version"`
+	Scan            gitlabScan            `json:"scan"`
+	Vulnerabilities []gitlabVulnerability `json:"vulnerabilities"`
+	Remediations    []interface{}         `json:"remediations"`
+}
+
+type gitlabScan struct {
+	Analyzer  gitlabScanner `json:"analyzer"`
+	Scanner   gitlabScanner `json:"scanner"`
+	Type      string        `json:"type"`
+	StartTime string        `json:"start_time"`
+	EndTime   string        `json:"end_time"`
+	Status    string        `json:"status"`
+}
+
+type gitlabScanner struct {
+	ID      string       `json:"id"`
+	Name    string       `json:"name"`
+	URL     string       `json:"url,omitempty"`
+	Version string       `json:"version"`
+	Vendor  gitlabVendor `json:"vendor"`
+}
+
+type gitlabVendor struct {
+	Name string `json:"name"`
+}
+
+type gitlabVulnerability struct {
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description,omitempty"`
+	Severity    string             `json:"severity"`
+	Solution    string             `json:"solution,omitempty"`
+	Location    gitlabLocation     `json:"location"`
+	Identifiers []gitlabIdentifier `json:"identifiers"`
+	Links       []gitlabLink       `json:"links,omitempty"`
+	CVSSVectors []gitlabCVSSVector `json:"cvss_vectors,omitempty"`
+}
+
+type gitlabCVSSVector struct {
+	Vendor string `json:"vendor"`
+	Vector string `json:"vector"`
+}
+
+type gitlabLocation struct {
+	Dependency      gitlabDependency `json:"dependency"`
+	OperatingSystem string           `json:"operating_system"`
+	Image           string           `json:"image"`
+}
+
+type gitlabDependency struct {
+	Package gitlabPackage `json:"package"`
+	Version string        `json:"version"`
+}
+
+type gitlabPackage struct {
+	Name string `json:"name"`
+}
+
+type gitlabIdentifier struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	URL   string `json:"url,omitempty"`
+}
+
+type gitlabLink struct {
+	URL string `json:"url"`
+}
+
+// clairVersions maps a Clair API to the version of Clair that serves it
+var clairVersions = map[string]string{
+	"v1": "2",
+	"v3": "3",
+	"v4": "4",
+}
+
+// gitlabSeverities maps a CVE severity to the severities known by GitLab
+var gitlabSeverities = map[string]string{
+	"Defcon1":    "Critical",
+	"Critical":   "Critical",
+	"High":       "High",
+	"Medium":     "Medium",
+	"Low":        "Low",
+	"Negligible": "Info",
+	"Unknown":    "Unknown",
+}
+
+// writeGitlabReport writes the unapproved vulnerabilities in the GitLab container scanning report format
+func writeGitlabReport(w io.Writer, report *vulnerabilityReport) error {
+	now := time.Now().UTC().Format(gitlabTimeFormat)
+	startTime := now
+	if !report.started.IsZero() {
+		startTime = report.started.UTC().Format(gitlabTimeFormat)
+	}
+
+	clairVersion, known := clairVersions[report.clairAPI]
+	if !known {
+		clairVersion = "unknown"
+	}
+
+	gitlab := gitlabReport{
+		Version: gitlabSchemaVersion,
+		Scan: gitlabScan{
+			Analyzer:  gitlabScanner{ID: toolName, Name: toolName, URL: toolURI, Version: version, Vendor: gitlabVendor{Name: toolName}},
+			Scanner:   gitlabScanner{ID: "clair", Name: "Clair", URL: "https://github.com/quay/clair", Version: clairVersion, Vendor: gitlabVendor{Name: "Quay"}},
+			Type:      "container_scanning",
+			StartTime: startTime,
+			EndTime:   now,
+			Status:    "success",
+		},
+		Vulnerabilities: []gitlabVulnerability{},
+		Remediations:    []interface{}{},
+	}
+
+	for _, vulnerability := range report.Vulnerabilities {
+		if vulnerability.Status == "Approved" {
+			continue
+		}
+		gitlab.Vulnerabilities = append(gitlab.Vulnerabilities, gitlabVulnerabilityFor(report.Image, vulnerability))
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(gitlab)
+}
+
+// gitlabVulnerabilityFor converts a vulnerability to a GitLab vulnerability
+func gitlabVulnerabilityFor(image string, vulnerability vulnerabilityInfo) gitlabVulnerability {
+	id := sha256.Sum256([]byte(image + ":" + vulnerability.Vulnerability + ":" + vulnerability.FeatureName + ":" + vulnerability.FeatureVersion))
+	severity, exists := gitlabSeverities[vulnerability.Severity]
+	if !exists {
+		severity = "Unknown"
+	}
+
+	gitlab := gitlabVulnerability{
+		ID:          hex.EncodeToString(id[:]),
+		Name:        vulnerability.Vulnerability,
+		Description: vulnerability.Description,
+		Severity:    severity,
+		Location: gitlabLocation{
+			Dependency:      gitlabDependency{Package: gitlabPackage{Name: vulnerability.FeatureName}, Version: vulnerability.FeatureVersion},
+			OperatingSystem: vulnerability.Namespace,
+			Image:           image,
+		},
+		Identifiers: []gitlabIdentifier{{
+			Type:  "cve",
+			Name:  vulnerability.Vulnerability,
+			Value: vulnerability.Vulnerability,
+			URL:   vulnerability.Link,
+		}},
+	}
+	if vulnerability.FixedBy != "" {
+		gitlab.Solution = "Upgrade " + vulnerability.FeatureName + " to " + vulnerability.FixedBy
+	}
+	if vulnerability.Link != "" {
+		gitlab.Links = []gitlabLink{{URL: vulnerability.Link}}
+	}
+	if vulnerability.CVSSVector != "" {
+		gitlab.CVSSVectors = []gitlabCVSSVector{{Vendor: "NVD", Vector: vulnerability.CVSSVector}}
+	}
+	return gitlab
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestWriteGitlabReport(t *testing.T) {
+	report := &vulnerabilityReport{
+		Image: "alpine:3.5",
+		Vulnerabilities: []vulnerabilityInfo{
+			{FeatureName: "zlib", FeatureVersion: "1.2.8", Vulnerability: "CVE-2016-9840", Namespace: "alpine:v3.5", Severity: "Defcon1", FixedBy: "1.2.11", Status: "Unapproved", Link: "https://example.com/CVE-2016-9840"},
+			{FeatureName: "musl", FeatureVersion: "1.1.15", Vulnerability: "CVE-2017-15650", Severity: "Low", Status: "Approved"},
+		},
+		started:  time.Date(2017, 9, 24, 11, 16, 41, 0, time.UTC),
+		clairAPI: "v4",
+	}
+
+	var buffer bytes.Buffer
+	if err := writeGitlabReport(&buffer, report); err != nil {
+		t.Fatalf("Could not write GitLab report: %v", err)
+	}
+	var gitlab struct {
+		Version string `json:"version"`
+		Scan    struct {
+			Type      string `json:"type"`
+			Status    string `json:"status"`
+			StartTime string `json:"start_time"`
+			Analyzer  struct {
+				ID     string `json:"id"`
+				Vendor struct {
+					Name string `json:"name"`
+				} `json:"vendor"`
+			} `json:"analyzer"`
+			Scanner struct {
+				Version string `json:"version"`
+			} `json:"scanner"`
+		} `json:"scan"`
+		Vulnerabilities []map[string]interface{} `json:"vulnerabilities"`
+		Remediations    []interface{}            `json:"remediations"`
+	}
+	if err := json.Unmarshal(buffer.Bytes(), &gitlab); err != nil {
+		t.Fatalf("GitLab report is not JSON: %v", err)
+	}
+	if gitlab.Version != gitlabSchemaVersion || gitlab.Scan.Type != "container_scanning" || gitlab.Scan.Status != "success" || gitlab.Scan.Analyzer.ID != toolName || gitlab.Scan.Analyzer.Vendor.Name == "" {
+		t.Errorf("Expected the schema version and the container scanning analyzer, got %+v", gitlab)
+	}
+	if gitlab.Scan.Scanner.Version != "4" {
+		t.Errorf("Expected the version of the Clair the image was scanned with, got %q", gitlab.Scan.Scanner.Version)
+	}
+	if gitlab.Scan.StartTime != "2017-09-24T11:16:41" || gitlab.Remediations == nil {
+		t.Errorf("Expected the start of the scan and empty remediations, got %+v", gitlab)
+	}
+	if len(gitlab.Vulnerabilities) != 1 {
+		t.Fatalf("Expected only the unapproved vulnerability, got %v", gitlab.Vulnerabilities)
+	}
+	vulnerability := gitlab.Vulnerabilities[0]
+	for _, field := range []string{"id", "name", "severity", "location", "identifiers"} {
+		if _, ok := vulnerability[field]; !ok {
+			t.Errorf("Expected the required field %s in the vulnerability, got %v", field, vulnerability)
+		}
+	}
+	location := vulnerability["location"].(map[string]interface{})
+	if vulnerability["severity"] != "Critical" || vulnerability["solution"] != "Upgrade zlib to 1.2.11" || location["image"] != "alpine:3.5" || location["operating_system"] != "alpine:v3.5" {
+		t.Errorf("Expected the vulnerability of zlib in alpine:3.5, got %v", vulnerability)
+	}
+}
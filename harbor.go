@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// harborScheme prefixes the image argument that scans the repositories of a Harbor project, e.g. harbor://myproject/*
+const harborScheme = "harbor://"
+
+// harborPageSize is the number of repositories or artifacts requested per page of the Harbor API
+const harborPageSize = 100
+
+type harborRepository struct {
+	Name string `json:"name"`
+}
+
+type harborArtifact struct {
+	Type   string `json:"type"`
+	Digest string `json:"digest"`
+	Tags   []struct {
+		Name string `json:"name"`
+	} `json:"tags"`
+}
+
+// harborClient requests the API of a Harbor instance, with the credentials of its registry, e.g. of a robot account
+type harborClient struct {
+	client      *http.Client
+	url         string
+	credentials registryCredentials
+}
+
+// parseHarborImage parses harbor://project/repositories into the project and the glob pattern of its repositories, all repositories without pattern
+func parseHarborImage(imageName string) (string, string) {
+	parts := strings.SplitN(strings.TrimPrefix(imageName, harborScheme), "/", 2)
+	if parts[0] == "" {
+		logger.Fatalf("Invalid Harbor image %s given, use harbor://project/* or harbor://project/repository", imageName)
+	}
+	if len(parts) == 1 || parts[1] == "" {
+		return parts[0], "*"
+	}
+	if _, err := path.Match(parts[1], ""); err != nil {
+		logger.Fatalf("Invalid Harbor image %s given: %v", imageName, err)
+	}
+	return parts[0], parts[1]
+}
+
+// harborConfigs returns a scanner configuration for every tag of the repositories of a Harbor project, the images are downloaded from the Harbor registry
+func harborConfigs(config scannerConfig, harborURL string) []scannerConfig {
+	if harborURL == "" {
+		logger.Fatalf("Scanning a Harbor project requires the URL of Harbor, use --harbor-url")
+	}
+	if config.ociDir != "" || config.tarFile != "" {
+		logger.Fatalf("Harbor images are downloaded from Harbor, they can't be read with --oci-dir or --tar")
+	}
+	parsed, err := url.Parse(harborURL)
+	if err != nil || parsed.Host == "" {
+		logger.Fatalf("Invalid Harbor URL %s given", harborURL)
+	}
+	project, pattern := parseHarborImage(config.imageName)
+	harbor := &harborClient{client: &http.Client{Transport: newTransport()}, url: strings.TrimSuffix(harborURL, "/"), credentials: lookupRegistryCredentials(parsed.Host)}
+	images, err := harbor.images(parsed.Host, project, pattern)
+	if err != nil {
+		logger.Fatalf("Could not list the images of Harbor project %s: %v", project, err)
+	}
+	if len(images) == 0 {
+		logger.Fatalf("No tagged images in Harbor project %s match %s", project, pattern)
+	}
+	logger.Infof("Scanning %d images of Harbor project %s", len(images), project)
+
+	var configs []scannerConfig
+	for _, image := range images {
+		imageConfig := forImage(config, image)
+		imageConfig.registry = true
+		configs = append(configs, imageConfig)
+	}
+	return configs
+}
+
+// harborRepositoryMatches tells if the repository name matches the glob pattern, * also matches the / of nested repositories like team/api
+func harborRepositoryMatches(pattern string, name string) bool {
+	matched, _ := path.Match(strings.Replace(pattern, "/", "\x00", -1), strings.Replace(name, "/", "\x00", -1))
+	return matched
+}
+
+// images returns the tagged images of the repositories of the project that match the pattern, as registry/project/repository:tag
+func (harbor *harborClient) images(registry string, project string, pattern string) ([]string, error) {
+	var images []string
+	for page := 1; ; page++ {
+		var repositories []harborRepository
+		if err := harbor.get(fmt.Sprintf("/projects/%s/repositories?page=%d&page_size=%d", url.PathEscape(project), page, harborPageSize), &repositories); err != nil {
+			return nil, err
+		}
+		for _, repository := range repositories {
+			name := strings.TrimPrefix(repository.Name, project+"/")
+			if !harborRepositoryMatches(pattern, name) {
+				continue
+			}
+			tags, err := harbor.tags(project, name)
+			if err != nil {
+				return nil, err
+			}
+			for _, tag := range tags {
+				images = append(images, registry+"/"+project+"/"+name+":"+tag)
+			}
+		}
+		if len(repositories) < harborPageSize {
+			return images, nil
+		}
+	}
+}
+
+// tags returns the tags of the images of a repository, other artifacts like Helm charts and signatures are skipped
+func (harbor *harborClient) tags(project string, repository string) ([]string, error) {
+	var tags []string
+	// Harbor requires the slashes of nested repository names to be encoded twice
+	repositoryPath := url.PathEscape(url.PathEscape(repository))
+	for page := 1; ; page++ {
+		var artifacts []harborArtifact
+		if err := harbor.get(fmt.Sprintf("/projects/%s/repositories/%s/artifacts?with_tag=true&page=%d&page_size=%d", url.PathEscape(project), repositoryPath, page, harborPageSize), &artifacts); err != nil {
+			return nil, err
+		}
+		for _, artifact := range artifacts {
+			if artifact.Type != "" && artifact.Type != "IMAGE" {
+				continue
+			}
+			for _, tag := range artifact.Tags {
+				tags = append(tags, tag.Name)
+			}
+		}
+		if len(artifacts) < harborPageSize {
+			return tags, nil
+		}
+	}
+}
+
+// get requests the Harbor API and decodes the JSON response
+func (harbor *harborClient) get(apiPath string, value interface{}) error {
+	request, err := http.NewRequest("GET", harbor.url+"/api/v2.0"+apiPath, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	if harbor.credentials.user != "" {
+		request.SetBasicAuth(harbor.credentials.user, harbor.credentials.password)
+	}
+	response, err := harbor.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(response.Body)
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("Harbor responded %d with message %s", response.StatusCode, string(body))
+	}
+	if err = json.Unmarshal(body, value); err != nil {
+		return fmt.Errorf("Harbor response is not json: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseHarborImage(t *testing.T) {
+	initializeLogger("")
+	tests := map[string][2]string{
+		"harbor://myproject/*":        {"myproject", "*"},
+		"harbor://myproject":          {"myproject", "*"},
+		"harbor://myproject/team/app": {"myproject", "team/app"},
+	}
+	for imageName, expected := range tests {
+		if project, pattern := parseHarborImage(imageName); project != expected[0] || pattern != expected[1] {
+			t.Errorf("Expected %s to be parsed as %v, got %s %s", imageName, expected, project, pattern)
+		}
+	}
+}
+
+func TestHarborImages(t *testing.T) {
+	initializeLogger("")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, _ := r.BasicAuth(); user != "robot$myproject+ci" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.EscapedPath() {
+		case "/api/v2.0/projects/myproject/repositories":
+			w.Write([]byte(`[{"name": "myproject/app"}, {"name": "myproject/team/api"}, {"name": "myproject/docs"}]`))
+		case "/api/v2.0/projects/myproject/repositories/app/artifacts":
+			w.Write([]byte(`[{"type": "IMAGE", "tags": [{"name": "1.0"}, {"name": "latest"}]}, {"type": "IMAGE", "tags": null}, {"type": "CHART", "tags": [{"name": "chart"}]}]`))
+		case "/api/v2.0/projects/myproject/repositories/team%252Fapi/artifacts":
+			w.Write([]byte(`[{"type": "IMAGE", "tags": [{"name": "2.0"}]}]`))
+		default:
+			t.Errorf("Unexpected request of %s", r.URL.EscapedPath())
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	harbor := &harborClient{client: server.Client(), url: server.URL, credentials: registryCredentials{user: "robot$myproject+ci", password: "secret"}}
+	images, err := harbor.images("harbor.example.com", "myproject", "*a*")
+	expected := []string{"harbor.example.com/myproject/app:1.0", "harbor.example.com/myproject/app:latest", "harbor.example.com/myproject/team/api:2.0"}
+	if err != nil || !reflect.DeepEqual(images, expected) {
+		t.Errorf("Expected the tagged images %v, got %v %v", expected, images, err)
+	}
+}
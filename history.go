@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// historyFileName is the file in the cache folder every scan is recorded in, as a JSON object per line
+const historyFileName = "history.jsonl"
+
+// historyEntry records the findings of a scan of an image
+type historyEntry struct {
+	Time            time.Time      `json:"time"`
+	Image           string         `json:"image"`
+	Platform        string         `json:"platform,omitempty"`
+	Digest          string         `json:"digest,omitempty"`
+	Severities      map[string]int `json:"severities"`
+	Total           int            `json:"total"`
+	Unapproved      int            `json:"unapproved"`
+	Vulnerabilities []string       `json:"vulnerabilities"`
+}
+
+// historyPath returns the path of the scan history in the cache folder
+func historyPath() string {
+	return filepath.Join(cacheDir, historyFileName)
+}
+
+// parseHistoryRetention parses the duration scans are kept in the scan history, 0 keeps every scan
+func parseHistoryRetention(value string) time.Duration {
+	retention, err := time.ParseDuration(value)
+	if err != nil || retention < 0 {
+		logger.Fatalf("Invalid history retention %s given, use a duration like '720h'", value)
+	}
+	return retention
+}
+
+// newHistoryEntry counts the vulnerabilities of the report by severity and lists its CVEs
+func newHistoryEntry(report *vulnerabilityReport, scanned time.Time) historyEntry {
+	entry := historyEntry{Time: scanned, Image: report.Image, Platform: report.Platform, Digest: report.Digest, Severities: map[string]int{}, Unapproved: len(report.Unapproved), Vulnerabilities: []string{}}
+	found := map[string]bool{}
+	for _, vulnerability := range report.Vulnerabilities {
+		entry.Severities[vulnerability.Severity]++
+		if !found[vulnerability.Vulnerability] {
+			found[vulnerability.Vulnerability] = true
+			entry.Vulnerabilities = append(entry.Vulnerabilities, vulnerability.Vulnerability)
+		}
+	}
+	entry.Total = len(report.Vulnerabilities)
+	sort.Strings(entry.Vulnerabilities)
+	return entry
+}
+
+// historyLock keeps the scans of server mode from appending to the scan history while it is pruned
+var historyLock sync.Mutex
+
+// recordHistory appends the scan of the report to the scan history and removes the scans older than the retention, failures are logged as warnings
+func recordHistory(report *vulnerabilityReport, retention time.Duration) {
+	now := time.Now()
+	content, _ := json.Marshal(newHistoryEntry(report, now))
+	path := historyPath()
+	historyLock.Lock()
+	defer historyLock.Unlock()
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err == nil {
+		var file *os.File
+		if file, err = os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644); err == nil {
+			_, err = file.Write(append(content, '\n'))
+			file.Close()
+		}
+	}
+	if err == nil && retention > 0 {
+		err = pruneHistory(path, now.Add(-retention))
+	}
+	if err != nil {
+		logger.Warnf("Could not record the scan of image [%s] in the scan history: %v", report.Image, err)
+	}
+}
+
+// pruneHistory removes the scans before the cutoff from the scan history. Scans are appended in time order, so the file is only rewritten when its first scan is before the cutoff
+func pruneHistory(path string, cutoff time.Time) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var kept bytes.Buffer
+	pruned := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry historyEntry
+		if strings.TrimSpace(scanner.Text()) == "" || json.Unmarshal(scanner.Bytes(), &entry) != nil || entry.Time.Before(cutoff) {
+			pruned++
+			continue
+		}
+		if pruned == 0 {
+			return nil
+		}
+		kept.Write(scanner.Bytes())
+		kept.WriteByte('\n')
+	}
+	if err = scanner.Err(); err != nil || pruned == 0 {
+		return err
+	}
+
+	//The pruned history replaces the file at once, so readers never see a partial file
+	tmpPath := path + ".tmp"
+	if err = ioutil.WriteFile(tmpPath, kept.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// readHistory reads the scans of the image, by its name or a digest, from the scan history, oldest first
+func readHistory(history io.Reader, image string) ([]historyEntry, error) {
+	entries := []historyEntry{}
+	scanner := bufio.NewScanner(history)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry historyEntry
+		if strings.TrimSpace(scanner.Text()) == "" || json.Unmarshal(scanner.Bytes(), &entry) != nil {
+			continue // skip lines of an interrupted write
+		}
+		if entry.Image == image || entry.Digest == image {
+			entries = append(entries, entry)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	return entries, scanner.Err()
+}
+
+// historyChanges returns the vulnerabilities of the scan that are new and fixed since the previous scan
+func historyChanges(previous historyEntry, entry historyEntry) ([]string, []string) {
+	added, fixed := []string{}, []string{}
+	before := map[string]bool{}
+	for _, vulnerability := range previous.Vulnerabilities {
+		before[vulnerability] = true
+	}
+	after := map[string]bool{}
+	for _, vulnerability := range entry.Vulnerabilities {
+		after[vulnerability] = true
+		if !before[vulnerability] {
+			added = append(added, vulnerability)
+		}
+	}
+	for _, vulnerability := range previous.Vulnerabilities {
+		if !after[vulnerability] {
+			fixed = append(fixed, vulnerability)
+		}
+	}
+	return added, fixed
+}
+
+// historyToConsole prints the vulnerabilities of every scan by severity and how they changed since the scan before
+func historyToConsole(entries []historyEntry) {
+	severities := summarySeverities()
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader(append(append([]string{"Scanned", "Digest"}, severities...), "Total", "Unapproved", "New", "Fixed"))
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	for i, entry := range entries {
+		row := []string{entry.Time.Local().Format("2006-01-02 15:04"), shortLayerID(strings.TrimPrefix(entry.Digest, "sha256:"))}
+		for _, severity := range severities {
+			row = append(row, strconv.Itoa(entry.Severities[severity]))
+		}
+		row = append(row, strconv.Itoa(entry.Total), strconv.Itoa(entry.Unapproved))
+		if i > 0 {
+			added, fixed := historyChanges(entries[i-1], entry)
+			row = append(row, strconv.Itoa(len(added)), strconv.Itoa(len(fixed)))
+		} else {
+			row = append(row, "", "")
+		}
+		table.Append(row)
+	}
+	table.Render()
+}
+
+// printHistory prints the scan history of the image, as a table or as JSON
+func printHistory(image string, asJSON bool) {
+	file, err := os.Open(historyPath())
+	if err != nil && !os.IsNotExist(err) {
+		logger.Fatalf("Could not read the scan history: %v", err)
+	}
+	entries := []historyEntry{}
+	if file != nil {
+		defer file.Close()
+		if entries, err = readHistory(file, image); err != nil {
+			logger.Fatalf("Could not read the scan history: %v", err)
+		}
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "    ")
+		encoder.Encode(entries)
+		return
+	}
+	if len(entries) == 0 {
+		logger.Infof("Image [%s] has no scans in the scan history %s", image, historyPath())
+		return
+	}
+	historyToConsole(entries)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewHistoryEntry(t *testing.T) {
+	report := &vulnerabilityReport{
+		Image:      "alpine:3.5",
+		Digest:     "sha256:abc",
+		Unapproved: []string{"CVE-2017-2"},
+		Vulnerabilities: []vulnerabilityInfo{
+			{Vulnerability: "CVE-2017-2", Severity: "High", FeatureName: "openssl"},
+			{Vulnerability: "CVE-2017-1", Severity: "Low", FeatureName: "musl"},
+			{Vulnerability: "CVE-2017-2", Severity: "High", FeatureName: "libssl"},
+		},
+	}
+	entry := newHistoryEntry(report, time.Unix(0, 0))
+	if entry.Total != 3 || entry.Unapproved != 1 || entry.Severities["High"] != 2 || entry.Severities["Low"] != 1 {
+		t.Errorf("Expected 2 High and 1 Low vulnerabilities with 1 unapproved, got %v", entry)
+	}
+	if !reflect.DeepEqual(entry.Vulnerabilities, []string{"CVE-2017-1", "CVE-2017-2"}) {
+		t.Errorf("Expected the sorted CVEs of the report, got %v", entry.Vulnerabilities)
+	}
+}
+
+func TestReadHistory(t *testing.T) {
+	history := `{"time": "2017-09-25T10:00:00Z", "image": "alpine:3.5", "digest": "sha256:def", "vulnerabilities": ["CVE-2017-2"]}
+{"time": "2017-09-24T10:00:00Z", "image": "alpine:3.5", "digest": "sha256:abc", "vulnerabilities": ["CVE-2017-1"]}
+{"time": "2017-09-24T11:00:00Z", "image": "debian:jessie", "digest": "sha256:123"}
+{"time": "2017-09-26T10:00:00Z", "image": "alp
+`
+	entries, err := readHistory(strings.NewReader(history), "alpine:3.5")
+	if err != nil || len(entries) != 2 || entries[0].Digest != "sha256:abc" || entries[1].Digest != "sha256:def" {
+		t.Fatalf("Expected the 2 scans of alpine:3.5 oldest first, got %v %v", entries, err)
+	}
+	if entries, _ = readHistory(strings.NewReader(history), "sha256:123"); len(entries) != 1 || entries[0].Image != "debian:jessie" {
+		t.Errorf("Expected the scan of the digest, got %v", entries)
+	}
+
+	added, fixed := historyChanges(historyEntry{Vulnerabilities: []string{"CVE-2017-1", "CVE-2017-2"}}, historyEntry{Vulnerabilities: []string{"CVE-2017-2", "CVE-2017-3"}})
+	if !reflect.DeepEqual(added, []string{"CVE-2017-3"}) || !reflect.DeepEqual(fixed, []string{"CVE-2017-1"}) {
+		t.Errorf("Expected CVE-2017-3 to be new and CVE-2017-1 fixed, got %v and %v", added, fixed)
+	}
+}
+
+func TestPruneHistory(t *testing.T) {
+	tmpPath := createTmpPath("history")
+	defer os.RemoveAll(tmpPath)
+	path := filepath.Join(tmpPath, historyFileName)
+	history := `{"time": "2017-06-01T10:00:00Z", "image": "alpine:3.5", "digest": "sha256:abc"}
+{"time": "2017-09-24T10:00:00Z", "image": "alpine:3.5", "digest": "sha256:def"}
+{"time": "2017-09-25T10:00:00Z", "image": "debian:jessie", "digest": "sha256:123"}
+`
+	ioutil.WriteFile(path, []byte(history), 0644)
+
+	if err := pruneHistory(path, time.Date(2017, 5, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := ioutil.ReadFile(path); string(content) != history {
+		t.Errorf("Expected the history without older scans to be left unchanged, got:\n%s", content)
+	}
+
+	if err := pruneHistory(path, time.Date(2017, 9, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	file, _ := os.Open(path)
+	defer file.Close()
+	entries, _ := readHistory(file, "alpine:3.5")
+	if len(entries) != 1 || entries[0].Digest != "sha256:def" {
+		t.Errorf("Expected only the scan of alpine:3.5 after the cutoff to be kept, got %v", entries)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Expected the temporary history to be renamed, got %v", err)
+	}
+	if parseHistoryRetention("0") != 0 || parseHistoryRetention("720h") != 30*24*time.Hour {
+		t.Errorf("Expected the retention to be parsed as a duration")
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"html/template"
+	"io"
+	"strings"
+)
+
+const nvdURL = "https://nvd.nist.gov/vuln/detail/"
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"severityRank": func(severity string) int { return SeverityMap[severity] },
+	"lower":        strings.ToLower,
+	"isCVE":        func(name string) bool { return strings.HasPrefix(name, "CVE-") },
+	"nvdURL":       func(name string) string { return nvdURL + name },
+	"percent":      func(score float64) float64 { return score * 100 },
+	"shortLayerID": shortLayerID,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>clair-scanner report for {{.Image}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #24292e; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #d1d5da; padding: 6px 10px; text-align: left; vertical-align: top; }
+th { background: #f6f8fa; cursor: pointer; user-select: none; }
+td.description { white-space: pre-wrap; max-width: 40em; }
+.severity { font-weight: bold; border-radius: 3px; padding: 2px 6px; color: #fff; }
+.severity-defcon1, .severity-critical { background: #6f0000; }
+.severity-high { background: #cb2431; }
+.severity-medium { background: #e36209; }
+.severity-low { background: #b08800; }
+.severity-negligible, .severity-unknown { background: #6a737d; }
+.status-unapproved { color: #cb2431; font-weight: bold; }
+.status-approved { color: #22863a; }
+#filter { margin: 1em 0; padding: 6px; width: 30em; }
+</style>
+</head>
+<body>
+<h1>clair-scanner report for {{.Image}}</h1>
+<p>{{len .Vulnerabilities}} vulnerabilities found, {{len .Unapproved}} unapproved.</p>
+<input id="filter" type="search" placeholder="Filter on CVE, package, severity or status">
+<table id="vulnerabilities">
+<thead>
+<tr><th>Status</th><th>Severity</th><th>CVSS</th><th>EPSS</th><th>CVE</th><th>Package Name</th><th>Package Version</th><th>Fixed By</th><th>Layer</th><th>Description</th></tr>
+</thead>
+<tbody>
+{{- range .Vulnerabilities}}
+<tr>
+<td class="status-{{lower .Status}}">{{.Status}}</td>
+<td data-sort="{{severityRank .Severity}}"><span class="severity severity-{{lower .Severity}}">{{.Severity}}</span></td>
+<td data-sort="{{printf "%04.1f" .CVSSScore}}" title="{{.CVSSVector}}">{{if .CVSSScore}}{{printf "%.1f" .CVSSScore}}{{end}}</td>
+<td data-sort="{{printf "%.5f" .EPSS}}" title="percentile {{printf "%.2f" .EPSSPercentile}}">{{if .EPSS}}{{printf "%.2f%%" (percent .EPSS)}}{{end}}</td>
+<td>{{if .Link}}<a href="{{.Link}}">{{.Vulnerability}}</a>{{else}}{{.Vulnerability}}{{end}}{{if .KnownExploited}} <span class="severity severity-critical" title="In the CISA Known Exploited Vulnerabilities catalog">KEV</span>{{end}}{{if isCVE .Vulnerability}} (<a href="{{nvdURL .Vulnerability}}">NVD</a>){{end}}</td>
+<td>{{.FeatureName}}</td>
+<td>{{.FeatureVersion}}</td>
+<td>{{.FixedBy}}</td>
+<td title="{{.AddedBy}}">{{shortLayerID .AddedBy}}{{if .InstructionLine}}<br>Dockerfile:{{.InstructionLine}} <code>{{.Instruction}}</code>{{end}}</td>
+<td class="description">{{.Description}}</td>
+</tr>
+{{- end}}
+</tbody>
+</table>
+<script>
+(function () {
+  var table = document.getElementById("vulnerabilities");
+  var body = table.tBodies[0];
+  document.getElementById("filter").addEventListener("input", function () {
+    var query = this.value.toLowerCase();
+    Array.prototype.forEach.call(body.rows, function (row) {
+      row.style.display = row.textContent.toLowerCase().indexOf(query) === -1 ? "none" : "";
+    });
+  });
+  Array.prototype.forEach.call(table.tHead.rows[0].cells, function (header, column) {
+    var ascending = true;
+    header.addEventListener("click", function () {
+      var rows = Array.prototype.slice.call(body.rows);
+      rows.sort(function (a, b) {
+        var x = a.cells[column].getAttribute("data-sort") || a.cells[column].textContent;
+        var y = b.cells[column].getAttribute("data-sort") || b.cells[column].textContent;
+        return (ascending ? 1 : -1) * x.localeCompare(y, undefined, {numeric: true});
+      });
+      ascending = !ascending;
+      rows.forEach(function (row) { body.appendChild(row); });
+    });
+  });
+})();
+</script>
+</body>
+</html>
+`))
+
+// writeHTMLReport writes the report as a standalone HTML page
+func writeHTMLReport(w io.Writer, report *vulnerabilityReport) error {
+	return htmlReportTemplate.Execute(w, report)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteHTMLReport(t *testing.T) {
+	report := &vulnerabilityReport{
+		Image:      "alpine:3.5",
+		Unapproved: []string{"CVE-2016-9840"},
+		Vulnerabilities: []vulnerabilityInfo{
+			{FeatureName: "zlib", FeatureVersion: "1.2.8", Vulnerability: "CVE-2016-9840", Severity: "High", Status: "Unapproved", Description: "<script>alert(1)</script>"},
+			{FeatureName: "musl", FeatureVersion: "1.1.15", Vulnerability: "CVE-2017-15650", Severity: "Low", Status: "Approved", KnownExploited: true},
+		},
+	}
+
+	var buffer bytes.Buffer
+	if err := writeHTMLReport(&buffer, report); err != nil {
+		t.Fatalf("Could not write HTML report: %v", err)
+	}
+	page := buffer.String()
+	if rows := strings.Count(page, "<tr>"); rows != 3 {
+		t.Errorf("Expected a header row and a row per vulnerability, got %d rows", rows)
+	}
+	if !strings.Contains(page, "2 vulnerabilities found, 1 unapproved.") {
+		t.Errorf("Expected the totals of the report in the page")
+	}
+	if strings.Contains(page, "<script>alert(1)</script>") || !strings.Contains(page, "&lt;script&gt;alert(1)&lt;/script&gt;") {
+		t.Errorf("Expected the description to be escaped")
+	}
+	if !strings.Contains(page, `<a href="https://nvd.nist.gov/vuln/detail/CVE-2017-15650">NVD</a>`) || !strings.Contains(page, ">KEV</span>") {
+		t.Errorf("Expected the NVD link and the known exploited mark of CVE-2017-15650")
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+type grypeConfig struct {
+	Ignore []grypeIgnoreRule `yaml:"ignore"`
+}
+
+type grypeIgnoreRule struct {
+	Vulnerability string `yaml:"vulnerability"`
+	Reason        string `yaml:"reason"`
+	FixState      string `yaml:"fix-state"`
+	Package       struct {
+		Name     string `yaml:"name"`
+		Version  string `yaml:"version"`
+		Type     string `yaml:"type"`
+		Location string `yaml:"location"`
+	} `yaml:"package"`
+}
+
+// isTrivyIgnoreFile tells if the whitelist file is a Trivy .trivyignore file
+func isTrivyIgnoreFile(whitelistFile string) bool {
+	name := filepath.Base(whitelistFile)
+	return strings.HasPrefix(name, ".trivyignore") && !strings.HasSuffix(name, ".yaml") && !strings.HasSuffix(name, ".yml")
+}
+
+// isGrypeConfigFile tells if the local whitelist file is a Grype configuration with ignore rules
+func isGrypeConfigFile(whitelistFile string) bool {
+	content, err := ioutil.ReadFile(whitelistFile)
+	if err != nil {
+		return false
+	}
+	_, isGrype := parseGrypeIgnore(content)
+	return isGrype
+}
+
+// parseTrivyIgnore converts a .trivyignore file, with a CVE per line and an optional exp:YYYY-MM-DD expiration date, to a whitelist
+func parseTrivyIgnore(content []byte) vulnerabilitiesWhitelist {
+	whitelist := vulnerabilitiesWhitelist{GeneralWhitelist: make(map[string]whitelistEntry)}
+	description := ""
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			description = ""
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			//Comments above a CVE are used as its description
+			description = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+			continue
+		}
+		fields := strings.Fields(line)
+		entry := whitelistEntry{Description: description}
+		if entry.Description == "" {
+			entry.Description = "Imported from .trivyignore"
+		}
+		for _, field := range fields[1:] {
+			if strings.HasPrefix(field, "exp:") {
+				entry.Expires = strings.TrimPrefix(field, "exp:")
+			}
+		}
+		whitelist.GeneralWhitelist[fields[0]] = entry
+	}
+	return whitelist
+}
+
+// parseGrypeIgnore converts the ignore rules of a Grype configuration to a whitelist, it returns false when the content has no ignore rules.
+// A rule with a vulnerability and a package only accepts the vulnerability of that package, rules the whitelist can't express fail the scan.
+func parseGrypeIgnore(content []byte) (vulnerabilitiesWhitelist, bool) {
+	var config grypeConfig
+	if err := yaml.Unmarshal(content, &config); err != nil || len(config.Ignore) == 0 {
+		return vulnerabilitiesWhitelist{}, false
+	}
+
+	whitelist := vulnerabilitiesWhitelist{GeneralWhitelist: make(map[string]whitelistEntry), Packages: make(map[string]whitelistEntry)}
+	for _, rule := range config.Ignore {
+		entry := whitelistEntry{Description: rule.Reason, Reason: rule.Reason}
+		if entry.Description == "" {
+			entry.Description = "Imported from Grype ignore rules"
+		}
+		versions := ""
+		if rule.Package.Version != "" {
+			versions = "=" + rule.Package.Version
+		}
+		switch {
+		case rule.FixState != "" || rule.Package.Type != "" || rule.Package.Location != "":
+			logger.Fatalf("Could not convert Grype ignore rule %s, fix-state, package type and location are not supported", rule)
+		case rule.Vulnerability != "" && rule.Package.Name != "":
+			whitelist.Rules = append(whitelist.Rules, whitelistRule{
+				Vulnerabilities: []string{rule.Vulnerability},
+				Packages:        []string{rule.Package.Name},
+				Versions:        versions,
+				Description:     entry.Description,
+				Reason:          entry.Reason,
+			})
+		case rule.Vulnerability != "" && versions == "":
+			whitelist.GeneralWhitelist[rule.Vulnerability] = entry
+		case rule.Vulnerability == "" && rule.Package.Name != "":
+			entry.Versions = versions
+			whitelist.Packages[rule.Package.Name] = entry
+		default:
+			logger.Fatalf("Could not convert Grype ignore rule %s, it needs a vulnerability or a package name, and a package name with a package version", rule)
+		}
+	}
+	return whitelist, true
+}
+
+// String describes the Grype ignore rule in log messages
+func (rule grypeIgnoreRule) String() string {
+	criteria := []string{}
+	for _, criterion := range []string{rule.Vulnerability, rule.FixState, rule.Package.Name, rule.Package.Version, rule.Package.Type, rule.Package.Location} {
+		if criterion != "" {
+			criteria = append(criteria, criterion)
+		}
+	}
+	return "[" + strings.Join(criteria, " ") + "]"
+}
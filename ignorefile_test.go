@@ -0,0 +1,86 @@
+package main
+
+import "testing"
+
+func TestParseTrivyIgnore(t *testing.T) {
+	whitelist := parseTrivyIgnore([]byte(`
+# Accept the risk
+CVE-2018-14618
+
+CVE-2019-14697 exp:2025-12-31
+`))
+	if whitelist.GeneralWhitelist["CVE-2018-14618"].Description != "Accept the risk" {
+		t.Errorf("Expected the comment as description, got %v", whitelist.GeneralWhitelist["CVE-2018-14618"])
+	}
+	if whitelist.GeneralWhitelist["CVE-2019-14697"].Expires != "2025-12-31" {
+		t.Errorf("Expected the expiration date, got %v", whitelist.GeneralWhitelist["CVE-2019-14697"])
+	}
+	if !isTrivyIgnoreFile("project/.trivyignore") || isTrivyIgnoreFile("whitelist.yaml") {
+		t.Errorf("Expected only .trivyignore files to be detected")
+	}
+}
+
+func TestParseGrypeIgnore(t *testing.T) {
+	initializeLogger("")
+	whitelist, isGrype := parseGrypeIgnore([]byte(`
+ignore:
+  - vulnerability: CVE-2008-4318
+    reason: Not used
+  - package:
+      name: libcurl
+      version: 1.5.1
+  - vulnerability: CVE-2017-3735
+    package:
+      name: openssl
+      version: 1.0.2k-r0
+`))
+	if !isGrype {
+		t.Fatalf("Expected a Grype configuration")
+	}
+	if whitelist.GeneralWhitelist["CVE-2008-4318"].Reason != "Not used" {
+		t.Errorf("Expected CVE-2008-4318 to be accepted, got %v", whitelist.GeneralWhitelist)
+	}
+	if whitelist.Packages["libcurl"].Versions != "=1.5.1" {
+		t.Errorf("Expected libcurl 1.5.1 to be accepted, got %v", whitelist.Packages)
+	}
+	if _, exists := whitelist.GeneralWhitelist["CVE-2017-3735"]; exists || len(whitelist.Rules) != 1 {
+		t.Fatalf("Expected CVE-2017-3735 to be accepted by a rule for openssl only, got %v and %v", whitelist.GeneralWhitelist, whitelist.Rules)
+	}
+	for _, test := range []struct {
+		vulnerability vulnerabilityInfo
+		accepted      bool
+	}{
+		{vulnerabilityInfo{Vulnerability: "CVE-2017-3735", FeatureName: "openssl", FeatureVersion: "1.0.2k-r0"}, true},
+		{vulnerabilityInfo{Vulnerability: "CVE-2017-3735", FeatureName: "openssl", FeatureVersion: "1.0.2l-r0"}, false},
+		{vulnerabilityInfo{Vulnerability: "CVE-2017-3735", FeatureName: "libressl", FeatureVersion: "1.0.2k-r0"}, false},
+		{vulnerabilityInfo{Vulnerability: "CVE-2017-3736", FeatureName: "openssl", FeatureVersion: "1.0.2k-r0"}, false},
+	} {
+		if _, accepted := lookupWhitelistRule(whitelist.Rules, nil, test.vulnerability); accepted != test.accepted {
+			t.Errorf("Expected %+v to be accepted %t", test.vulnerability, test.accepted)
+		}
+	}
+	if _, isGrype := parseGrypeIgnore([]byte("generalwhitelist:\n  CVE-1: XML\n")); isGrype {
+		t.Errorf("Expected a whitelist not to be a Grype configuration")
+	}
+}
+
+func TestParseGrypeIgnoreUnsupportedRules(t *testing.T) {
+	initializeLogger("")
+	logger.recoverFatal = true
+	defer func() { logger.recoverFatal = false }()
+	for _, rule := range []string{
+		"  - vulnerability: CVE-2008-4318\n    fix-state: not-fixed\n",
+		"  - package:\n      name: libcurl\n      type: npm\n",
+		"  - package:\n      location: /usr/lib/**\n",
+		"  - vulnerability: CVE-2008-4318\n    package:\n      version: 1.5.1\n",
+	} {
+		func() {
+			defer func() {
+				if _, failed := recover().(scanFailure); !failed {
+					t.Errorf("Expected the rule to be rejected:\n%s", rule)
+				}
+			}()
+			parseGrypeIgnore([]byte("ignore:\n" + rule))
+		}()
+	}
+}
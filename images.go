@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// readImagesFile reads the names of the images to scan from a file, or from stdin for -, one image per line,
+// empty lines and lines starting with # are skipped
+func readImagesFile(file string, stdin io.Reader) []string {
+	input := stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			logger.Fatalf("Could not read images file: %v", err)
+		}
+		defer f.Close()
+		input = f
+	}
+	var images []string
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			images = append(images, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		logger.Fatalf("Could not read images file: %v", err)
+	}
+	return images
+}
+
+// imageFilters are the filters of 'docker images --filter'
+var imageFilters = []string{"dangling", "label", "before", "since", "reference"}
+
+// parseImageFilters parses the filters of the images to scan, given as key=value like 'docker images --filter'
+func parseImageFilters(imageFilter []string) filters.Args {
+	args := filters.NewArgs()
+	for _, filter := range imageFilter {
+		var err error
+		if args, err = filters.ParseFlag(filter, args); err != nil {
+			logger.Fatalf("Invalid filter %s given: %v", filter, err)
+		}
+		if !contains(imageFilters, strings.SplitN(filter, "=", 2)[0]) {
+			logger.Fatalf("Invalid filter %s given, valid filters are %s", filter, strings.Join(imageFilters, ", "))
+		}
+	}
+	return args
+}
+
+// listDockerImages returns the names of the images of the Docker daemon that match the filters, every image once
+func listDockerImages(args filters.Args, skipDangling bool) []string {
+	if skipDangling {
+		args.Add("dangling", "false")
+	}
+	images, err := createDockerClient().ImageList(context.Background(), types.ImageListOptions{Filters: args})
+	if err != nil {
+		logger.Fatalf("Could not list Docker images: %v%s", err, explainDockerError("", err))
+	}
+	return imageSummaryNames(images)
+}
+
+// imageSummaryNames names every image by its first tag, images without tag by their image ID
+func imageSummaryNames(images []types.ImageSummary) []string {
+	var names []string
+	for _, image := range images {
+		name := image.ID
+		for _, tag := range image.RepoTags {
+			if tag != "<none>:<none>" {
+				name = tag
+				break
+			}
+		}
+		names = append(names, name)
+	}
+	return names
+}
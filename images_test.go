@@ -0,0 +1,39 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestReadImagesFile(t *testing.T) {
+	initializeLogger("")
+	stdin := strings.NewReader("# images of the release\nmyorg/api:1.0\n\n  myorg/worker:1.0  \r\n")
+	if images := readImagesFile("-", stdin); !reflect.DeepEqual(images, []string{"myorg/api:1.0", "myorg/worker:1.0"}) {
+		t.Errorf("Expected the images of stdin, got %v", images)
+	}
+}
+
+func TestParseImageFilters(t *testing.T) {
+	initializeLogger("")
+	args := parseImageFilters([]string{"label=team=payments", "reference=myorg/*", "label=env=prod"})
+	if labels := args.Get("label"); len(labels) != 2 || !args.ExactMatch("label", "team=payments") {
+		t.Errorf("Expected both label filters, got %v", labels)
+	}
+	if !args.ExactMatch("reference", "myorg/*") || args.Len() != 2 {
+		t.Errorf("Expected the reference filter, got %v", args.Get("reference"))
+	}
+}
+
+func TestImageSummaryNames(t *testing.T) {
+	images := []types.ImageSummary{
+		{ID: "sha256:aaa", RepoTags: []string{"myorg/api:1.0", "myorg/api:latest"}},
+		{ID: "sha256:bbb", RepoTags: []string{"<none>:<none>"}},
+		{ID: "sha256:ccc"},
+	}
+	if names := imageSummaryNames(images); !reflect.DeepEqual(names, []string{"myorg/api:1.0", "sha256:bbb", "sha256:ccc"}) {
+		t.Errorf("Expected every image once by tag or image ID, got %v", names)
+	}
+}
@@ -20,18 +20,18 @@ func TestMain(m *testing.M) {
 
 func TestDebian(t *testing.T) {
 	initializeLogger("")
-	unapproved := scan(scannerConfig{
-		"debian:jessie",
-		vulnerabilitiesWhitelist{},
-		"http://127.0.0.1:6060",
-		*ip,
-		"",
-		"Unknown",
-		true,
-		false,
-		true,
+	report := scan(scannerConfig{
+		imageName:          "debian:jessie",
+		whitelist:          vulnerabilitiesWhitelist{},
+		clairURL:           "http://127.0.0.1:6060",
+		scannerIP:          *ip,
+		format:             "table",
+		whitelistThreshold: "Unknown",
+		reportAll:          true,
+		quiet:              false,
+		exitWhenNoFeatures: true,
 	})
-	if len(unapproved) == 0 {
+	if report == nil || len(report.Unapproved) == 0 {
 		t.Errorf("No vulnerabilities, expecting some")
 	}
 }
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// writeJUnitReport writes the report as JUnit XML, unapproved vulnerabilities fail and approved ones are skipped
+func writeJUnitReport(w io.Writer, report *vulnerabilityReport) error {
+	suite := junitTestSuite{Name: report.Image, TestCases: []junitTestCase{}}
+	for _, vulnerability := range report.Vulnerabilities {
+		testCase := junitTestCase{
+			Name:      vulnerability.Vulnerability + " " + vulnerability.FeatureName + " " + vulnerability.FeatureVersion,
+			ClassName: report.Image,
+		}
+		if vulnerability.Status == "Approved" {
+			testCase.Skipped = &junitSkipped{Message: "Approved by whitelist"}
+			suite.Skipped++
+		} else {
+			testCase.Failure = &junitFailure{
+				Message: vulnerability.Severity + " " + vulnerability.Vulnerability,
+				Type:    vulnerability.Severity,
+				Text:    junitFailureText(vulnerability),
+			}
+			suite.Failures++
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	suite.Tests = len(suite.TestCases)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitTestSuites{
+		Name:     toolName,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Skipped:  suite.Skipped,
+		Suites:   []junitTestSuite{suite},
+	}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// junitInstructionText describes the Dockerfile instruction that added the vulnerable package, if known
+func junitInstructionText(vulnerability vulnerabilityInfo) string {
+	if vulnerability.InstructionLine == 0 {
+		return ""
+	}
+	return "Added by Dockerfile instruction: line " + strconv.Itoa(vulnerability.InstructionLine) + " " + vulnerability.Instruction + "\n"
+}
+
+// junitFailureText describes the vulnerable package, the fixed version and the advisory of a vulnerability
+func junitFailureText(vulnerability vulnerabilityInfo) string {
+	fixedBy := vulnerability.FixedBy
+	if fixedBy == "" {
+		fixedBy = "no fix available"
+	}
+	cvss := formatCVSS(vulnerability)
+	if cvss == "" {
+		cvss = "unknown"
+	}
+	epss := formatEPSS(vulnerability)
+	if epss == "" {
+		epss = "unknown"
+	}
+	return "Package: " + vulnerability.FeatureName + "\n" +
+		"Installed version: " + vulnerability.FeatureVersion + "\n" +
+		"Fixed version: " + fixedBy + "\n" +
+		"CVSS: " + cvss + "\n" +
+		"EPSS: " + epss + "\n" +
+		"Known exploited: " + strconv.FormatBool(vulnerability.KnownExploited) + "\n" +
+		"Advisory: " + vulnerability.Link + "\n" +
+		"Added by layer: " + vulnerability.AddedBy + "\n" +
+		junitInstructionText(vulnerability) + "\n" +
+		vulnerability.Description
+}
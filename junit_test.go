@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestWriteJUnitReport(t *testing.T) {
+	report := &vulnerabilityReport{
+		Image: "alpine:3.5",
+		Vulnerabilities: []vulnerabilityInfo{
+			{FeatureName: "zlib", FeatureVersion: "1.2.8", Vulnerability: "CVE-2016-9840", Severity: "High", FixedBy: "1.2.11", Status: "Unapproved"},
+			{FeatureName: "musl", FeatureVersion: "1.1.15", Vulnerability: "CVE-2017-15650", Severity: "Low", Status: "Approved"},
+		},
+	}
+
+	var buffer bytes.Buffer
+	if err := writeJUnitReport(&buffer, report); err != nil {
+		t.Fatalf("Could not write JUnit report: %v", err)
+	}
+
+	var suites junitTestSuites
+	if err := xml.Unmarshal(buffer.Bytes(), &suites); err != nil {
+		t.Fatalf("JUnit report is not XML: %v", err)
+	}
+	if suites.Tests != 2 || suites.Failures != 1 || suites.Skipped != 1 || len(suites.Suites) != 1 {
+		t.Fatalf("Expected a suite of 2 tests with 1 failure and 1 skipped, got %+v", suites)
+	}
+	testCases := suites.Suites[0].TestCases
+	if testCases[0].Name != "CVE-2016-9840 zlib 1.2.8" || testCases[0].ClassName != "alpine:3.5" {
+		t.Errorf("Expected the test case to be named after the vulnerability and package, got %+v", testCases[0])
+	}
+	if failure := testCases[0].Failure; failure == nil || failure.Type != "High" || !strings.Contains(failure.Text, "Fixed version: 1.2.11") {
+		t.Errorf("Expected the unapproved vulnerability to fail with its fixed version, got %+v", failure)
+	}
+	if testCases[1].Skipped == nil || testCases[1].Failure != nil {
+		t.Errorf("Expected the approved vulnerability to be skipped, got %+v", testCases[1])
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// newKubeClient creates the client of the cluster of the context of the kubeconfig, the current context without context. The kubeconfig is loaded like kubectl does:
+// the file of configPath, else the files of KUBECONFIG or ~/.kube/config, inside a pod without kubeconfig the service account of the pod is used
+func newKubeClient(configPath string, contextName string) kubernetes.Interface {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = configPath
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: contextName}).ClientConfig()
+	if err != nil {
+		logger.Fatalf("Could not connect to Kubernetes: %v", err)
+	}
+	config.Proxy = newTransport().Proxy
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		logger.Fatalf("Could not connect to Kubernetes: %v", err)
+	}
+	return client
+}
+
+// listPods lists the pods of the namespace, of all namespaces without namespace, 500 pods per request
+func listPods(client kubernetes.Interface, namespace string) ([]corev1.Pod, error) {
+	var pods []corev1.Pod
+	options := metav1.ListOptions{Limit: 500}
+	for {
+		list, err := client.CoreV1().Pods(namespace).List(context.Background(), options)
+		if err != nil {
+			return nil, err
+		}
+		pods = append(pods, list.Items...)
+		if options.Continue = list.Continue; options.Continue == "" {
+			return pods, nil
+		}
+	}
+}
+
+// podWorkload names the workload that runs the pod as namespace/kind/name, pods of a ReplicaSet of a Deployment are named after the Deployment
+func podWorkload(pod corev1.Pod) string {
+	kind, name := "Pod", pod.Name
+	if len(pod.OwnerReferences) > 0 {
+		kind, name = pod.OwnerReferences[0].Kind, pod.OwnerReferences[0].Name
+	}
+	if hash := pod.Labels["pod-template-hash"]; kind == "ReplicaSet" && hash != "" && strings.HasSuffix(name, "-"+hash) {
+		kind, name = "Deployment", strings.TrimSuffix(name, "-"+hash)
+	}
+	return pod.Namespace + "/" + kind + "/" + name
+}
+
+// workloadImages returns every image of the containers of the pods once, with the workloads running every image
+func workloadImages(pods []corev1.Pod) ([]string, map[string][]string) {
+	var images []string
+	imageWorkloads := make(map[string][]string)
+	for _, pod := range pods {
+		workload := podWorkload(pod)
+		for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+			workloads, exists := imageWorkloads[container.Image]
+			if !exists {
+				images = append(images, container.Image)
+			}
+			if !contains(workloads, workload) {
+				imageWorkloads[container.Image] = append(workloads, workload)
+			}
+		}
+	}
+	for _, workloads := range imageWorkloads {
+		sort.Strings(workloads)
+	}
+	return images, imageWorkloads
+}
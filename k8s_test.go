@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestKubernetesWorkloadImages(t *testing.T) {
+	initializeLogger("")
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bearer secret" || r.URL.Path != "/api/v1/namespaces/payments/pods" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Query().Get("continue") == "" {
+			w.Write([]byte(`{"kind": "PodList", "apiVersion": "v1", "metadata": {"continue": "next"}, "items": [
+				{"metadata": {"name": "api-7d9f8-x1", "namespace": "payments", "labels": {"pod-template-hash": "7d9f8"}, "ownerReferences": [{"kind": "ReplicaSet", "name": "api-7d9f8"}]},
+				 "spec": {"initContainers": [{"name": "migrate", "image": "myorg/migrate:1.0"}], "containers": [{"name": "api", "image": "myorg/api:1.0"}]}},
+				{"metadata": {"name": "api-7d9f8-x2", "namespace": "payments", "labels": {"pod-template-hash": "7d9f8"}, "ownerReferences": [{"kind": "ReplicaSet", "name": "api-7d9f8"}]},
+				 "spec": {"containers": [{"name": "api", "image": "myorg/api:1.0"}]}}]}`))
+			return
+		}
+		w.Write([]byte(`{"kind": "PodList", "apiVersion": "v1", "metadata": {}, "items": [
+			{"metadata": {"name": "db-0", "namespace": "payments", "ownerReferences": [{"kind": "StatefulSet", "name": "db"}]},
+			 "spec": {"containers": [{"name": "db", "image": "postgres:15"}, {"name": "sidecar", "image": "myorg/api:1.0"}]}}]}`))
+	}))
+	defer server.Close()
+
+	dir, _ := ioutil.TempDir("", "clair-kubeconfig")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "token"), []byte("secret\n"), 0600)
+	ca := base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	config := `current-context: dev
+contexts:
+- name: prod
+  context: {cluster: prod, user: ci}
+clusters:
+- name: prod
+  cluster: {server: "` + server.URL + `", certificate-authority-data: ` + ca + `}
+users:
+- name: ci
+  user: {tokenFile: token}
+`
+	ioutil.WriteFile(filepath.Join(dir, "config"), []byte(config), 0600)
+
+	pods, err := listPods(newKubeClient(filepath.Join(dir, "config"), "prod"), "payments")
+	if err != nil || len(pods) != 3 {
+		t.Fatalf("Expected the pods of both pages, got %d %v", len(pods), err)
+	}
+	images, workloads := workloadImages(pods)
+	if !reflect.DeepEqual(images, []string{"myorg/migrate:1.0", "myorg/api:1.0", "postgres:15"}) {
+		t.Errorf("Expected every image once, got %v", images)
+	}
+	if expected := []string{"payments/Deployment/api", "payments/StatefulSet/db"}; !reflect.DeepEqual(workloads["myorg/api:1.0"], expected) {
+		t.Errorf("Expected the workloads running the image %v, got %v", expected, workloads["myorg/api:1.0"])
+	}
+}
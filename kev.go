@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// kevCacheTTL is how long the downloaded catalog is used before it is downloaded again
+const kevCacheTTL = 24 * time.Hour
+
+// kevCatalogURL is the JSON feed of the CISA Known Exploited Vulnerabilities catalog
+var kevCatalogURL = "https://www.cisa.gov/sites/default/files/feeds/known_exploited_vulnerabilities.json"
+
+type kevCatalog struct {
+	Vulnerabilities []struct {
+		CVEID string `json:"cveID"`
+	} `json:"vulnerabilities"`
+}
+
+// flagKnownExploited flags the vulnerabilities that are in the CISA Known Exploited Vulnerabilities catalog
+func flagKnownExploited(vulnerabilities []vulnerabilityInfo) {
+	content, err := cachedDownload("known_exploited_vulnerabilities.json", kevCacheTTL, kevCatalogURL, nil)
+	if err != nil {
+		logger.Fatalf("Could not download the CISA KEV catalog: %v", err)
+	}
+	var catalog kevCatalog
+	if err = json.Unmarshal(content, &catalog); err != nil {
+		logger.Fatalf("Could not read the CISA KEV catalog: %v", err)
+	}
+
+	knownExploited := make(map[string]bool, len(catalog.Vulnerabilities))
+	for _, vulnerability := range catalog.Vulnerabilities {
+		knownExploited[vulnerability.CVEID] = true
+	}
+	for i := range vulnerabilities {
+		vulnerabilities[i].KnownExploited = knownExploited[vulnerabilities[i].Vulnerability]
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestFlagKnownExploited(t *testing.T) {
+	initializeLogger("")
+	defer func(dir string) { cacheDir = dir }(cacheDir)
+	cacheDir, _ = ioutil.TempDir("", "clair-scanner-cache")
+	defer os.RemoveAll(cacheDir)
+
+	downloads := 0
+	cisa := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		fmt.Fprint(w, `{"title": "CISA Catalog of Known Exploited Vulnerabilities", "catalogVersion": "2017.09.24", "count": 2, "vulnerabilities": [
+			{"cveID": "CVE-2017-5638", "vendorProject": "Apache", "product": "Struts", "dateAdded": "2021-11-03"},
+			{"cveID": "CVE-2016-9840", "vendorProject": "zlib", "product": "zlib", "dateAdded": "2021-11-03"}]}`)
+	}))
+	defer cisa.Close()
+	defer func(url string) { kevCatalogURL = url }(kevCatalogURL)
+	kevCatalogURL = cisa.URL
+
+	vulnerabilities := []vulnerabilityInfo{
+		{Vulnerability: "CVE-2016-9840", FeatureName: "zlib", Severity: "High"},
+		{Vulnerability: "CVE-2017-15650", FeatureName: "musl", Severity: "High"},
+		{Vulnerability: "CVE-2017-5638", FeatureName: "struts", Severity: "Low"},
+	}
+	flagKnownExploited(vulnerabilities)
+	flagKnownExploited(vulnerabilities)
+	if downloads != 1 {
+		t.Errorf("Expected the catalog to be downloaded once and then read from the cache, got %d downloads", downloads)
+	}
+	if !vulnerabilities[0].KnownExploited || vulnerabilities[1].KnownExploited || !vulnerabilities[2].KnownExploited {
+		t.Errorf("Expected only the CVEs of the catalog to be known exploited, got %+v", vulnerabilities)
+	}
+
+	config := scannerConfig{
+		imageName:          "alpine:3.5",
+		whitelistThreshold: "High",
+		whitelist:          vulnerabilitiesWhitelist{GeneralWhitelist: map[string]whitelistEntry{"CVE-2016-9840": {Reason: "not reachable"}, "CVE-2017-15650": {Reason: "not reachable"}}},
+	}
+	if unapproved := checkForUnapprovedVulnerabilities(config, vulnerabilities); len(unapproved) != 0 {
+		t.Errorf("Expected the whitelist and the severity threshold to approve every vulnerability without --fail-on-kev, got %v", unapproved)
+	}
+	config.failOnKEV = true
+	unapproved := checkForUnapprovedVulnerabilities(config, vulnerabilities)
+	if len(unapproved) != 2 || !contains(unapproved, "CVE-2016-9840") || !contains(unapproved, "CVE-2017-5638") {
+		t.Errorf("Expected --fail-on-kev to fail on the whitelisted and the low known exploited vulnerabilities, got %v", unapproved)
+	}
+	if vulnerabilities[0].Status != "Unapproved" || vulnerabilities[1].Status != "Approved" {
+		t.Errorf("Expected only the known exploited vulnerabilities to be unapproved, got %+v", vulnerabilities)
+	}
+}
+
+func TestFlagKnownExploitedInvalidCatalog(t *testing.T) {
+	initializeLogger("")
+	defer func(dir string) { cacheDir = dir }(cacheDir)
+	cacheDir, _ = ioutil.TempDir("", "clair-scanner-cache")
+	defer os.RemoveAll(cacheDir)
+
+	cisa := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html>Maintenance</html>`)
+	}))
+	defer cisa.Close()
+	defer func(url string) { kevCatalogURL = url }(kevCatalogURL)
+	kevCatalogURL = cisa.URL
+
+	logger.recoverFatal = true
+	defer func() { logger.recoverFatal = false }()
+	defer func() {
+		if _, failed := recover().(scanFailure); !failed {
+			t.Errorf("Expected a catalog that is not JSON to fail the scan")
+		}
+	}()
+	flagKnownExploited([]vulnerabilityInfo{{Vulnerability: "CVE-2016-9840"}})
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+var (
+	cveIDPattern     = regexp.MustCompile(`^CVE-\d{4}-\d{4,}$`)
+	imageNamePattern = regexp.MustCompile(`^[a-z0-9]+(?:[._-][a-z0-9]+)*(?::[0-9]+)?(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*(?::[\w][\w.-]{0,127})?(?:@sha256:[a-f0-9]{64})?$`)
+	whitelistKeys    = []string{"extends", "generalwhitelist", "images", "packages", "rules"}
+	entryKeys        = []string{"description", "expires", "owner", "reason", "versions"}
+	ruleKeys         = []string{"severities", "namespaces", "images", "description", "expires", "owner", "reason"}
+)
+
+// lintWhitelist validates the content of a whitelist file and returns every problem found, a misparsed whitelist otherwise results in unexpected failed builds
+func lintWhitelist(content []byte) []string {
+	var document yaml.MapSlice
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return []string{fmt.Sprintf("not valid YAML: %v", err)}
+	}
+
+	problems := lintDuplicateKeys("", document)
+	for _, item := range document {
+		key := fmt.Sprint(item.Key)
+		switch key {
+		case "extends":
+			if _, ok := item.Value.(string); !ok {
+				problems = append(problems, "extends: must be a path or URL")
+			}
+		case "generalwhitelist":
+			problems = append(problems, lintEntries(key, item.Value, lintCVEID)...)
+		case "packages":
+			problems = append(problems, lintEntries(key, item.Value, lintPackageName)...)
+		case "images":
+			images, _ := item.Value.(yaml.MapSlice)
+			if item.Value != nil && images == nil {
+				problems = append(problems, "images: must be a mapping of image names")
+			}
+			for _, image := range images {
+				name := fmt.Sprint(image.Key)
+				problems = append(problems, lintImageName("images."+name, name)...)
+				problems = append(problems, lintEntries("images."+name, image.Value, lintCVEID)...)
+			}
+		case "rules":
+			problems = append(problems, lintRules(item.Value)...)
+		default:
+			problems = append(problems, fmt.Sprintf("%s: unknown key, expected one of %s", key, strings.Join(whitelistKeys, ", ")))
+		}
+	}
+
+	var whitelist vulnerabilitiesWhitelist
+	if err := yaml.Unmarshal(content, &whitelist); err != nil {
+		problems = append(problems, fmt.Sprintf("could not be parsed: %v", err))
+	}
+	return problems
+}
+
+// lintDuplicateKeys finds keys that are given more than once in the same mapping, YAML silently keeps only the last one
+func lintDuplicateKeys(prefix string, mapping yaml.MapSlice) []string {
+	problems := []string{}
+	seen := make(map[string]bool)
+	for _, item := range mapping {
+		key := fmt.Sprint(item.Key)
+		if seen[key] {
+			problems = append(problems, fmt.Sprintf("%s%s: duplicate key, only the last one is used", prefix, key))
+		}
+		seen[key] = true
+		if nested, ok := item.Value.(yaml.MapSlice); ok {
+			problems = append(problems, lintDuplicateKeys(prefix+key+".", nested)...)
+		}
+	}
+	return problems
+}
+
+// lintEntries validates the keys and entries of a whitelist section
+func lintEntries(section string, value interface{}, lintKey func(string, string) []string) []string {
+	if value == nil {
+		return nil
+	}
+	entries, ok := value.(yaml.MapSlice)
+	if !ok {
+		return []string{section + ": must be a mapping"}
+	}
+	problems := []string{}
+	for _, entry := range entries {
+		key := fmt.Sprint(entry.Key)
+		problems = append(problems, lintKey(section+"."+key, key)...)
+		switch fields := entry.Value.(type) {
+		case string, nil:
+		case yaml.MapSlice:
+			problems = append(problems, lintFields(section+"."+key, fields, entryKeys)...)
+		default:
+			problems = append(problems, fmt.Sprintf("%s.%s: must be a description or a mapping with %s", section, key, strings.Join(entryKeys, ", ")))
+		}
+	}
+	return problems
+}
+
+// lintFields validates the field names, expiration date and version range of an entry or rule
+func lintFields(location string, fields yaml.MapSlice, known []string) []string {
+	problems := []string{}
+	for _, field := range fields {
+		name := fmt.Sprint(field.Key)
+		value := fmt.Sprint(field.Value)
+		switch {
+		case !contains(known, name):
+			problems = append(problems, fmt.Sprintf("%s.%s: unknown field, expected one of %s", location, name, strings.Join(known, ", ")))
+		case name == "expires":
+			if _, err := time.Parse(whitelistDateFormat, value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s.expires: invalid date %s, use YYYY-MM-DD", location, value))
+			}
+		case name == "versions":
+			if !strings.HasPrefix(location, "packages.") {
+				problems = append(problems, fmt.Sprintf("%s.versions: only packages can have a version range", location))
+			} else if !validateVersionRange(value) {
+				problems = append(problems, fmt.Sprintf("%s.versions: invalid version range %s", location, value))
+			}
+		}
+	}
+	return problems
+}
+
+// lintRules validates the criteria of the whitelist rules
+func lintRules(value interface{}) []string {
+	rules, ok := value.([]interface{})
+	if !ok && value != nil {
+		return []string{"rules: must be a list"}
+	}
+	problems := []string{}
+	for i, value := range rules {
+		location := fmt.Sprintf("rules[%d]", i)
+		rule, ok := value.(yaml.MapSlice)
+		if !ok {
+			problems = append(problems, location+": must be a mapping")
+			continue
+		}
+		problems = append(problems, lintFields(location, rule, ruleKeys)...)
+		criteria := 0
+		for _, field := range rule {
+			values, _ := field.Value.([]interface{})
+			switch field.Key {
+			case "severities", "namespaces", "images":
+				criteria++
+				if field.Value != nil && values == nil {
+					problems = append(problems, fmt.Sprintf("%s.%s: must be a list", location, field.Key))
+				}
+			}
+			for _, value := range values {
+				switch field.Key {
+				case "severities":
+					if _, exists := SeverityMap[fmt.Sprint(value)]; !exists {
+						problems = append(problems, fmt.Sprintf("%s.severities: invalid CVE severity %v", location, value))
+					}
+				case "images":
+					problems = append(problems, lintImageName(location+".images", fmt.Sprint(value))...)
+				}
+			}
+		}
+		if criteria == 0 {
+			problems = append(problems, location+": needs severities, namespaces or images")
+		}
+	}
+	return problems
+}
+
+// lintCVEID reports CVE ids that are not formatted as CVE-YYYY-NNNN, Clair would never report them so the entry has no effect
+func lintCVEID(location string, key string) []string {
+	if isWhitelistRegexp(key) {
+		if _, err := regexp.Compile(key[1 : len(key)-1]); err != nil {
+			return []string{fmt.Sprintf("%s: invalid regular expression: %v", location, err)}
+		}
+		return nil
+	}
+	if _, err := path.Match(key, ""); err != nil {
+		return []string{fmt.Sprintf("%s: invalid pattern: %v", location, err)}
+	}
+	if strings.HasPrefix(strings.ToUpper(key), "CVE") && !strings.ContainsAny(key, "*?[") && !cveIDPattern.MatchString(key) {
+		return []string{fmt.Sprintf("%s: malformed CVE id, expected CVE-YYYY-NNNN", location)}
+	}
+	return nil
+}
+
+// lintPackageName reports invalid package name patterns
+func lintPackageName(location string, name string) []string {
+	if _, err := path.Match(name, ""); err != nil {
+		return []string{fmt.Sprintf("%s: invalid pattern: %v", location, err)}
+	}
+	return nil
+}
+
+// lintImageName reports image names that can never match an image, like names with uppercase letters or a registry scheme
+func lintImageName(location string, name string) []string {
+	if _, err := path.Match(name, ""); err != nil {
+		return []string{fmt.Sprintf("%s: invalid image pattern: %v", location, err)}
+	}
+	if strings.ContainsAny(name, "*?[") {
+		return nil
+	}
+	if !imageNamePattern.MatchString(name) {
+		return []string{fmt.Sprintf("%s: invalid image name, use a lowercase repository optionally followed by :tag or @sha256:digest", location)}
+	}
+	return nil
+}
+
+// lintWhitelistFile lints the whitelist file and logs every problem, it returns false when problems were found
+func lintWhitelistFile(whitelistFile string, header string) bool {
+	problems := lintWhitelist(readWhitelistFile(whitelistFile, header))
+	sort.Strings(problems)
+	for _, problem := range problems {
+		logger.Errorf("%s: %s", whitelistFile, problem)
+	}
+	if len(problems) > 0 {
+		logger.Errorf("Whitelist [%s] contains %d problems", whitelistFile, len(problems))
+		return false
+	}
+	logger.Infof("Whitelist [%s] is valid", whitelistFile)
+	return true
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLintWhitelist(t *testing.T) {
+	problems := lintWhitelist([]byte(`
+generalwhitelist:
+  CVE-2017-6055: XML
+  CVE-2017-605: typo
+  CVE-2016-*: glob
+  CVE-2017-6055: duplicate
+  CVE-2017-5586:
+    descripton: typo
+    expires: 31-12-2025
+packages:
+  openssl:
+    versions: "~1.0"
+images:
+  Ubuntu:
+    CVE-2017-5230: Java
+  registry:777/ubuntu:16.04:
+    CVE-2017-5230: Java
+rules:
+  - severities: [Negligable]
+  - description: no criteria
+whitelist:
+  CVE-2017-5230: Java
+`))
+	expected := []string{
+		"generalwhitelist.CVE-2017-605: malformed CVE id",
+		"generalwhitelist.CVE-2017-6055: duplicate key",
+		"generalwhitelist.CVE-2017-5586.descripton: unknown field",
+		"generalwhitelist.CVE-2017-5586.expires: invalid date",
+		"packages.openssl.versions: invalid version range",
+		"images.Ubuntu: invalid image name",
+		"rules[0].severities: invalid CVE severity Negligable",
+		"rules[1]: needs severities, namespaces or images",
+		"whitelist: unknown key",
+	}
+	if len(problems) != len(expected) {
+		t.Errorf("Expected %d problems, got %d: %v", len(expected), len(problems), problems)
+	}
+	for _, prefix := range expected {
+		found := false
+		for _, problem := range problems {
+			found = found || strings.HasPrefix(problem, prefix)
+		}
+		if !found {
+			t.Errorf("Expected problem %s, got %v", prefix, problems)
+		}
+	}
+
+	if problems := lintWhitelist([]byte("generalwhitelist:\n  CVE-2017-6055: XML\n")); len(problems) != 0 {
+		t.Errorf("Expected no problems, got %v", problems)
+	}
+}
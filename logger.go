@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	levelTags   = map[logrus.Level]string{logrus.DebugLevel: "[DEBU]", logrus.InfoLevel: "[INFO]", logrus.WarnLevel: "[WARN]", logrus.ErrorLevel: "[ERRO]"}
+	levelColors = map[logrus.Level]string{logrus.DebugLevel: DebugColor, logrus.InfoLevel: InfoColor, logrus.WarnLevel: WarningColor, logrus.ErrorLevel: ErrorColor}
+)
+
+// scanLogger is a leveled logrus logger that exits with exitCodeError on fatal errors, so CI can tell a broken scan from a vulnerable image
+type scanLogger struct {
+	*logrus.Logger
+	file         *fileHook // writes the messages to the log file as well, nil without log file
+	recoverFatal bool      // in server mode a fatal error fails the scan instead of exiting the server
+}
+
+// textFormatter formats a message as a line with its time and level tag, the tag is colored on the console
+type textFormatter struct {
+	color bool
+}
+
+// fileHook writes every logged message to the log file with its own formatter, so the file has no color codes
+type fileHook struct {
+	mutex     sync.Mutex
+	writer    io.Writer
+	formatter logrus.Formatter
+}
+
+// newScanLogger creates a logger writing text messages of the info level and above to out
+func newScanLogger(out io.Writer, color bool) *scanLogger {
+	return &scanLogger{Logger: &logrus.Logger{Out: out, Formatter: &textFormatter{color: color}, Hooks: make(logrus.LevelHooks), Level: logrus.InfoLevel}}
+}
+
+// initializeLogger logs to stderr, and to the log file when given, at the info level as text
+func initializeLogger(logFile string) {
+	logger = newScanLogger(os.Stderr, true)
+	if logFile != "" {
+		file, err := os.OpenFile(logFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not initialize logging file %v\n", err)
+			os.Exit(exitCodeError)
+		}
+		logger.file = &fileHook{writer: file, formatter: &textFormatter{}}
+		logger.Hooks.Add(logger.file)
+	}
+}
+
+// configureLogger sets the least severe level of the messages to log and the log format
+func configureLogger(level string, format string) {
+	parsed, err := logrus.ParseLevel(level)
+	if err != nil || parsed < logrus.ErrorLevel {
+		logger.Fatalf("Invalid log level %s given", level)
+	}
+	logger.Level = parsed
+	switch format {
+	case "text":
+		logger.setFormatter(&textFormatter{color: true}, &textFormatter{})
+	case "json":
+		formatter := &logrus.JSONFormatter{TimestampFormat: time.RFC3339Nano}
+		logger.setFormatter(formatter, formatter)
+	default:
+		logger.Fatalf("Invalid log format %s given", format)
+	}
+}
+
+// setFormatter sets the formatter of the console and of the log file
+func (l *scanLogger) setFormatter(console logrus.Formatter, file logrus.Formatter) {
+	l.Formatter = console
+	if l.file != nil {
+		l.file.formatter = file
+	}
+}
+
+// Format formats the message as "2006/01/02 15:04:05 [INFO] ▶ message"
+func (f *textFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	tag, exists := levelTags[entry.Level]
+	if !exists {
+		tag = levelTags[logrus.ErrorLevel]
+	}
+	if f.color {
+		tag = fmt.Sprintf(levelColors[entry.Level], tag)
+	}
+	return []byte(fmt.Sprintf("%s %s ▶ %s\n", entry.Time.Format("2006/01/02 15:04:05"), tag, entry.Message)), nil
+}
+
+// Levels returns every level, the level of the logger decides which messages reach the hook
+func (hook *fileHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire writes the message to the log file
+func (hook *fileHook) Fire(entry *logrus.Entry) error {
+	line, err := hook.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	hook.mutex.Lock()
+	defer hook.mutex.Unlock()
+	_, err = hook.writer.Write(line)
+	return err
+}
+
+// Fatal logs the error and exits with exitCodeError
+func (l *scanLogger) Fatal(args ...interface{}) {
+	l.fatal(fmt.Sprint(args...))
+}
+
+// Fatalf logs the formatted error and exits with exitCodeError
+func (l *scanLogger) Fatalf(format string, args ...interface{}) {
+	l.fatal(fmt.Sprintf(format, args...))
+}
+
+// fatal logs the error, exports the trace of the scan and exits, or in server mode fails the scan
+func (l *scanLogger) fatal(message string) {
+	l.Error(message)
+	tracer.abort(message)
+	if l.recoverFatal {
+		panic(scanFailure(message))
+	}
+	os.Exit(exitCodeError)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestLoggerLevel(t *testing.T) {
+	var output bytes.Buffer
+	logger := newScanLogger(&output, false)
+	logger.Level = logrus.WarnLevel
+	logger.Infof("Scanning %s", "alpine:3.5")
+	logger.Warnf("Whitelist entry %s matches no vulnerability", "CVE-2017-1")
+	logger.Error("Could not scan")
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected only the warning and the error, got %v", lines)
+	}
+	if !strings.HasSuffix(lines[0], " [WARN] ▶ Whitelist entry CVE-2017-1 matches no vulnerability") || !strings.HasSuffix(lines[1], " [ERRO] ▶ Could not scan") {
+		t.Errorf("Expected the messages with their level, got %v", lines)
+	}
+}
+
+func TestLoggerJSON(t *testing.T) {
+	var output bytes.Buffer
+	logger := newScanLogger(&output, true)
+	logger.Level = logrus.DebugLevel
+	logger.setFormatter(&logrus.JSONFormatter{}, nil)
+	logger.Debug("Detected Clair API v4")
+
+	var entry map[string]string
+	if err := json.Unmarshal(output.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected a JSON object, got %s", output.String())
+	}
+	if entry["level"] != "debug" || entry["msg"] != "Detected Clair API v4" || entry["time"] == "" {
+		t.Errorf("Expected the debug message, got %+v", entry)
+	}
+}
+
+func TestLoggerFile(t *testing.T) {
+	file, _ := ioutil.TempFile("", "clair-scanner-log")
+	file.Close()
+	defer os.Remove(file.Name())
+	initializeLogger(file.Name())
+	defer initializeLogger("")
+	logger.Out = ioutil.Discard
+	logger.Debug("Not logged at the info level")
+	logger.Warn("Whitelist entry CVE-2017-1 matches no vulnerability")
+
+	content, _ := ioutil.ReadFile(file.Name())
+	if !strings.HasSuffix(string(content), " [WARN] ▶ Whitelist entry CVE-2017-1 matches no vulnerability\n") || strings.Count(string(content), "\n") != 1 {
+		t.Errorf("Expected the warning without color codes in the log file, got %q", content)
+	}
+}
+
+func TestConfigureLogger(t *testing.T) {
+	initializeLogger("")
+	defer initializeLogger("")
+	configureLogger("WARN", "json")
+	if _, isJSON := logger.Formatter.(*logrus.JSONFormatter); logger.Level != logrus.WarnLevel || !isJSON {
+		t.Errorf("Expected the warn level and json format, got %v %T", logger.Level, logger.Formatter)
+	}
+}
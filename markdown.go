@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// writeMarkdownReport writes a compact summary of the report grouped by severity, meant to be posted as a pull request comment
+func writeMarkdownReport(w io.Writer, report *vulnerabilityReport) error {
+	var md strings.Builder
+
+	fmt.Fprintf(&md, "### clair-scanner report for `%s`\n\n", report.Image)
+	if len(report.Vulnerabilities) == 0 {
+		md.WriteString("No vulnerabilities found.\n")
+		_, err := io.WriteString(w, md.String())
+		return err
+	}
+	fmt.Fprintf(&md, "**%d** vulnerabilities found, **%d** unapproved.\n\n", len(report.Vulnerabilities), len(report.Unapproved))
+
+	totals := make(map[string]int)
+	unapproved := make(map[string]int)
+	for _, vulnerability := range report.Vulnerabilities {
+		totals[vulnerability.Severity]++
+		if vulnerability.Status != "Approved" {
+			unapproved[vulnerability.Severity]++
+		}
+	}
+	severities := make([]string, 0, len(totals))
+	for severity := range totals {
+		severities = append(severities, severity)
+	}
+	sort.Slice(severities, func(i, j int) bool {
+		return SeverityMap[severities[i]] < SeverityMap[severities[j]]
+	})
+
+	md.WriteString("| Severity | Total | Unapproved |\n|---|---:|---:|\n")
+	for _, severity := range severities {
+		fmt.Fprintf(&md, "| %s | %d | %d |\n", severity, totals[severity], unapproved[severity])
+	}
+
+	if len(report.Unapproved) > 0 {
+		vulnerabilities := make([]vulnerabilityInfo, 0, len(report.Unapproved))
+		for _, vulnerability := range report.Vulnerabilities {
+			if vulnerability.Status != "Approved" {
+				vulnerabilities = append(vulnerabilities, vulnerability)
+			}
+		}
+		sortBySeverity(vulnerabilities)
+
+		md.WriteString("\n<details>\n<summary>Unapproved vulnerabilities</summary>\n\n")
+		md.WriteString("| Severity | CVSS | EPSS | CVE | Package | Version | Fixed By |\n|---|---:|---:|---|---|---|---|\n")
+		for _, vulnerability := range vulnerabilities {
+			cve := vulnerability.Vulnerability
+			if vulnerability.Link != "" {
+				cve = "[" + cve + "](" + vulnerability.Link + ")"
+			}
+			if vulnerability.KnownExploited {
+				cve += " :warning: known exploited"
+			}
+			cvss := ""
+			if vulnerability.CVSSScore > 0 {
+				cvss = fmt.Sprintf("%.1f", vulnerability.CVSSScore)
+			}
+			epss := ""
+			if vulnerability.EPSS > 0 {
+				epss = fmt.Sprintf("%.2f%%", vulnerability.EPSS*100)
+			}
+			fmt.Fprintf(&md, "| %s | %s | %s | %s | %s | %s | %s |\n", vulnerability.Severity, cvss, epss, cve,
+				escapeMarkdown(vulnerability.FeatureName), escapeMarkdown(vulnerability.FeatureVersion), escapeMarkdown(vulnerability.FixedBy))
+		}
+		md.WriteString("\n</details>\n")
+	}
+
+	_, err := io.WriteString(w, md.String())
+	return err
+}
+
+// escapeMarkdown escapes the characters that would break a markdown table cell
+func escapeMarkdown(text string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(text)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteMarkdownReport(t *testing.T) {
+	report := &vulnerabilityReport{
+		Image:      "alpine:3.5",
+		Unapproved: []string{"CVE-2016-9840"},
+		Vulnerabilities: []vulnerabilityInfo{
+			{FeatureName: "musl", FeatureVersion: "1.1.15", Vulnerability: "CVE-2017-15650", Severity: "Low", Status: "Approved"},
+			{FeatureName: "zlib", FeatureVersion: "1.2.8|r0", Vulnerability: "CVE-2016-9840", Severity: "High", Status: "Unapproved", Link: "https://example.com/CVE-2016-9840", CVSSScore: 8.8},
+		},
+	}
+
+	var buffer bytes.Buffer
+	if err := writeMarkdownReport(&buffer, report); err != nil {
+		t.Fatalf("Could not write markdown report: %v", err)
+	}
+	markdown := buffer.String()
+	if !strings.Contains(markdown, "**2** vulnerabilities found, **1** unapproved.") {
+		t.Errorf("Expected the totals of the report, got %s", markdown)
+	}
+	if high, low := strings.Index(markdown, "| High | 1 | 1 |"), strings.Index(markdown, "| Low | 1 | 0 |"); high < 0 || low < high {
+		t.Errorf("Expected the severities ordered from High to Low, got %s", markdown)
+	}
+	if !strings.Contains(markdown, "| High | 8.8 |  | [CVE-2016-9840](https://example.com/CVE-2016-9840) | zlib | 1.2.8\\|r0 |  |") {
+		t.Errorf("Expected the unapproved vulnerability with an escaped version, got %s", markdown)
+	}
+	if strings.Contains(markdown, "| musl |") {
+		t.Errorf("Expected the approved vulnerability not to be listed, got %s", markdown)
+	}
+
+	buffer.Reset()
+	writeMarkdownReport(&buffer, &vulnerabilityReport{Image: "alpine:3.18"})
+	if !strings.HasSuffix(buffer.String(), "No vulnerabilities found.\n") {
+		t.Errorf("Expected a report without vulnerabilities to say so, got %s", buffer.String())
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Results of a scan in the metrics
+const (
+	scanResultClean      = "clean"
+	scanResultUnapproved = "unapproved"
+	scanResultNoFeatures = "no_features"
+	scanResultFailed     = "failed"
+)
+
+var (
+	scansTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "clair_scanner",
+		Name:      "scans_total",
+		Help:      "Scans performed, by result: clean, unapproved, no_features or failed.",
+	}, []string{"result"})
+	vulnerabilitiesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "clair_scanner",
+		Name:      "vulnerabilities_total",
+		Help:      "Vulnerabilities found by the scans, by severity.",
+	}, []string{"severity"})
+	unapprovedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "clair_scanner",
+		Name:      "unapproved_vulnerabilities_total",
+		Help:      "Unapproved vulnerabilities found by the scans.",
+	})
+	scanDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "clair_scanner",
+		Name:      "scan_duration_seconds",
+		Help:      "Duration of the scans that did not fail.",
+		Buckets:   []float64{1, 5, 10, 30, 60, 120, 300, 600, 1200},
+	})
+	clairRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "clair_scanner",
+		Name:      "clair_request_duration_seconds",
+		Help:      "Latency of the requests to Clair, by method and response code.",
+	}, []string{"method", "code"})
+	clairRequestFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "clair_scanner",
+		Name:      "clair_request_failures_total",
+		Help:      "Requests to Clair that failed, without response or with a 5xx response, by method.",
+	}, []string{"method"})
+)
+
+// observeClairRequest records the latency of a request to Clair, requests without response or with a 5xx response are failures
+func observeClairRequest(method string, started time.Time, response *http.Response, err error) {
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(response.StatusCode)
+	}
+	clairRequestDuration.WithLabelValues(method, code).Observe(time.Since(started).Seconds())
+	if err != nil || response.StatusCode >= 500 {
+		clairRequestFailures.WithLabelValues(method).Inc()
+	}
+}
+
+// observeScan records the result of a scan and the vulnerabilities it found
+func observeScan(report *vulnerabilityReport, code int, failed bool) {
+	switch {
+	case failed:
+		scansTotal.WithLabelValues(scanResultFailed).Inc()
+		return
+	case report == nil:
+		scansTotal.WithLabelValues(scanResultNoFeatures).Inc()
+		return
+	case code == exitCodeUnapproved:
+		scansTotal.WithLabelValues(scanResultUnapproved).Inc()
+	default:
+		scansTotal.WithLabelValues(scanResultClean).Inc()
+	}
+	for _, vulnerability := range report.Vulnerabilities {
+		vulnerabilitiesTotal.WithLabelValues(vulnerability.Severity).Inc()
+	}
+	unapprovedTotal.Add(float64(len(report.Unapproved)))
+	if !report.started.IsZero() {
+		scanDuration.Observe(time.Since(report.started).Seconds())
+	}
+}
+
+// metricsHandler serves the metrics of the scans and the requests to Clair, with the number of queued scans of the service
+func (service *scanService) metricsHandler() http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(scansTotal, vulnerabilitiesTotal, unapprovedTotal, scanDuration, clairRequestDuration, clairRequestFailures)
+	registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "clair_scanner",
+		Name:      "queued_scans",
+		Help:      "Scans waiting for the scanner.",
+	}, func() float64 {
+		return float64(len(service.queue))
+	}))
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
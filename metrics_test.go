@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	observeScan(&vulnerabilityReport{Unapproved: []string{"CVE-2017-1"}, Vulnerabilities: []vulnerabilityInfo{{Vulnerability: "CVE-2017-1", Severity: "High"}}}, exitCodeUnapproved, false)
+	observeScan(nil, exitCodeError, true)
+	observeClairRequest("GET", time.Now(), nil, errors.New("connection refused"))
+
+	service := newScanService(nil, "secret")
+	server := httptest.NewServer(service.router())
+	defer server.Close()
+	response, err := http.Get(server.URL + "/metrics")
+	if err != nil || response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the metrics without a token, got %v %v", response, err)
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(response.Body)
+	for _, metric := range []string{
+		`clair_scanner_scans_total{result="unapproved"}`,
+		`clair_scanner_scans_total{result="failed"}`,
+		`clair_scanner_vulnerabilities_total{severity="High"}`,
+		`clair_scanner_clair_request_failures_total{method="GET"}`,
+		`clair_scanner_queued_scans 0`,
+	} {
+		if !strings.Contains(string(body), metric) {
+			t.Errorf("Expected metric %s, got %s", metric, body)
+		}
+	}
+}
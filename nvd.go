@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const nvdCacheTTL = 24 * time.Hour
+
+// nvdAPIURL is the NVD CVE API the CVE ID is appended to
+var nvdAPIURL = "https://services.nvd.nist.gov/rest/json/cves/2.0?cveId="
+
+// nvdRequestInterval is the wait between requests to the NVD API, which allows 5 requests in 30 seconds without an API key and 50 with one
+var nvdRequestInterval = map[bool]time.Duration{false: 6 * time.Second, true: 600 * time.Millisecond}
+
+// nvdRetry is the retry policy of requests to the NVD API, which responds 403 or 429 when the rate limit is exceeded
+var nvdRetry = retryPolicy{attempts: 4, backoff: 30 * time.Second, statusCodes: map[int]bool{http.StatusForbidden: true, http.StatusTooManyRequests: true}}
+
+// nvdClient paces the requests to the NVD API to stay within its rate limit
+type nvdClient struct {
+	headers  map[string]string
+	interval time.Duration
+	last     time.Time
+}
+
+type nvdResponse struct {
+	Vulnerabilities []struct {
+		CVE nvdCVE `json:"cve"`
+	} `json:"vulnerabilities"`
+}
+
+type nvdCVE struct {
+	ID           string `json:"id"`
+	Descriptions []struct {
+		Lang  string `json:"lang"`
+		Value string `json:"value"`
+	} `json:"descriptions"`
+	Metrics map[string][]struct {
+		CVSSData struct {
+			Version      string  `json:"version"`
+			VectorString string  `json:"vectorString"`
+			BaseScore    float64 `json:"baseScore"`
+		} `json:"cvssData"`
+	} `json:"metrics"`
+}
+
+// enrichFromNVD fills in the CVSS data and description of vulnerabilities Clair has no information for, using the NVD API
+func enrichFromNVD(vulnerabilities []vulnerabilityInfo, apiKey string) {
+	client := &nvdClient{headers: map[string]string{}, interval: nvdRequestInterval[apiKey != ""]}
+	if apiKey != "" {
+		client.headers["apiKey"] = apiKey
+	}
+
+	cves := make(map[string]*nvdCVE)
+	for i := range vulnerabilities {
+		vulnerability := &vulnerabilities[i]
+		if !strings.HasPrefix(vulnerability.Vulnerability, "CVE-") || (vulnerability.CVSSScore > 0 && vulnerability.Description != "") {
+			continue
+		}
+
+		cve, fetched := cves[vulnerability.Vulnerability]
+		if !fetched {
+			var err error
+			if cve, err = client.fetchCVE(vulnerability.Vulnerability); err != nil {
+				logger.Warnf("Could not enrich %s from NVD: %v", vulnerability.Vulnerability, err)
+			}
+			cves[vulnerability.Vulnerability] = cve
+		}
+		if cve == nil {
+			continue
+		}
+
+		if vulnerability.Description == "" {
+			vulnerability.Description = cve.description()
+		}
+		if vulnerability.CVSSScore == 0 {
+			vulnerability.CVSSVersion, vulnerability.CVSSScore, vulnerability.CVSSVector = cve.cvss()
+		}
+	}
+}
+
+// fetchCVE fetches a CVE from the NVD API, responses are cached for a day
+func (client *nvdClient) fetchCVE(id string) (*nvdCVE, error) {
+	content, err := cachedFetch("nvd/"+id+".json", nvdCacheTTL, func() ([]byte, error) {
+		return client.download(nvdAPIURL + url.QueryEscape(id))
+	})
+	if err != nil {
+		return nil, err
+	}
+	var response nvdResponse
+	if err = json.Unmarshal(content, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Vulnerabilities) == 0 {
+		return nil, nil
+	}
+	return &response.Vulnerabilities[0].CVE, nil
+}
+
+// download downloads the url once the request interval has passed since the previous request,
+// requests over the rate limit are retried with exponential backoff
+func (client *nvdClient) download(url string) ([]byte, error) {
+	backoff := nvdRetry.backoff
+	for attempt := 1; ; attempt++ {
+		if wait := client.interval - time.Since(client.last); wait > 0 {
+			time.Sleep(wait)
+		}
+		client.last = time.Now()
+		content, err := download(url, client.headers)
+		failure, isResponse := err.(*responseError)
+		if err == nil || !isResponse || !nvdRetry.statusCodes[failure.statusCode] || attempt >= nvdRetry.attempts {
+			return content, err
+		}
+		logger.Warnf("NVD API rate limit exceeded: %v, retrying in %v (attempt %d of %d)", err, backoff, attempt+1, nvdRetry.attempts)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// description returns the English description of the CVE
+func (cve *nvdCVE) description() string {
+	for _, description := range cve.Descriptions {
+		if description.Lang == "en" {
+			return description.Value
+		}
+	}
+	return ""
+}
+
+// cvss returns the CVSS major version, base score and vector of the CVE, CVSSv3 is preferred over CVSSv2
+func (cve *nvdCVE) cvss() (string, float64, string) {
+	for _, metric := range []string{"cvssMetricV31", "cvssMetricV30", "cvssMetricV2"} {
+		for _, cvss := range cve.Metrics[metric] {
+			if cvss.CVSSData.BaseScore > 0 {
+				return strings.SplitN(cvss.CVSSData.Version, ".", 2)[0], cvss.CVSSData.BaseScore, cvss.CVSSData.VectorString
+			}
+		}
+	}
+	return "", 0, ""
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnrichFromNVD(t *testing.T) {
+	initializeLogger("")
+	defer func(dir string) { cacheDir = dir }(cacheDir)
+	cacheDir, _ = ioutil.TempDir("", "clair-scanner-cache")
+	defer os.RemoveAll(cacheDir)
+
+	var requests []time.Time
+	nvd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, time.Now())
+		if len(requests) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if r.Header.Get("apiKey") != "secret" {
+			t.Errorf("Expected the API key to be sent, got %q", r.Header.Get("apiKey"))
+		}
+		id := r.URL.Query().Get("cveId")
+		fmt.Fprintf(w, `{"vulnerabilities": [{"cve": {"id": %q, "descriptions": [{"lang": "en", "value": "Description of %s"}],
+			"metrics": {"cvssMetricV31": [{"cvssData": {"version": "3.1", "vectorString": "AV:N/AC:L", "baseScore": 9.8}}]}}}]}`, id, id)
+	}))
+	defer nvd.Close()
+	defer func(url string, interval map[bool]time.Duration, retry retryPolicy) {
+		nvdAPIURL, nvdRequestInterval, nvdRetry = url, interval, retry
+	}(nvdAPIURL, nvdRequestInterval, nvdRetry)
+	nvdAPIURL = nvd.URL + "/?cveId="
+	nvdRequestInterval = map[bool]time.Duration{true: 50 * time.Millisecond}
+	nvdRetry.backoff = time.Millisecond
+
+	vulnerabilities := []vulnerabilityInfo{
+		{Vulnerability: "CVE-2016-9840", FeatureName: "zlib"},
+		{Vulnerability: "CVE-2016-9840", FeatureName: "zlib-dev"},
+		{Vulnerability: "CVE-2017-15650", FeatureName: "musl"},
+		{Vulnerability: "ALPINE-13661", FeatureName: "busybox"},
+	}
+	enrichFromNVD(vulnerabilities, "secret")
+
+	if len(requests) != 3 {
+		t.Fatalf("Expected a retried request and one request per CVE, got %d requests", len(requests))
+	}
+	for i := 1; i < len(requests); i++ {
+		// the gap is measured by the server, which may receive a request a little before the client's interval has passed
+		if gap := requests[i].Sub(requests[i-1]); gap < 40*time.Millisecond {
+			t.Errorf("Expected the requests to be paced, got %v between request %d and %d", gap, i, i+1)
+		}
+	}
+	for _, vulnerability := range vulnerabilities[:3] {
+		if vulnerability.CVSSScore != 9.8 || vulnerability.CVSSVersion != "3" || !strings.HasPrefix(vulnerability.Description, "Description of CVE-") {
+			t.Errorf("Expected %s to be enriched, got %+v", vulnerability.Vulnerability, vulnerability)
+		}
+	}
+	if vulnerabilities[3].Description != "" {
+		t.Errorf("Expected vulnerabilities without CVE ID not to be enriched, got %+v", vulnerabilities[3])
+	}
+
+	requests = nil
+	cached := []vulnerabilityInfo{{Vulnerability: "CVE-2016-9840", FeatureName: "zlib"}}
+	enrichFromNVD(cached, "secret")
+	if len(requests) != 0 || cached[0].CVSSScore != 9.8 {
+		t.Errorf("Expected the cached CVE to be used without requests, got %d requests and %+v", len(requests), cached[0])
+	}
+}
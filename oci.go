@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	ociImageIndexMediaType   = "application/vnd.oci.image.index.v1+json"
+	dockerManifestListType   = "application/vnd.docker.distribution.manifest.list.v2+json"
+	ociRefNameAnnotation     = "org.opencontainers.image.ref.name"
+	containerdNameAnnotation = "io.containerd.image.name"
+)
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations"`
+	Platform    *ociPlatform      `json:"platform"`
+}
+
+type ociPlatform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant"`
+}
+
+type ociIndex struct {
+	Manifests []ociDescriptor `json:"manifests"`
+}
+
+type ociManifest struct {
+	Config ociDescriptor   `json:"config"`
+	Layers []ociDescriptor `json:"layers"`
+}
+
+// saveOCIImage saves the image of an OCI image layout to the temporary folder in the layout of 'docker save' and returns its digests,
+// the layer files are not copied, they are read from the blobs of the layout
+func saveOCIImage(layoutPath string, imageName string, platform string, tmpPath string) []string {
+	var index ociIndex
+	readOCIBlob(filepath.Join(layoutPath, "index.json"), &index)
+	descriptor := selectOCIManifest(index.Manifests, imageName)
+	for isOCIIndex(descriptor) {
+		readOCIBlob(ociBlobPath(layoutPath, descriptor.Digest), &index)
+		descriptor = selectOCIPlatform(index.Manifests, platform)
+	}
+
+	var manifest ociManifest
+	readOCIBlob(ociBlobPath(layoutPath, descriptor.Digest), &manifest)
+	configFile := ociDigestHex(manifest.Config.Digest) + ".json"
+	config, err := readImageFile(ociBlobPath(layoutPath, manifest.Config.Digest))
+	if err != nil {
+		logger.Fatalf("Could not read OCI image [%s]: %v", imageName, err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(tmpPath, configFile), config, 0644); err != nil {
+		logger.Fatalf("Could not read OCI image [%s]: %v", imageName, err)
+	}
+
+	dockerManifest := manifestJSON{Config: configFile}
+	for _, layer := range manifest.Layers {
+		if strings.HasSuffix(layer.MediaType, "+zstd") {
+			logger.Warnf("Layer %s of OCI image [%s] is zstd compressed, Clair may not be able to analyze it", layer.Digest, imageName)
+		}
+		layerID := ociDigestHex(layer.Digest)
+		path := filepath.Join(tmpPath, layerID, layerFileName)
+		if archived, exists := archivedLayers[ociBlobPath(layoutPath, layer.Digest)]; exists {
+			archivedLayers[path] = archived // the layout is itself read from an image archive
+		} else {
+			archivedLayers[path] = archivedFile{archive: ociBlobPath(layoutPath, layer.Digest), offset: 0, size: layer.Size}
+		}
+		dockerManifest.Layers = append(dockerManifest.Layers, layerID+"/"+layerFileName)
+	}
+	content, _ := json.Marshal([]manifestJSON{dockerManifest})
+	if err = ioutil.WriteFile(filepath.Join(tmpPath, "manifest.json"), content, 0644); err != nil {
+		logger.Fatalf("Could not read OCI image [%s]: %v", imageName, err)
+	}
+	return []string{manifest.Config.Digest, descriptor.Digest}
+}
+
+// selectOCIManifest selects the manifest of the image from the index of an OCI image layout, by its reference name, tag or digest,
+// without image name, or without reference names in the layout, the layout must contain a single image
+func selectOCIManifest(manifests []ociDescriptor, imageName string) ociDescriptor {
+	if imageName == "" || (len(manifests) == 1 && manifests[0].Annotations[ociRefNameAnnotation] == "" && manifests[0].Annotations[containerdNameAnnotation] == "") {
+		if len(manifests) != 1 {
+			logger.Fatalf("Could not read OCI image: the layout contains %d images, give the name of the image to scan", len(manifests))
+		}
+		return manifests[0]
+	}
+	digest := imageName[strings.Index(imageName, "@")+1:]
+	for _, manifest := range manifests {
+		if manifest.Digest == digest || manifest.Digest == "sha256:"+digest {
+			return manifest
+		}
+		for _, name := range []string{manifest.Annotations[ociRefNameAnnotation], manifest.Annotations[containerdNameAnnotation]} {
+			if name != "" && (name == imageName || strings.HasSuffix(imageName, ":"+name)) {
+				return manifest
+			}
+		}
+	}
+	logger.Fatalf("Could not read OCI image: no image [%s] in the layout", imageName)
+	return ociDescriptor{}
+}
+
+// selectOCIPlatform selects the manifest of the platform from a multi-platform image,
+// without platform the manifest of the platform clair-scanner runs on, or the first manifest
+func selectOCIPlatform(manifests []ociDescriptor, platform string) ociDescriptor {
+	if len(manifests) == 0 {
+		logger.Fatalf("Could not read OCI image: the image index contains no manifests")
+	}
+	for _, manifest := range manifests {
+		if matchesPlatform(manifest.Platform, platform) {
+			return manifest
+		}
+	}
+	if platform != "" {
+		logger.Fatalf("Could not read OCI image: the image has no %s platform", platform)
+	}
+	return manifests[0]
+}
+
+// ociImagePlatforms returns the platforms of a multi-platform image of an OCI image layout, without platforms for a single platform image
+func ociImagePlatforms(layoutPath string, imageName string) []string {
+	var index ociIndex
+	readOCIBlob(filepath.Join(layoutPath, "index.json"), &index)
+	descriptor := selectOCIManifest(index.Manifests, imageName)
+	if !isOCIIndex(descriptor) {
+		return []string{""}
+	}
+	readOCIBlob(ociBlobPath(layoutPath, descriptor.Digest), &index)
+	return indexPlatforms(index)
+}
+
+// indexPlatforms returns the platforms of the images of an image index
+func indexPlatforms(index ociIndex) []string {
+	var platforms []string
+	for _, manifest := range index.Manifests {
+		// Attestations of buildx are stored as manifests of the unknown/unknown platform
+		if manifest.Platform != nil && manifest.Platform.OS != "unknown" && !isOCIIndex(manifest) {
+			platforms = append(platforms, platformName(manifest.Platform))
+		}
+	}
+	return platforms
+}
+
+// isOCIIndex tells whether the descriptor refers to an image index of a multi-platform image
+func isOCIIndex(descriptor ociDescriptor) bool {
+	return descriptor.MediaType == ociImageIndexMediaType || descriptor.MediaType == dockerManifestListType
+}
+
+// ociImageName returns the reference name of the single image in an OCI image layout, or the layout path when it has none
+func ociImageName(layoutPath string) string {
+	var index ociIndex
+	readOCIBlob(filepath.Join(layoutPath, "index.json"), &index)
+	if name := selectOCIManifest(index.Manifests, "").Annotations[ociRefNameAnnotation]; name != "" {
+		return name
+	}
+	return layoutPath
+}
+
+// readOCIBlob reads a JSON file of an OCI image layout
+func readOCIBlob(path string, value interface{}) {
+	file, err := openLayerFile(path)
+	if err != nil {
+		logger.Fatalf("Could not read OCI image layout: %v", err)
+	}
+	defer file.Close()
+	if err = json.NewDecoder(file).Decode(value); err != nil {
+		logger.Fatalf("Could not read OCI image layout: %s is not json: %v", path, err)
+	}
+}
+
+// ociBlobPath returns the path of a blob in an OCI image layout
+func ociBlobPath(layoutPath string, digest string) string {
+	parts := strings.SplitN(digest, ":", 2)
+	if len(parts) != 2 || strings.ContainsAny(parts[1], "/\\.") {
+		logger.Fatalf("Could not read OCI image layout: invalid digest %s", digest)
+	}
+	return filepath.Join(layoutPath, "blobs", parts[0], parts[1])
+}
+
+// ociDigestHex returns the encoded part of a digest
+func ociDigestHex(digest string) string {
+	return digest[strings.Index(digest, ":")+1:]
+}
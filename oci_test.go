@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSaveOCIImage(t *testing.T) {
+	initializeLogger("")
+	layoutPath := createTmpPath("oci-layout")
+	defer os.RemoveAll(layoutPath)
+	layer := writeOCIBlob(t, layoutPath, "layer content")
+	config := writeOCIBlob(t, layoutPath, `{"history": [{"created_by": "ADD rootfs.tar /"}]}`)
+	manifest := writeOCIBlob(t, layoutPath, `{"config": {"digest": "`+config+`"}, "layers": [{"digest": "`+layer+`", "size": 13}]}`)
+	index := `{"manifests": [{"digest": "` + manifest + `", "annotations": {"org.opencontainers.image.ref.name": "3.18"}}]}`
+	if err := ioutil.WriteFile(filepath.Join(layoutPath, "index.json"), []byte(index), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpPath := createTmpPath("clair-oci")
+	defer os.RemoveAll(tmpPath)
+	digests := saveOCIImage(layoutPath, "alpine:3.18", "", tmpPath)
+	if !reflect.DeepEqual(digests, []string{config, manifest}) {
+		t.Errorf("Expected the config and manifest digests, got %v", digests)
+	}
+	layerIds := getImageLayerIds(tmpPath)
+	if !reflect.DeepEqual(layerIds, []string{ociDigestHex(layer)}) {
+		t.Errorf("Expected the layer blob as layer, got %v", layerIds)
+	}
+	if history := getImageHistory(tmpPath); len(history) != 1 || history[0].CreatedBy != "ADD rootfs.tar /" {
+		t.Errorf("Expected the history of the image configuration, got %v", history)
+	}
+	if layerDigest(tmpPath, layerIds[0]) != layer {
+		t.Errorf("Expected the layer to be read from the blob %s, got %s", layer, layerDigest(tmpPath, layerIds[0]))
+	}
+	if name := ociImageName(layoutPath); name != "3.18" {
+		t.Errorf("Expected the reference name of the image, got %s", name)
+	}
+}
+
+// writeOCIBlob writes the content as blob of the OCI image layout and returns its digest
+func writeOCIBlob(t *testing.T, layoutPath string, content string) string {
+	hash := sha256.Sum256([]byte(content))
+	digest := hex.EncodeToString(hash[:])
+	if err := os.MkdirAll(filepath.Join(layoutPath, "blobs", "sha256"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(layoutPath, "blobs", "sha256", digest), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return "sha256:" + digest
+}
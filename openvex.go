@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/pborman/uuid"
+)
+
+const openvexContext = "https://openvex.dev/ns/v0.2.0"
+
+// vexStatus is the OpenVEX status given to whitelisted vulnerabilities
+var vexStatus = "not_affected"
+
+type openvexDocument struct {
+	Context    string             `json:"@context"`
+	ID         string             `json:"@id"`
+	Author     string             `json:"author"`
+	Timestamp  string             `json:"timestamp"`
+	Version    int                `json:"version"`
+	Tooling    string             `json:"tooling"`
+	Statements []openvexStatement `json:"statements"`
+}
+
+type openvexStatement struct {
+	Vulnerability   openvexVulnerability `json:"vulnerability"`
+	Products        []openvexProduct     `json:"products"`
+	Status          string               `json:"status"`
+	ImpactStatement string               `json:"impact_statement,omitempty"`
+	StatusNotes     string               `json:"status_notes,omitempty"`
+}
+
+type openvexVulnerability struct {
+	Name string `json:"name"`
+}
+
+type openvexProduct struct {
+	ID            string             `json:"@id"`
+	Subcomponents []openvexComponent `json:"subcomponents,omitempty"`
+}
+
+type openvexComponent struct {
+	ID string `json:"@id"`
+}
+
+// writeOpenVEXReport writes an OpenVEX statement for every vulnerability approved by the whitelist
+func writeOpenVEXReport(w io.Writer, report *vulnerabilityReport) error {
+	document := openvexDocument{
+		Context:    openvexContext,
+		ID:         "urn:uuid:" + uuid.New(),
+		Author:     toolName,
+		Timestamp:  time.Now().UTC().Format(time.RFC3339),
+		Version:    1,
+		Tooling:    toolName + "/" + version,
+		Statements: []openvexStatement{},
+	}
+
+	statementIndexes := make(map[string]int)
+	for _, vulnerability := range report.Vulnerabilities {
+		if !vulnerability.Whitelisted {
+			continue
+		}
+		component := openvexComponent{ID: packageURL(vulnerability.FeatureName, vulnerability.FeatureVersion, vulnerability.Namespace)}
+		if index, exists := statementIndexes[vulnerability.Vulnerability]; exists {
+			product := &document.Statements[index].Products[0]
+			product.Subcomponents = append(product.Subcomponents, component)
+			continue
+		}
+		statementIndexes[vulnerability.Vulnerability] = len(document.Statements)
+
+		statement := openvexStatement{
+			Vulnerability: openvexVulnerability{Name: vulnerability.Vulnerability},
+			Products:      []openvexProduct{{ID: report.Image, Subcomponents: []openvexComponent{component}}},
+			Status:        vexStatus,
+		}
+		justification := "Accepted in the clair-scanner whitelist"
+		if vulnerability.WhitelistReason != "" {
+			justification += ": " + vulnerability.WhitelistReason
+		}
+		if vulnerability.WhitelistOwner != "" {
+			justification += " (owner " + vulnerability.WhitelistOwner + ")"
+		}
+		if vexStatus == "not_affected" {
+			statement.ImpactStatement = justification
+		} else {
+			statement.StatusNotes = justification
+		}
+		document.Statements = append(document.Statements, statement)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(document)
+}
+
+// Validate that the given OpenVEX status can be used for whitelisted vulnerabilities
+func validateVexStatus(status string) {
+	if status != "not_affected" && status != "under_investigation" {
+		logger.Fatalf("Invalid OpenVEX status %s given", status)
+	}
+}
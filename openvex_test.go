@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWriteOpenVEXReport(t *testing.T) {
+	report := &vulnerabilityReport{
+		Image: "alpine:3.5",
+		Vulnerabilities: []vulnerabilityInfo{
+			{FeatureName: "zlib", FeatureVersion: "1.2.8-r2", Namespace: "alpine:v3.5", Vulnerability: "CVE-2016-9840", Whitelisted: true, WhitelistReason: "not reachable", WhitelistOwner: "team-a"},
+			{FeatureName: "zlib-dev", FeatureVersion: "1.2.8-r2", Namespace: "alpine:v3.5", Vulnerability: "CVE-2016-9840", Whitelisted: true},
+			{FeatureName: "musl", FeatureVersion: "1.1.15-r5", Namespace: "alpine:v3.5", Vulnerability: "CVE-2017-15650"},
+		},
+	}
+
+	var buffer bytes.Buffer
+	if err := writeOpenVEXReport(&buffer, report); err != nil {
+		t.Fatalf("Could not write OpenVEX report: %v", err)
+	}
+	var document openvexDocument
+	if err := json.Unmarshal(buffer.Bytes(), &document); err != nil {
+		t.Fatalf("OpenVEX report is not JSON: %v", err)
+	}
+	if document.Context != openvexContext || !strings.HasPrefix(document.ID, "urn:uuid:") || document.Author == "" || document.Timestamp == "" || document.Version != 1 {
+		t.Errorf("Expected the required OpenVEX document fields, got %+v", document)
+	}
+	if len(document.Statements) != 1 {
+		t.Fatalf("Expected a statement of the whitelisted vulnerability only, got %+v", document.Statements)
+	}
+	statement := document.Statements[0]
+	if statement.Vulnerability.Name != "CVE-2016-9840" || statement.Status != "not_affected" || len(statement.Products) != 1 || statement.Products[0].ID != "alpine:3.5" {
+		t.Errorf("Expected alpine:3.5 not to be affected by CVE-2016-9840, got %+v", statement)
+	}
+	// not_affected requires a justification or an impact statement
+	if statement.ImpactStatement != "Accepted in the clair-scanner whitelist: not reachable (owner team-a)" {
+		t.Errorf("Expected the whitelist reason as impact statement, got %s", statement.ImpactStatement)
+	}
+	if subcomponents := statement.Products[0].Subcomponents; len(subcomponents) != 2 || subcomponents[1].ID != "pkg:apk/alpine/zlib-dev@1.2.8-r2?distro=alpine-v3.5" {
+		t.Errorf("Expected both packages as subcomponents, got %+v", subcomponents)
+	}
+}
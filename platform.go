@@ -0,0 +1,80 @@
+package main
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// allPlatforms is the --platform value to scan every platform of a multi-platform image
+const allPlatforms = "all"
+
+// validatePlatform validates that the platform is given as os/arch[/variant] or all
+func validatePlatform(platform string) {
+	if platform == "" || platform == allPlatforms {
+		return
+	}
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		logger.Fatalf("Invalid platform %s given, use os/arch[/variant], e.g. 'linux/arm64', or 'all'", platform)
+	}
+}
+
+// matchesPlatform tells whether the platform of a manifest is the platform given as os/arch[/variant], without platform the linux image of the platform clair-scanner runs on matches
+func matchesPlatform(manifestPlatform *ociPlatform, platform string) bool {
+	if manifestPlatform == nil {
+		return false
+	}
+	if platform == "" {
+		platform = "linux/" + runtime.GOARCH
+	}
+	parts := strings.Split(platform, "/")
+	if manifestPlatform.OS != parts[0] || manifestPlatform.Architecture != parts[1] {
+		return false
+	}
+	return len(parts) < 3 || manifestPlatform.Variant == parts[2]
+}
+
+// platformName returns the platform of a manifest as os/arch[/variant]
+func platformName(platform *ociPlatform) string {
+	name := platform.OS + "/" + platform.Architecture
+	if platform.Variant != "" {
+		name += "/" + platform.Variant
+	}
+	return name
+}
+
+// platformConfigs returns a scanner configuration for every platform of a multi-platform image
+func platformConfigs(config scannerConfig) []scannerConfig {
+	var platforms []string
+	if config.ociDir != "" {
+		platforms = ociImagePlatforms(config.ociDir, config.imageName)
+	} else if config.registry {
+		platforms = registryImagePlatforms(config.imageName)
+	} else {
+		logger.Fatalf("Scanning all platforms requires the multi-platform image in an OCI image layout or a registry, use --oci-dir or --registry")
+	}
+	var configs []scannerConfig
+	for _, platform := range platforms {
+		configs = append(configs, forPlatform(config, platform))
+	}
+	return configs
+}
+
+// forPlatform returns the scanner configuration to scan one platform of a multi-platform image, the platform is added to the names of the report files
+func forPlatform(config scannerConfig, platform string) scannerConfig {
+	config.platform = platform
+	for _, file := range []*string{&config.reportFile, &config.junitFile, &config.htmlFile, &config.sbomFile, &config.spdxFile, &config.vexFile, &config.generateWhitelist} {
+		*file = platformFile(*file, platform)
+	}
+	return config
+}
+
+// platformFile adds the platform to the name of a report file, report.json of linux/arm64 becomes report-linux-arm64.json
+func platformFile(file string, platform string) string {
+	if file == "" {
+		return ""
+	}
+	extension := filepath.Ext(file)
+	return strings.TrimSuffix(file, extension) + "-" + strings.Replace(platform, "/", "-", -1) + extension
+}
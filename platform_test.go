@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestMatchesPlatform(t *testing.T) {
+	arm := &ociPlatform{OS: "linux", Architecture: "arm", Variant: "v7"}
+	for platform, expected := range map[string]bool{"linux/arm": true, "linux/arm/v7": true, "linux/arm/v6": false, "linux/arm64": false, "windows/arm": false} {
+		if matchesPlatform(arm, platform) != expected {
+			t.Errorf("Expected %s to match linux/arm/v7: %v", platform, expected)
+		}
+	}
+	if matchesPlatform(nil, "linux/arm") {
+		t.Errorf("Expected a manifest without platform not to match")
+	}
+}
+
+func TestForPlatform(t *testing.T) {
+	config := forPlatform(scannerConfig{reportFile: "reports/report.json", junitFile: "junit"}, "linux/arm64")
+	if config.platform != "linux/arm64" || config.reportFile != "reports/report-linux-arm64.json" || config.junitFile != "junit-linux-arm64" || config.htmlFile != "" {
+		t.Errorf("Expected the platform in the report file names, got %s %s %s", config.reportFile, config.junitFile, config.htmlFile)
+	}
+}
+
+func TestForImage(t *testing.T) {
+	tests := map[string]string{
+		"alpine:3.5":                           "report-alpine-3.5.json",
+		"myorg/app:1.0":                        "report-myorg-app-1.0.json",
+		"harbor.example.com/myproject/app:1.0": "report-myproject-app-1.0.json",
+	}
+	for imageName, expected := range tests {
+		if config := forImage(scannerConfig{reportFile: "report.json"}, imageName); config.imageName != imageName || config.reportFile != expected {
+			t.Errorf("Expected %s to be scanned with report file %s, got %s", imageName, expected, config.reportFile)
+		}
+	}
+}
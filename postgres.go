@@ -0,0 +1,130 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq" // registers the postgres driver of database/sql
+	"github.com/pborman/uuid"
+)
+
+// postgresDriver is the database/sql driver the results are inserted with
+var postgresDriver = "postgres"
+
+// postgresSchema creates the tables of the scan results, a scan has a row in clair_scanner_scans and a row per vulnerability found in clair_scanner_vulnerabilities
+var postgresSchema = []string{
+	`CREATE TABLE IF NOT EXISTS clair_scanner_scans (
+		scan_id    UUID PRIMARY KEY,
+		scanned_at TIMESTAMPTZ NOT NULL,
+		image      TEXT NOT NULL,
+		digest     TEXT NOT NULL,
+		platform   TEXT NOT NULL,
+		total      INTEGER NOT NULL,
+		unapproved INTEGER NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS clair_scanner_scans_image ON clair_scanner_scans (image, scanned_at)`,
+	`CREATE TABLE IF NOT EXISTS clair_scanner_vulnerabilities (
+		scan_id          UUID NOT NULL REFERENCES clair_scanner_scans (scan_id) ON DELETE CASCADE,
+		vulnerability    TEXT NOT NULL,
+		severity         TEXT NOT NULL,
+		package_name     TEXT NOT NULL,
+		package_version  TEXT NOT NULL,
+		fixed_by         TEXT NOT NULL,
+		namespace        TEXT NOT NULL,
+		added_by         TEXT NOT NULL,
+		cvss_score       DOUBLE PRECISION,
+		in_base_image    BOOLEAN NOT NULL,
+		status           TEXT NOT NULL,
+		whitelist_owner  TEXT NOT NULL,
+		whitelist_reason TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS clair_scanner_vulnerabilities_scan ON clair_scanner_vulnerabilities (scan_id)`,
+	`CREATE INDEX IF NOT EXISTS clair_scanner_vulnerabilities_vulnerability ON clair_scanner_vulnerabilities (vulnerability)`,
+}
+
+const (
+	// postgresSchemaLock is the advisory lock key that keeps scanners from creating the schema at the same time
+	postgresSchemaLock = 7242303515
+	postgresInsertScan = `INSERT INTO clair_scanner_scans (scan_id, scanned_at, image, digest, platform, total, unapproved) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	postgresInsertVuln = `INSERT INTO clair_scanner_vulnerabilities (scan_id, vulnerability, severity, package_name, package_version, fixed_by, namespace, added_by, cvss_score, in_base_image, status, whitelist_owner, whitelist_reason)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+)
+
+// isPostgresURL tells if the database URL is a PostgreSQL connection URL
+func isPostgresURL(databaseURL string) bool {
+	return strings.HasPrefix(databaseURL, "postgres://") || strings.HasPrefix(databaseURL, "postgresql://")
+}
+
+// exportToPostgres inserts the scan and its vulnerabilities into the PostgreSQL database in one transaction, failures are logged as warnings
+func exportToPostgres(report *vulnerabilityReport, databaseURL string) {
+	if databaseURL == "" {
+		return
+	}
+	db, err := sql.Open(postgresDriver, databaseURL)
+	if err == nil {
+		defer db.Close()
+		if err = createPostgresSchema(db); err == nil {
+			err = insertPostgresScan(db, report, uuid.New(), time.Now())
+		}
+	}
+	if err != nil {
+		logger.Warnf("Could not insert the scan of image [%s] into the database: %v", report.Image, err)
+		return
+	}
+	logger.Infof("Inserted the scan of image [%s] with %d vulnerabilities into the database", report.Image, len(report.Vulnerabilities))
+}
+
+// createPostgresSchema creates the tables of the scan results when they don't exist yet
+func createPostgresSchema(db *sql.DB) error {
+	return inTransaction(db, func(tx *sql.Tx) error {
+		//CREATE TABLE IF NOT EXISTS fails when another scanner creates the table at the same time
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, postgresSchemaLock); err != nil {
+			return err
+		}
+		for _, statement := range postgresSchema {
+			if _, err := tx.Exec(statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// insertPostgresScan inserts the scan and a row for every vulnerability of the report
+func insertPostgresScan(db *sql.DB, report *vulnerabilityReport, scanID string, scanned time.Time) error {
+	return inTransaction(db, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(postgresInsertScan, scanID, scanned.UTC(), report.Image, report.Digest, report.Platform, len(report.Vulnerabilities), len(report.Unapproved)); err != nil {
+			return err
+		}
+		statement, err := tx.Prepare(postgresInsertVuln)
+		if err != nil {
+			return err
+		}
+		defer statement.Close()
+		for _, vulnerability := range report.Vulnerabilities {
+			var cvssScore interface{}
+			if vulnerability.CVSSScore > 0 {
+				cvssScore = vulnerability.CVSSScore
+			}
+			if _, err = statement.Exec(scanID, vulnerability.Vulnerability, vulnerability.Severity, vulnerability.FeatureName, vulnerability.FeatureVersion, vulnerability.FixedBy,
+				vulnerability.Namespace, vulnerability.AddedBy, cvssScore, vulnerability.InBaseImage, vulnerability.Status, vulnerability.WhitelistOwner, vulnerability.WhitelistReason); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// inTransaction runs the statements of execute in a transaction, which is committed when execute succeeds and rolled back otherwise
+func inTransaction(db *sql.DB, execute func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err = execute(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingDriver is a database/sql driver that records the statements executed on it
+type recordingDriver struct {
+	statements []recordedStatement
+	commits    int
+	rollbacks  int
+	failOn     string
+}
+
+type recordedStatement struct {
+	query string
+	args  []driver.Value
+}
+
+type recordingConn struct{ driver *recordingDriver }
+
+type recordingStmt struct {
+	conn  *recordingConn
+	query string
+}
+
+type recordingTx struct{ conn *recordingConn }
+
+func (d *recordingDriver) Open(name string) (driver.Conn, error) { return &recordingConn{d}, nil }
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return &recordingStmt{c, query}, nil
+}
+func (c *recordingConn) Close() error              { return nil }
+func (c *recordingConn) Begin() (driver.Tx, error) { return &recordingTx{c}, nil }
+
+func (s *recordingStmt) Close() error  { return nil }
+func (s *recordingStmt) NumInput() int { return -1 }
+func (s *recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if s.conn.driver.failOn != "" && strings.Contains(s.query, s.conn.driver.failOn) {
+		return nil, errors.New("relation does not exist")
+	}
+	s.conn.driver.statements = append(s.conn.driver.statements, recordedStatement{s.query, args})
+	return driver.RowsAffected(1), nil
+}
+func (s *recordingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func (tx *recordingTx) Commit() error   { tx.conn.driver.commits++; return nil }
+func (tx *recordingTx) Rollback() error { tx.conn.driver.rollbacks++; return nil }
+
+func TestExportToPostgres(t *testing.T) {
+	initializeLogger("")
+	recorder := &recordingDriver{}
+	sql.Register("recording", recorder)
+	defer func(name string) { postgresDriver = name }(postgresDriver)
+	postgresDriver = "recording"
+
+	report := &vulnerabilityReport{Image: "alpine:3.5", Digest: "sha256:abc", Unapproved: []string{"CVE-2017-1"}, Vulnerabilities: []vulnerabilityInfo{
+		{Vulnerability: "CVE-2017-1", FeatureName: "openssl", FeatureVersion: "1.0.2k-r0", Severity: "High", CVSSScore: 7.5, Status: "Unapproved"},
+		{Vulnerability: "CVE-2017-2", FeatureName: "musl", FeatureVersion: "1.1.15", Severity: "Low", Status: "Approved", WhitelistReason: "Not used"},
+	}}
+	exportToPostgres(report, "postgres://scanner@db.example.com/scans")
+
+	if recorder.commits != 2 || recorder.rollbacks != 0 {
+		t.Fatalf("Expected the schema and the scan to be committed, got %d commits and %d rollbacks", recorder.commits, recorder.rollbacks)
+	}
+	statements := recorder.statements
+	if len(statements) != 1+len(postgresSchema)+3 || !strings.Contains(statements[0].query, "pg_advisory_xact_lock") {
+		t.Fatalf("Expected the schema to be created under a lock before the scan is inserted, got %d statements", len(statements))
+	}
+	scan := statements[1+len(postgresSchema)]
+	if scan.query != postgresInsertScan || scan.args[2] != "alpine:3.5" || scan.args[3] != "sha256:abc" || scan.args[5] != int64(2) || scan.args[6] != int64(1) {
+		t.Errorf("Expected the scan of alpine:3.5 with 2 vulnerabilities and 1 unapproved, got %v", scan.args)
+	}
+	first, second := statements[len(statements)-2], statements[len(statements)-1]
+	if first.query != postgresInsertVuln || first.args[0] != scan.args[0] || first.args[1] != "CVE-2017-1" || first.args[8] != 7.5 || first.args[10] != "Unapproved" {
+		t.Errorf("Expected the row of CVE-2017-1 in the scan, got %v", first.args)
+	}
+	if second.args[1] != "CVE-2017-2" || second.args[8] != nil || second.args[12] != "Not used" {
+		t.Errorf("Expected the row of CVE-2017-2 without CVSS score, got %v", second.args)
+	}
+
+	failing := &recordingDriver{failOn: "INSERT INTO clair_scanner_vulnerabilities"}
+	sql.Register("failing", failing)
+	db, _ := sql.Open("failing", "")
+	defer db.Close()
+	if err := insertPostgresScan(db, report, "5f0c6e1a-6b4e-4f57-8d8f-3a7f6c1d2e9b", time.Now()); err == nil || failing.rollbacks != 1 || failing.commits != 0 {
+		t.Errorf("Expected a failed insert to roll back the scan, got %v with %d rollbacks", err, failing.rollbacks)
+	}
+
+	if !isPostgresURL("postgresql://localhost/scans") || isPostgresURL("mysql://localhost/scans") {
+		t.Errorf("Expected only PostgreSQL URLs to be accepted")
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// proxyConfig is the proxy of every HTTP request, read from HTTP_PROXY, HTTPS_PROXY and NO_PROXY unless --proxy is given
+var proxyConfig = httpproxy.FromEnvironment()
+
+// configureProxy sends every HTTP request through the proxy, hosts in NO_PROXY and localhost are still requested directly
+func configureProxy(proxy string) {
+	if proxy == "" {
+		return
+	}
+	if parsed, err := url.Parse(proxy); err != nil || parsed.Host == "" {
+		logger.Fatalf("Invalid proxy URL %s given", proxy)
+	}
+	proxyConfig.HTTPProxy = proxy
+	proxyConfig.HTTPSProxy = proxy
+}
+
+// newTransport creates an HTTP transport using the configured proxy
+func newTransport() *http.Transport {
+	proxy := proxyConfig.ProxyFunc()
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = func(request *http.Request) (*url.URL, error) {
+		return proxy(request.URL)
+	}
+	return transport
+}
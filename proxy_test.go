@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+func TestConfigureProxy(t *testing.T) {
+	initializeLogger("")
+	defer func(config httpproxy.Config) { proxyConfig = &config }(*proxyConfig)
+	configureProxy("http://proxy.example.com:3128")
+	proxyConfig.NoProxy = "clair.internal"
+
+	transport := newTransport()
+	for target, expected := range map[string]string{
+		"https://services.nvd.nist.gov/rest": "http://proxy.example.com:3128",
+		"http://clair.internal:6060/v1":      "",
+		"http://localhost:6060/v1":           "",
+	} {
+		request, _ := http.NewRequest("GET", target, nil)
+		proxy, err := transport.Proxy(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (expected == "" && proxy != nil) || (expected != "" && (proxy == nil || proxy.String() != expected)) {
+			t.Errorf("Expected proxy %q for %s, got %v", expected, target, proxy)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// pushgatewayConfig is the Prometheus Pushgateway the metrics of every scan are pushed to
+type pushgatewayConfig struct {
+	url string
+	job string
+}
+
+// pushgatewayGroup returns the path of the group of metrics of the scanned image, the image and platform are base64 encoded as they contain slashes
+func pushgatewayGroup(job string, report *vulnerabilityReport) string {
+	group := "/metrics/job/" + job + "/image@base64/" + base64.RawURLEncoding.EncodeToString([]byte(report.Image))
+	if report.Platform != "" {
+		group += "/platform@base64/" + base64.RawURLEncoding.EncodeToString([]byte(report.Platform))
+	}
+	return group
+}
+
+// pushgatewayMetrics returns the metrics of the scan in the Prometheus text format
+func pushgatewayMetrics(report *vulnerabilityReport, duration time.Duration, scanned time.Time) []byte {
+	var metrics bytes.Buffer
+	gauge := func(name string, help string) {
+		fmt.Fprintf(&metrics, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	}
+	entry := newHistoryEntry(report, scanned)
+	gauge("clair_scanner_vulnerabilities", "Vulnerabilities of the image found by the last scan, by severity.")
+	for _, severity := range summarySeverities() {
+		fmt.Fprintf(&metrics, "clair_scanner_vulnerabilities{severity=%q} %d\n", severity, entry.Severities[severity])
+	}
+	gauge("clair_scanner_unapproved_vulnerabilities", "Unapproved vulnerabilities of the image found by the last scan.")
+	fmt.Fprintf(&metrics, "clair_scanner_unapproved_vulnerabilities %d\n", entry.Unapproved)
+	gauge("clair_scanner_scan_duration_seconds", "Duration of the last scan of the image.")
+	fmt.Fprintf(&metrics, "clair_scanner_scan_duration_seconds %g\n", duration.Seconds())
+	gauge("clair_scanner_last_scan_timestamp_seconds", "Time of the last scan of the image.")
+	fmt.Fprintf(&metrics, "clair_scanner_last_scan_timestamp_seconds %d\n", scanned.Unix())
+	return metrics.Bytes()
+}
+
+// pushToGateway pushes the metrics of the scan to the Pushgateway, replacing the metrics of the previous scan of the image, failures are logged as warnings
+func pushToGateway(report *vulnerabilityReport, pushgateway pushgatewayConfig) {
+	if pushgateway.url == "" {
+		return
+	}
+	var duration time.Duration
+	if !report.started.IsZero() {
+		duration = time.Since(report.started)
+	}
+	url := strings.TrimSuffix(pushgateway.url, "/") + pushgatewayGroup(pushgateway.job, report)
+	_, err := upload("PUT", url, pushgatewayMetrics(report, duration, time.Now()), map[string]string{"Content-Type": "text/plain; version=0.0.4"})
+	if err != nil {
+		logger.Warnf("Could not push the metrics of image [%s] to the Pushgateway: %v", report.Image, err)
+	}
+}
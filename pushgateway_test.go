@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPushgatewayGroup(t *testing.T) {
+	if group := pushgatewayGroup("ci", &vulnerabilityReport{Image: "myorg/app:1.0"}); group != "/metrics/job/ci/image@base64/bXlvcmcvYXBwOjEuMA" {
+		t.Errorf("Expected the group of the image, got %s", group)
+	}
+	if group := pushgatewayGroup("ci", &vulnerabilityReport{Image: "app", Platform: "linux/arm64"}); group != "/metrics/job/ci/image@base64/YXBw/platform@base64/bGludXgvYXJtNjQ" {
+		t.Errorf("Expected the group of the image and platform, got %s", group)
+	}
+}
+
+func TestPushToGateway(t *testing.T) {
+	initializeLogger("")
+	var method, path, body string
+	pushgateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, _ := ioutil.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.Path, string(content)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer pushgateway.Close()
+
+	report := &vulnerabilityReport{Image: "app", Unapproved: []string{"CVE-2017-1"}, Vulnerabilities: []vulnerabilityInfo{{Vulnerability: "CVE-2017-1", Severity: "High"}}, started: time.Now()}
+	pushToGateway(report, pushgatewayConfig{url: pushgateway.URL + "/", job: "clair-scanner"})
+	if method != "PUT" || path != "/metrics/job/clair-scanner/image@base64/YXBw" {
+		t.Errorf("Expected the metrics to replace the group of the image, got %s %s", method, path)
+	}
+	for _, metric := range []string{
+		"# TYPE clair_scanner_vulnerabilities gauge\n",
+		`clair_scanner_vulnerabilities{severity="High"} 1` + "\n",
+		`clair_scanner_vulnerabilities{severity="Low"} 0` + "\n",
+		"clair_scanner_unapproved_vulnerabilities 1\n",
+		"clair_scanner_last_scan_timestamp_seconds ",
+	} {
+		if !strings.Contains(body, metric) {
+			t.Errorf("Expected metric %s, got %s", metric, body)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	dockerHubRegistry       = "registry-1.docker.io"
+	ociManifestMediaType    = "application/vnd.oci.image.manifest.v1+json"
+	dockerManifestMediaType = "application/vnd.docker.distribution.manifest.v2+json"
+	defaultTokenLifetime    = 60 * time.Second // lifetime of registry tokens without expires_in, as of the distribution token spec
+	tokenRenewal            = 30 * time.Second // tokens expiring within this time are renewed before they are handed to Clair
+)
+
+// manifestMediaTypes are the media types of the manifests clair-scanner reads from a registry
+var manifestMediaTypes = []string{ociImageIndexMediaType, dockerManifestListType, ociManifestMediaType, dockerManifestMediaType}
+
+type imageReference struct {
+	registry   string
+	repository string
+	reference  string // tag or digest
+}
+
+// registryClient requests the distribution API of the registry of an image, authorizing with the token the registry asks for
+type registryClient struct {
+	client        *http.Client
+	image         imageReference
+	credentials   registryCredentials
+	authorization string
+	challenge     string
+	expires       time.Time
+}
+
+// remoteLayer is a layer Clair downloads straight from the registry of the image
+type remoteLayer struct {
+	registry *registryClient
+	digest   string
+}
+
+// remoteLayers are the layers Clair downloads straight from their registry, by layer ID
+var remoteLayers = make(map[string]remoteLayer)
+
+// parseImageReference parses an image name into its registry, repository and tag or digest, as Docker does
+func parseImageReference(imageName string) imageReference {
+	image := imageReference{registry: dockerHubRegistry, reference: "latest"}
+	name := imageName
+	digest := ""
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, digest = name[:i], name[i+1:]
+	}
+	//The tag of a name with tag and digest is ignored, the digest identifies the image
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, image.reference = name[:i], name[i+1:]
+	}
+	if digest != "" {
+		image.reference = digest
+	}
+	if i := strings.Index(name, "/"); i >= 0 && (strings.ContainsAny(name[:i], ".:") || name[:i] == "localhost") {
+		image.registry, name = name[:i], name[i+1:]
+	}
+	if image.registry == "docker.io" || image.registry == "index.docker.io" {
+		image.registry = dockerHubRegistry
+	}
+	if image.registry == dockerHubRegistry && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	if name == "" {
+		logger.Fatalf("Invalid image name %s given", imageName)
+	}
+	image.repository = name
+	return image
+}
+
+// newRegistryClient creates the client for the distribution API of the registry of the image
+func newRegistryClient(image imageReference) *registryClient {
+	return &registryClient{client: &http.Client{Transport: newTransport()}, image: image, credentials: lookupRegistryCredentials(image.registry)}
+}
+
+// baseURL returns the URL of the distribution API of the repository, registries on localhost are requested over plain HTTP
+func (registry *registryClient) baseURL() string {
+	scheme := "https"
+	if strings.HasPrefix(registry.image.registry, "localhost") || strings.HasPrefix(registry.image.registry, "127.0.0.1") {
+		scheme = "http"
+	}
+	return scheme + "://" + registry.image.registry + "/v2/" + registry.image.repository
+}
+
+// get sends a GET request to the distribution API, when the registry asks for authorization the request is sent again with a token
+func (registry *registryClient) get(path string, accept []string) (*http.Response, error) {
+	response, err := registry.send(path, accept)
+	if err != nil || response.StatusCode != http.StatusUnauthorized {
+		return response, err
+	}
+	response.Body.Close()
+	if err = registry.authorize(response.Header.Get("WWW-Authenticate")); err != nil {
+		return nil, err
+	}
+	return registry.send(path, accept)
+}
+
+func (registry *registryClient) send(path string, accept []string) (*http.Response, error) {
+	request, err := http.NewRequest("GET", registry.baseURL()+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, mediaType := range accept {
+		request.Header.Add("Accept", mediaType)
+	}
+	if registry.authorization != "" {
+		request.Header.Set("Authorization", registry.authorization)
+	}
+	return registry.client.Do(request)
+}
+
+// authorize answers the authentication challenge of the registry, with basic authentication
+// or with a bearer token of its token service for the pull scope of the repository
+func (registry *registryClient) authorize(challenge string) error {
+	scheme, params := parseChallenge(challenge)
+	credentials := registry.credentials
+	registry.challenge = challenge
+	if strings.EqualFold(scheme, "Basic") && credentials.user != "" {
+		registry.authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials.user+":"+credentials.password))
+		return nil
+	} else if !strings.EqualFold(scheme, "Bearer") || params["realm"] == "" {
+		return fmt.Errorf("registry %s asks for unsupported authentication %q, are credentials missing?", registry.image.registry, challenge)
+	}
+
+	query := url.Values{}
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	query.Set("scope", "repository:"+registry.image.repository+":pull")
+	var request *http.Request
+	var err error
+	if credentials.identityToken != "" {
+		// Identity tokens of 'docker login' are OAuth refresh tokens
+		query.Set("grant_type", "refresh_token")
+		query.Set("refresh_token", credentials.identityToken)
+		query.Set("client_id", "clair-scanner")
+		request, err = http.NewRequest("POST", params["realm"], strings.NewReader(query.Encode()))
+		if err == nil {
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	} else {
+		request, err = http.NewRequest("GET", params["realm"]+"?"+query.Encode(), nil)
+		if err == nil && credentials.user != "" {
+			request.SetBasicAuth(credentials.user, credentials.password)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	response, err := registry.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(response.Body)
+		return fmt.Errorf("token service of registry %s responded %d with message %s", registry.image.registry, response.StatusCode, string(body))
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err = json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return fmt.Errorf("token of registry %s is not json: %v", registry.image.registry, err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	registry.authorization = "Bearer " + token.Token
+	registry.expires = time.Now().Add(defaultTokenLifetime)
+	if token.ExpiresIn > 0 {
+		registry.expires = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return nil
+}
+
+// freshAuthorization returns the authorization of the registry, a token that is about to expire is renewed first
+func (registry *registryClient) freshAuthorization() string {
+	if !registry.expires.IsZero() && time.Until(registry.expires) < tokenRenewal {
+		if err := registry.authorize(registry.challenge); err != nil {
+			logger.Fatalf("Could not renew the token of registry %s: %v", registry.image.registry, err)
+		}
+	}
+	return registry.authorization
+}
+
+// parseChallenge parses the WWW-Authenticate header of a registry, e.g. 'Bearer realm="https://auth.docker.io/token",service="registry.docker.io"'
+func parseChallenge(challenge string) (string, map[string]string) {
+	params := make(map[string]string)
+	parts := strings.SplitN(strings.TrimSpace(challenge), " ", 2)
+	if len(parts) < 2 {
+		return parts[0], params
+	}
+	for _, param := range strings.Split(parts[1], ",") {
+		keyValue := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if len(keyValue) == 2 {
+			params[strings.ToLower(keyValue[0])] = strings.Trim(keyValue[1], `"`)
+		}
+	}
+	return parts[0], params
+}
+
+// fetchManifest fetches a manifest, or image index, of the repository by tag or digest and returns it with its digest
+func (registry *registryClient) fetchManifest(reference string) ([]byte, ociDescriptor) {
+	response, err := registry.get("/manifests/"+reference, manifestMediaTypes)
+	if err != nil {
+		logger.Fatalf("Could not fetch manifest of [%s]: %v", registry.image.repository, err)
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		logger.Fatalf("Could not fetch manifest of [%s]: %v", registry.image.repository, err)
+	}
+	if response.StatusCode != http.StatusOK {
+		logger.Fatalf("Could not fetch manifest of [%s:%s]: registry %s responded %d with message %s", registry.image.repository, reference, registry.image.registry, response.StatusCode, string(body))
+	}
+
+	hash := sha256.Sum256(body)
+	descriptor := ociDescriptor{MediaType: strings.Split(response.Header.Get("Content-Type"), ";")[0], Digest: "sha256:" + hex.EncodeToString(hash[:])}
+	if strings.HasPrefix(reference, "sha256:") && reference != descriptor.Digest {
+		logger.Fatalf("Could not fetch manifest of [%s]: registry %s returned content with digest %s instead of %s", registry.image.repository, registry.image.registry, descriptor.Digest, reference)
+	}
+	return body, descriptor
+}
+
+// fetchImageManifest fetches the manifest of the platform of the image, resolving the image index of a multi-platform image
+func (registry *registryClient) fetchImageManifest(platform string) (ociManifest, string) {
+	body, descriptor := registry.fetchManifest(registry.image.reference)
+	for isOCIIndex(descriptor) {
+		var index ociIndex
+		if err := json.Unmarshal(body, &index); err != nil {
+			logger.Fatalf("Could not read image index of [%s]: %v", registry.image.repository, err)
+		}
+		body, descriptor = registry.fetchManifest(selectOCIPlatform(index.Manifests, platform).Digest)
+	}
+	var manifest ociManifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		logger.Fatalf("Could not read manifest of [%s]: %v", registry.image.repository, err)
+	}
+	return manifest, descriptor.Digest
+}
+
+// downloadBlob downloads a blob of the repository to the path and verifies its digest
+func (registry *registryClient) downloadBlob(digest string, path string) error {
+	response, err := registry.get("/blobs/"+digest, nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("registry %s responded %d for blob %s", registry.image.registry, response.StatusCode, digest)
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(file, hash), response.Body); err != nil {
+		return err
+	}
+	if "sha256:"+hex.EncodeToString(hash.Sum(nil)) != digest {
+		return fmt.Errorf("blob %s has digest sha256:%s", digest, hex.EncodeToString(hash.Sum(nil)))
+	}
+	return nil
+}
+
+// saveRegistryImage downloads the image from its registry to the temporary folder in the layout of 'docker save' and returns its digests,
+// remote layers are not downloaded, Clair downloads them straight from the registry
+func saveRegistryImage(imageName string, platform string, tmpPath string, remote bool) []string {
+	registry := newRegistryClient(parseImageReference(imageName))
+	manifest, manifestDigest := registry.fetchImageManifest(platform)
+
+	dockerManifest := manifestJSON{Config: ociDigestHex(manifest.Config.Digest) + ".json"}
+	if err := registry.downloadBlob(manifest.Config.Digest, filepath.Join(tmpPath, dockerManifest.Config)); err != nil {
+		logger.Fatalf("Could not download image configuration of [%s]: %v", imageName, err)
+	}
+	for _, layer := range manifest.Layers {
+		layerID := ociDigestHex(layer.Digest)
+		dockerManifest.Layers = append(dockerManifest.Layers, layerID+"/"+layerFileName)
+		if remote {
+			remoteLayers[layerID] = remoteLayer{registry: registry, digest: layer.Digest}
+			layerDigests[tmpPath+"/"+layerID+"/"+layerFileName] = layer.Digest // the layer is not hashed, Clair verifies the digest
+			continue
+		}
+		logger.Infof("Downloading layer %s of [%s]", layer.Digest, imageName)
+		if err := registry.downloadBlob(layer.Digest, filepath.Join(tmpPath, layerID, layerFileName)); err != nil {
+			logger.Fatalf("Could not download layer of [%s]: %v", imageName, err)
+		}
+	}
+	content, _ := json.Marshal([]manifestJSON{dockerManifest})
+	if err := ioutil.WriteFile(filepath.Join(tmpPath, "manifest.json"), content, 0644); err != nil {
+		logger.Fatalf("Could not save image [%s]: %v", imageName, err)
+	}
+	return []string{manifest.Config.Digest, manifestDigest}
+}
+
+// registryImagePlatforms returns the platforms of a multi-platform image in a registry, without platforms for a single platform image
+func registryImagePlatforms(imageName string) []string {
+	registry := newRegistryClient(parseImageReference(imageName))
+	body, descriptor := registry.fetchManifest(registry.image.reference)
+	if !isOCIIndex(descriptor) {
+		return []string{""}
+	}
+	var index ociIndex
+	if err := json.Unmarshal(body, &index); err != nil {
+		logger.Fatalf("Could not read image index of [%s]: %v", imageName, err)
+	}
+	return indexPlatforms(index)
+}
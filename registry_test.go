@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseImageReference(t *testing.T) {
+	initializeLogger("")
+	tests := map[string]imageReference{
+		"alpine":                          {dockerHubRegistry, "library/alpine", "latest"},
+		"myorg/app:1.0":                   {dockerHubRegistry, "myorg/app", "1.0"},
+		"docker.io/library/alpine:3.18":   {dockerHubRegistry, "library/alpine", "3.18"},
+		"localhost:5000/app@sha256:abc":   {"localhost:5000", "app", "sha256:abc"},
+		"ghcr.io/org/team/app:2":          {"ghcr.io", "org/team/app", "2"},
+		"nginx:1.25@sha256:abc":           {dockerHubRegistry, "library/nginx", "sha256:abc"},
+		"localhost:5000/app:1.0@sha256:a": {"localhost:5000", "app", "sha256:a"},
+		"registry:777/ubuntu":             {"registry:777", "ubuntu", "latest"},
+		"registry.example.com:777/nginx":  {"registry.example.com:777", "nginx", "latest"},
+	}
+	for imageName, expected := range tests {
+		if image := parseImageReference(imageName); image != expected {
+			t.Errorf("Expected %s to be parsed as %v, got %v", imageName, expected, image)
+		}
+	}
+}
+
+func TestSaveRegistryImage(t *testing.T) {
+	initializeLogger("")
+	blobs := map[string]string{}
+	layer := addBlob(blobs, "layer content")
+	config := addBlob(blobs, `{}`)
+	manifest := addBlob(blobs, `{"config": {"digest": "`+config+`"}, "layers": [{"digest": "`+layer+`"}]}`)
+	index := `{"manifests": [{"digest": "` + manifest + `", "platform": {"os": "linux", "architecture": "arm64"}}]}`
+
+	var registry *httptest.Server
+	registry = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.URL.Query().Get("scope") != "repository:myapp:pull" {
+				t.Errorf("Expected a token for pulling myapp, got scope %s", r.URL.Query().Get("scope"))
+			}
+			w.Write([]byte(`{"token": "secret"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+registry.URL+`/token",service="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/v2/myapp/manifests/1.0":
+			w.Header().Set("Content-Type", ociImageIndexMediaType)
+			w.Write([]byte(index))
+		case r.URL.Path == "/v2/myapp/manifests/"+manifest:
+			w.Header().Set("Content-Type", ociManifestMediaType)
+			w.Write([]byte(blobs[manifest]))
+		case strings.HasPrefix(r.URL.Path, "/v2/myapp/blobs/"):
+			w.Write([]byte(blobs[strings.TrimPrefix(r.URL.Path, "/v2/myapp/blobs/")]))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer registry.Close()
+	imageName := strings.TrimPrefix(registry.URL, "http://") + "/myapp:1.0"
+
+	tmpPath := createTmpPath("clair-registry")
+	defer os.RemoveAll(tmpPath)
+	digests := saveRegistryImage(imageName, "linux/arm64", tmpPath, false)
+	if !reflect.DeepEqual(digests, []string{config, manifest}) {
+		t.Errorf("Expected the config and manifest digests, got %v", digests)
+	}
+	if layerIds := getImageLayerIds(tmpPath); !reflect.DeepEqual(layerIds, []string{ociDigestHex(layer)}) {
+		t.Errorf("Expected the layer blob as layer, got %v", layerIds)
+	}
+	if platforms := registryImagePlatforms(imageName); !reflect.DeepEqual(platforms, []string{"linux/arm64"}) {
+		t.Errorf("Expected the platforms of the image index, got %v", platforms)
+	}
+
+	remotePath := createTmpPath("clair-registry")
+	defer os.RemoveAll(remotePath)
+	saveRegistryImage(imageName, "linux/arm64", remotePath, true)
+	layerID := ociDigestHex(layer)
+	if _, err := os.Stat(remotePath + "/" + layerID + "/" + layerFileName); !os.IsNotExist(err) {
+		t.Errorf("Expected the layer Clair downloads from the registry not to be downloaded")
+	}
+	if url := layerURL("http://127.0.0.1:9279/token", layerID); url != registry.URL+"/v2/myapp/blobs/"+layer {
+		t.Errorf("Expected Clair to download the layer from the registry, got %s", url)
+	}
+	if headers := layerHeaders(layerID); headers["Authorization"] != "Bearer secret" {
+		t.Errorf("Expected Clair to download the layer with the token of the registry, got %v", headers)
+	}
+	if !remoteOnly([]string{layerID}) || layerDigest(remotePath, layerID) != layer {
+		t.Errorf("Expected no layer server and the digest of the registry for the layer")
+	}
+}
+
+// addBlob adds the content to the blobs by its digest and returns the digest
+func addBlob(blobs map[string]string, content string) string {
+	hash := sha256.Sum256([]byte(content))
+	digest := "sha256:" + hex.EncodeToString(hash[:])
+	blobs[digest] = content
+	return digest
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// dockerHubAuthKey is the key of Docker Hub credentials in the docker config.json
+const dockerHubAuthKey = "https://index.docker.io/v1/"
+
+// registryCreds are the credentials given with --creds, they are used for every registry
+var registryCreds = registryCredentials{}
+
+type registryCredentials struct {
+	user          string
+	password      string
+	identityToken string
+}
+
+type dockerAuthConfig struct {
+	Auth          string `json:"auth"`
+	IdentityToken string `json:"identitytoken"`
+}
+
+type dockerCredentialsJSON struct {
+	Auths       map[string]dockerAuthConfig `json:"auths"`
+	CredsStore  string                      `json:"credsStore"`
+	CredHelpers map[string]string           `json:"credHelpers"`
+}
+
+// parseRegistryCreds parses the registry credentials given as user:password
+func parseRegistryCreds(creds string) registryCredentials {
+	if creds == "" {
+		return registryCredentials{}
+	}
+	parts := strings.SplitN(creds, ":", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		logger.Fatalf("Invalid registry credentials given, use --creds user:password")
+	}
+	return registryCredentials{user: parts[0], password: parts[1]}
+}
+
+// lookupRegistryCredentials returns the credentials for the registry: the --creds credentials, else the credentials of the cloud of the registry,
+// else the credentials of 'docker login' from the credential helper, credential store or docker config.json
+func lookupRegistryCredentials(registry string) registryCredentials {
+	if registryCreds.user != "" {
+		return registryCreds
+	}
+	if credentials, found := ecrCredentials(registry); found {
+		return credentials
+	}
+	if credentials, found := googleCredentials(registry); found {
+		return credentials
+	}
+	if credentials, found := acrCredentials(registry); found {
+		return credentials
+	}
+	return dockerConfigCredentials(dockerConfigDir(), registry)
+}
+
+// dockerConfigCredentials reads the credentials for the registry the docker CLI uses from the config.json in the config folder
+func dockerConfigCredentials(configDir string, registry string) registryCredentials {
+	content, err := ioutil.ReadFile(filepath.Join(configDir, "config.json"))
+	if err != nil {
+		return registryCredentials{}
+	}
+	var config dockerCredentialsJSON
+	if err = json.Unmarshal(content, &config); err != nil {
+		logger.Warnf("Could not read registry credentials, %s/config.json is not json: %v", configDir, err)
+		return registryCredentials{}
+	}
+
+	keys := []string{registry, "https://" + registry, "http://" + registry}
+	if registry == dockerHubRegistry {
+		keys = []string{dockerHubAuthKey, "index.docker.io", "docker.io", registry}
+	}
+	for _, key := range keys {
+		if helper, exists := config.CredHelpers[key]; exists {
+			return credentialHelperCredentials(helper, key)
+		}
+	}
+	for _, key := range keys {
+		if auth, exists := config.Auths[key]; exists && (auth.Auth != "" || auth.IdentityToken != "") {
+			return decodeDockerAuth(auth)
+		}
+	}
+	if config.CredsStore != "" {
+		return credentialHelperCredentials(config.CredsStore, keys[0])
+	}
+	return registryCredentials{}
+}
+
+// decodeDockerAuth decodes the base64 encoded user:password of an auths entry of the docker config.json
+func decodeDockerAuth(auth dockerAuthConfig) registryCredentials {
+	credentials := registryCredentials{identityToken: auth.IdentityToken}
+	decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+	if err != nil {
+		logger.Warnf("Could not read registry credentials, auth of the docker config.json is not base64 encoded: %v", err)
+		return credentials
+	}
+	if parts := strings.SplitN(string(decoded), ":", 2); len(parts) == 2 {
+		credentials.user, credentials.password = parts[0], parts[1]
+	}
+	return credentials
+}
+
+// credentialHelperCredentials gets the credentials for the registry from a docker credential helper, e.g. docker-credential-osxkeychain
+func credentialHelperCredentials(helper string, serverURL string) registryCredentials {
+	command := exec.Command("docker-credential-"+helper, "get")
+	command.Stdin = strings.NewReader(serverURL)
+	var stderr bytes.Buffer
+	command.Stderr = &stderr
+	output, err := command.Output()
+	if err != nil {
+		// Credential helpers fail when they have no credentials for the registry, the registry is then used anonymously
+		logger.Warnf("Could not get registry credentials for %s from docker-credential-%s: %v %s", serverURL, helper, err, strings.TrimSpace(stderr.String()))
+		return registryCredentials{}
+	}
+	var credentials struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if err = json.Unmarshal(output, &credentials); err != nil {
+		logger.Warnf("Could not get registry credentials from docker-credential-%s: %v", helper, err)
+		return registryCredentials{}
+	}
+	// Helpers return identity tokens with the user <token>
+	if credentials.Username == "<token>" {
+		return registryCredentials{identityToken: credentials.Secret}
+	}
+	return registryCredentials{user: credentials.Username, password: credentials.Secret}
+}
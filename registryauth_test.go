@@ -0,0 +1,41 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDockerConfigCredentials(t *testing.T) {
+	initializeLogger("")
+	configDir := createTmpPath("docker-config")
+	defer os.RemoveAll(configDir)
+	config := `{"auths": {
+		"https://index.docker.io/v1/": {"auth": "aHViOnNlY3JldA=="},
+		"registry.example.com": {"auth": "dXNlcjpwYXNz"},
+		"token.example.com": {"identitytoken": "refresh"}
+	}}`
+	if err := ioutil.WriteFile(filepath.Join(configDir, "config.json"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]registryCredentials{
+		dockerHubRegistry:      {user: "hub", password: "secret"},
+		"registry.example.com": {user: "user", password: "pass"},
+		"token.example.com":    {identityToken: "refresh"},
+		"ghcr.io":              {},
+	}
+	for registry, expected := range tests {
+		if credentials := dockerConfigCredentials(configDir, registry); credentials != expected {
+			t.Errorf("Expected credentials %v for %s, got %v", expected, registry, credentials)
+		}
+	}
+}
+
+func TestParseRegistryCreds(t *testing.T) {
+	initializeLogger("")
+	if creds := parseRegistryCreds("user:pa:ss"); creds.user != "user" || creds.password != "pa:ss" {
+		t.Errorf("Expected user and password split at the first colon, got %v", creds)
+	}
+}
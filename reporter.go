@@ -1,18 +1,42 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"github.com/olekukonko/tablewriter"
+	"io"
 	"io/ioutil"
 	"os"
 	"sort"
+	"strconv"
+	"time"
 )
 
+// reportFormatters maps an output format to the function writing the report in that format, "table" is handled by reportToConsole
+var reportFormatters = map[string]func(io.Writer, *vulnerabilityReport) error{
+	"json":     writeJSONReport,
+	"ndjson":   writeNDJSONReport,
+	"gitlab":   writeGitlabReport,
+	"sarif":    writeSarifReport,
+	"markdown": writeMarkdownReport,
+	"template": writeTemplateReport,
+}
+
 type vulnerabilityReport struct {
 	Image           string              `json:"image"`
+	Digest          string              `json:"digest,omitempty"`
+	Digests         []string            `json:"digests,omitempty"`
+	Platform        string              `json:"platform,omitempty"`
+	Layers          []string            `json:"layers"`
+	Features        []featureInfo       `json:"features"`
 	Unapproved      []string            `json:"unapproved"`
 	Vulnerabilities []vulnerabilityInfo `json:"vulnerabilities"`
+	Dockerfile      string              `json:"dockerfile,omitempty"`
+	Containers      []string            `json:"containers,omitempty"`
+	UnusedWhitelist []string            `json:"unusedwhitelist,omitempty"`
+	started         time.Time
+	clairAPI        string // the Clair API the image was scanned with
 }
 
 func sortBySeverity(vulnerabilities []vulnerabilityInfo) {
@@ -21,56 +45,96 @@ func sortBySeverity(vulnerabilities []vulnerabilityInfo) {
 	})
 }
 
+// groupBaseImageVulnerabilities moves the vulnerabilities inherited from the base image after the ones added by the image itself
+func groupBaseImageVulnerabilities(vulnerabilities []vulnerabilityInfo) {
+	sort.SliceStable(vulnerabilities, func(i, j int) bool {
+		return !vulnerabilities[i].InBaseImage && vulnerabilities[j].InBaseImage
+	})
+}
+
 func formatStatus(status string) string {
-	if status == "Approved" {
+	if status == "Approved" || status == "Base image" || status == "Baseline" {
 		return fmt.Sprintf(NoticeColor, status)
 	}
 	return fmt.Sprintf(ErrorColor, status)
 }
 
-func formatTableData(vulnerabilities []vulnerabilityInfo, unapproved []string) [][]string {
+func formatTableData(vulnerabilities []vulnerabilityInfo) [][]string {
 	formatted := make([][]string, len(vulnerabilities))
 	for i, vulnerability := range vulnerabilities {
-		status := "Approved"
-		for _, u := range unapproved {
-			if vulnerability.Vulnerability == u {
-				status = "Unapproved"
-			}
+		status := vulnerability.Status
+		if status == "Approved" && vulnerability.InBaseImage {
+			status = "Base image"
+		} else if status == "Approved" && vulnerability.InBaseline {
+			status = "Baseline"
 		}
 		formatted[i] = []string{
 			formatStatus(status),
-			vulnerability.Severity + " " + vulnerability.Vulnerability,
+			vulnerability.Severity + " " + vulnerability.Vulnerability + formatTableCVSS(vulnerability),
 			vulnerability.FeatureName,
 			vulnerability.FeatureVersion,
+			vulnerability.FixedBy,
 			vulnerability.Description + "\n\n" + vulnerability.Link,
 		}
 	}
 	return formatted
 }
 
-func printTable(vulnerabilities []vulnerabilityInfo, unapproved []string) {
-	header := []string{"Status", "CVE Severity", "Package Name", "Package Version", "CVE Description"}
+// formatCVSS formats the CVSS base score and vector of a vulnerability, empty when Clair has no CVSS data
+func formatCVSS(vulnerability vulnerabilityInfo) string {
+	if vulnerability.CVSSScore == 0 {
+		return ""
+	}
+	cvss := strconv.FormatFloat(vulnerability.CVSSScore, 'f', 1, 64)
+	if vulnerability.CVSSVector != "" {
+		cvss += " (" + vulnerability.CVSSVector + ")"
+	}
+	return cvss
+}
+
+// formatEPSS formats the EPSS probability and percentile of a vulnerability, empty when there is no EPSS score
+func formatEPSS(vulnerability vulnerabilityInfo) string {
+	if vulnerability.EPSS == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%.2f%% (percentile %.2f)", vulnerability.EPSS*100, vulnerability.EPSSPercentile)
+}
+
+func formatTableCVSS(vulnerability vulnerabilityInfo) string {
+	scores := ""
+	if vulnerability.CVSSScore > 0 {
+		scores += "\nCVSS " + strconv.FormatFloat(vulnerability.CVSSScore, 'f', 1, 64)
+	}
+	if vulnerability.EPSS > 0 {
+		scores += fmt.Sprintf("\nEPSS %.2f%%", vulnerability.EPSS*100)
+	}
+	if vulnerability.KnownExploited {
+		scores += "\nKnown exploited"
+	}
+	return scores
+}
+
+func printTable(vulnerabilities []vulnerabilityInfo) {
+	header := []string{"Status", "CVE Severity", "Package Name", "Package Version", "Fixed Version", "CVE Description"}
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetHeader(header)
 	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
 	table.SetRowSeparator("-")
 	table.SetRowLine(true)
 	table.SetAlignment(tablewriter.ALIGN_LEFT)
-	table.AppendBulk(formatTableData(vulnerabilities, unapproved))
+	table.AppendBulk(formatTableData(vulnerabilities))
 	table.Render()
 }
 
-func filterApproved(vulnerabilities []vulnerabilityInfo, unapproved []string, reportAll bool) []vulnerabilityInfo {
+func filterApproved(vulnerabilities []vulnerabilityInfo, reportAll bool) []vulnerabilityInfo {
 	if reportAll {
 		return vulnerabilities
 	}
 
 	vulns := make([]vulnerabilityInfo, 0)
 	for _, vuln := range vulnerabilities {
-		for _, u := range unapproved {
-			if vuln.Vulnerability == u {
-				vulns = append(vulns, vuln)
-			}
+		if vuln.Status == "Unapproved" {
+			vulns = append(vulns, vuln)
 		}
 	}
 	return vulns
@@ -84,16 +148,17 @@ func reportToConsole(imageName string, vulnerabilities []vulnerabilityInfo, unap
 	if len(vulnerabilities) > 0 {
 		logger.Warnf("Image [%s] contains %d total vulnerabilities", imageName, len(vulnerabilities))
 
-		vulnerabilities = filterApproved(vulnerabilities, unapproved, reportAll)
+		vulnerabilities = filterApproved(vulnerabilities, reportAll)
 		sortBySeverity(vulnerabilities)
+		groupBaseImageVulnerabilities(vulnerabilities)
 
 		if len(unapproved) > 0 {
 			logger.Errorf("Image [%s] contains %d unapproved vulnerabilities", imageName, len(unapproved))
-			printTable(vulnerabilities, unapproved)
+			printTable(vulnerabilities)
 		} else {
 			logger.Infof("Image [%s] contains NO unapproved vulnerabilities", imageName)
 			if reportAll {
-				printTable(vulnerabilities, unapproved)
+				printTable(vulnerabilities)
 			}
 		}
 	} else {
@@ -101,21 +166,168 @@ func reportToConsole(imageName string, vulnerabilities []vulnerabilityInfo, unap
 	}
 }
 
+// reportVerdict writes whether the image has unapproved vulnerabilities, followed by a line per unapproved vulnerability, for quiet mode
+func reportVerdict(w io.Writer, imageName string, vulnerabilities []vulnerabilityInfo, unapproved []string) {
+	if len(unapproved) == 0 {
+		fmt.Fprintf(w, "Image [%s] contains NO unapproved vulnerabilities\n", imageName)
+		return
+	}
+	fmt.Fprintf(w, "Image [%s] contains %d unapproved vulnerabilities\n", imageName, len(unapproved))
+	for _, vulnerability := range vulnerabilities {
+		if vulnerability.Status == "Unapproved" {
+			fmt.Fprintf(w, "%s %s %s %s\n", vulnerability.Vulnerability, vulnerability.Severity, vulnerability.FeatureName, vulnerability.FeatureVersion)
+		}
+	}
+}
+
+// reportLayerAttribution logs how many vulnerabilities every layer introduced, so it is clear if they come from the base image or the own build steps
+func reportLayerAttribution(report *vulnerabilityReport, quiet bool) {
+	if quiet || len(report.Vulnerabilities) == 0 {
+		return
+	}
+	total := make(map[string]int)
+	unapproved := make(map[string]int)
+	instructions := make(map[string]string)
+	for _, vulnerability := range report.Vulnerabilities {
+		if vulnerability.InstructionLine > 0 {
+			instructions[vulnerability.AddedBy] = fmt.Sprintf(" (Dockerfile:%d %s)", vulnerability.InstructionLine, vulnerability.Instruction)
+		}
+		total[vulnerability.AddedBy]++
+		if vulnerability.Status != "Approved" {
+			unapproved[vulnerability.AddedBy]++
+		}
+	}
+	for i, layer := range report.Layers {
+		if total[layer] > 0 {
+			logger.Infof("Layer %d/%d [%s]%s introduced %d vulnerabilities, %d unapproved", i+1, len(report.Layers), shortLayerID(layer), instructions[layer], total[layer], unapproved[layer])
+		}
+	}
+}
+
+// shortLayerID shortens a layer ID the way docker does
+func shortLayerID(layer string) string {
+	if len(layer) > 12 {
+		return layer[:12]
+	}
+	return layer
+}
+
+// reportToStdout writes the report to stdout in the requested format
+func reportToStdout(report *vulnerabilityReport, format string) {
+	if err := reportFormatters[format](os.Stdout, report); err != nil {
+		logger.Fatalf("Could not create a %s report: %v", format, err)
+	}
+}
+
 // reportToFile writes the report to file
-func reportToFile(imageName string, vulnerabilities []vulnerabilityInfo, unapproved []string, file string) {
+func reportToFile(report *vulnerabilityReport, file string) {
+	writeReportFile(report, file, "JSON", writeJSONReport)
+}
+
+// reportToJUnitFile writes the report to file as JUnit XML
+func reportToJUnitFile(report *vulnerabilityReport, file string) {
+	writeReportFile(report, file, "JUnit", writeJUnitReport)
+}
+
+// reportToHTMLFile writes the report to file as a standalone HTML page
+func reportToHTMLFile(report *vulnerabilityReport, file string) {
+	writeReportFile(report, file, "HTML", writeHTMLReport)
+}
+
+// reportToSBOMFile writes the features and their vulnerabilities to file as a CycloneDX BOM
+func reportToSBOMFile(report *vulnerabilityReport, file string) {
+	writeReportFile(report, file, "CycloneDX", writeCycloneDXReport)
+}
+
+// reportToSPDXFile writes the features to file as an SPDX document
+func reportToSPDXFile(report *vulnerabilityReport, file string) {
+	writeReportFile(report, file, "SPDX", writeSPDXReport)
+}
+
+// reportToVEXFile writes the whitelisted vulnerabilities to file as an OpenVEX document
+func reportToVEXFile(report *vulnerabilityReport, file string) {
+	writeReportFile(report, file, "OpenVEX", writeOpenVEXReport)
+}
+
+// writeReportFile writes the report to file using the given formatter, nothing is written if no file is given
+func writeReportFile(report *vulnerabilityReport, file string, format string, formatter func(io.Writer, *vulnerabilityReport) error) {
 	if file == "" {
 		return
 	}
-	report := &vulnerabilityReport{
-		Image:           imageName,
-		Vulnerabilities: vulnerabilities,
-		Unapproved:      unapproved,
+	var buffer bytes.Buffer
+	if err := formatter(&buffer, report); err != nil {
+		logger.Fatalf("Could not create a %s report: %v", format, err)
 	}
+	if err := ioutil.WriteFile(file, buffer.Bytes(), 0644); err != nil {
+		logger.Fatalf("Could not create a %s report: could not write to file %v", format, err)
+	}
+}
+
+// writeJSONReport writes the report as indented JSON
+func writeJSONReport(w io.Writer, report *vulnerabilityReport) error {
 	reportJSON, err := json.MarshalIndent(report, "", "    ")
 	if err != nil {
-		logger.Fatalf("Could not create a report: report is not proper JSON %v", err)
+		return err
 	}
-	if err = ioutil.WriteFile(file, reportJSON, 0644); err != nil {
-		logger.Fatalf("Could not create a report: could not write to file %v", err)
+	_, err = w.Write(append(reportJSON, '\n'))
+	return err
+}
+
+// ndjsonVulnerability is a line of the NDJSON report
+type ndjsonVulnerability struct {
+	Image string `json:"image"`
+	vulnerabilityInfo
+}
+
+// writeNDJSONReport writes every vulnerability as a separate JSON object on its own line
+func writeNDJSONReport(w io.Writer, report *vulnerabilityReport) error {
+	encoder := json.NewEncoder(w)
+	for _, vulnerability := range report.Vulnerabilities {
+		if err := encoder.Encode(ndjsonVulnerability{report.Image, vulnerability}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// vulnerabilityStream writes the NDJSON lines of a scan as soon as the vulnerabilities are parsed from Clair's response, instead of once the report is complete
+type vulnerabilityStream struct {
+	encoder     *json.Encoder
+	baseline    map[string]bool
+	attribution map[string]dockerfileInstruction
+	streamed    bool
+}
+
+// newVulnerabilityStream returns the stream of the NDJSON output of the scan, nil when the output is not NDJSON or when the scan needs all
+// vulnerabilities before it can decide on one: to compare them with the base image or to enrich them
+func newVulnerabilityStream(w io.Writer, config scannerConfig) *vulnerabilityStream {
+	if config.format != "ndjson" || config.baseImage != "" || config.nvdEnrich || config.epss || config.minEPSS > 0 || config.kev || config.failOnKEV {
+		return nil
+	}
+	stream := &vulnerabilityStream{encoder: json.NewEncoder(w)}
+	if config.baselineFile != "" && !config.updateBaseline {
+		stream.baseline = baselineKeys(config.baselineFile)
+	}
+	return stream
+}
+
+// write writes the line of the vulnerability with the filters, baseline and whitelist applied like in the report, the methods of a nil stream do nothing
+func (stream *vulnerabilityStream) write(config scannerConfig, vulnerability vulnerabilityInfo) {
+	if stream == nil || excludedByFilters(config, vulnerability) {
+		return
+	}
+	vulnerabilities := []vulnerabilityInfo{vulnerability}
+	markDockerfileInstructions(vulnerabilities, stream.attribution)
+	vulnerabilities[0].InBaseline = stream.baseline[diffKey(vulnerability)]
+	checkForUnapprovedVulnerabilities(config, vulnerabilities)
+	if err := stream.encoder.Encode(ndjsonVulnerability{config.imageName, vulnerabilities[0]}); err != nil {
+		logger.Fatalf("Could not write a ndjson report: %v", err)
+	}
+}
+
+// finish marks the vulnerabilities of the scan as written, so they are not written again with the report
+func (stream *vulnerabilityStream) finish() {
+	if stream != nil {
+		stream.streamed = true
 	}
 }
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReportVerdict(t *testing.T) {
+	vulnerabilities := []vulnerabilityInfo{
+		{Vulnerability: "CVE-2017-3735", Severity: "Medium", FeatureName: "openssl", FeatureVersion: "1.0.2k-r0", Status: "Unapproved"},
+		{Vulnerability: "CVE-2017-3736", Severity: "Low", FeatureName: "openssl", FeatureVersion: "1.0.2k-r0", Status: "Approved"},
+	}
+	var output bytes.Buffer
+	reportVerdict(&output, "myapp:1.0", vulnerabilities, []string{"CVE-2017-3735"})
+	expected := "Image [myapp:1.0] contains 1 unapproved vulnerabilities\nCVE-2017-3735 Medium openssl 1.0.2k-r0\n"
+	if output.String() != expected {
+		t.Errorf("Expected the verdict with the unapproved vulnerability, got %q", output.String())
+	}
+
+	output.Reset()
+	reportVerdict(&output, "myapp:1.0", vulnerabilities[1:], nil)
+	if output.String() != "Image [myapp:1.0] contains NO unapproved vulnerabilities\n" {
+		t.Errorf("Expected the verdict without unapproved vulnerabilities, got %q", output.String())
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// cachedResult is the result of the analysis of an image by Clair, cached by the digest of the image
+type cachedResult struct {
+	Created         time.Time           `json:"created"`
+	ClairUpdate     string              `json:"clairupdate,omitempty"`
+	Digests         []string            `json:"digests"`
+	Layers          []string            `json:"layers"`
+	Features        []featureInfo       `json:"features"`
+	Vulnerabilities []vulnerabilityInfo `json:"vulnerabilities"`
+}
+
+// resultCacheEnabled tells whether the results of the scan are cached, the layer attribution of --dockerfile and --base-image needs the saved image
+func resultCacheEnabled(config scannerConfig) bool {
+	return config.cacheTTL > 0 && config.dockerfile == "" && config.baseImage == ""
+}
+
+// resultCachePath returns the path of the cached result of the image, by the Clair instance and the image ID or config digest of the image
+func resultCachePath(config scannerConfig) string {
+	key := sha256.Sum256([]byte(config.clairURL + "\n" + config.clairAPI + "\n" + config.imageDigests[0]))
+	return filepath.Join(cacheDir, "results", hex.EncodeToString(key[:])+".json")
+}
+
+// parseCacheTTL parses and validates how long the results of Clair are cached, no duration disables the cache
+func parseCacheTTL(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl < 0 {
+		logger.Fatalf("Invalid cache TTL %s given, use a duration like '24h'", value)
+	}
+	return ttl
+}
+
+// loadCachedResult returns the cached result of the image when it is younger than the cache TTL and Clair did not update its vulnerability data since
+func loadCachedResult(config scannerConfig) (cachedResult, bool) {
+	if !resultCacheEnabled(config) || len(config.imageDigests) == 0 {
+		return cachedResult{}, false
+	}
+	var result cachedResult
+	content, err := ioutil.ReadFile(resultCachePath(config))
+	if err != nil || json.Unmarshal(content, &result) != nil || time.Since(result.Created) > config.cacheTTL {
+		return cachedResult{}, false
+	}
+	if update := clairUpdateMarker(config); update != result.ClairUpdate {
+		logger.Infof("Clair updated its vulnerability data since image [%s] was scanned, scanning it again", config.imageName)
+		return cachedResult{}, false
+	}
+	logger.Infof("Using the result of image [%s] with digest %s cached at %s", config.imageName, resolvedDigest(config.imageName, config.imageDigests), result.Created.Format(time.RFC3339))
+	return result, true
+}
+
+// storeCachedResult caches the result of the analysis of the image, images without features are not cached
+func storeCachedResult(config scannerConfig, layerIds []string, features []featureInfo, vulnerabilities []vulnerabilityInfo) {
+	if !resultCacheEnabled(config) || vulnerabilities == nil || len(config.imageDigests) == 0 {
+		return
+	}
+	result := cachedResult{Created: time.Now(), ClairUpdate: clairUpdateMarker(config), Digests: config.imageDigests, Layers: layerIds, Features: features, Vulnerabilities: vulnerabilities}
+	content, _ := json.Marshal(result)
+	path := resultCachePath(config)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err == nil {
+		err = ioutil.WriteFile(path, content, 0644)
+	}
+	if err != nil {
+		logger.Warnf("Could not cache the result of image [%s]: %v", config.imageName, err)
+	}
+}
+
+// clairUpdateMarker identifies the latest update of the vulnerability data of Clair 4, so cached results are not used once the data changed,
+// Clair 2 and 3 have no API for their updates, their results are cached for the cache TTL
+func clairUpdateMarker(config scannerConfig) string {
+	if config.clairAPI != "v4" {
+		return ""
+	}
+	response, err := sendClairRequest("GET", config.clairURL+getUpdateOperationsURI+"?latest=true", nil)
+	if err != nil {
+		logger.Warnf("Could not get the latest update of Clair: %v", err)
+		return ""
+	}
+	defer response.Body.Close()
+	content, _ := ioutil.ReadAll(response.Body)
+	if response.StatusCode != 200 {
+		logger.Warnf("Could not get the latest update of Clair: Got response %d with message %s", response.StatusCode, string(content))
+		return ""
+	}
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
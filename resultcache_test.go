@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCachedResult(t *testing.T) {
+	initializeLogger("")
+	defer func(dir string) { cacheDir = dir }(cacheDir)
+	cacheDir, _ = ioutil.TempDir("", "clair-scanner-cache")
+	defer os.RemoveAll(cacheDir)
+
+	update := `{"debian": [{"ref": "1"}]}`
+	clair := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(update))
+	}))
+	defer clair.Close()
+
+	config := scannerConfig{imageName: "alpine:3.5", clairURL: clair.URL, clairAPI: "v4", cacheTTL: time.Hour, imageDigests: []string{"sha256:abc"}}
+	if _, cached := loadCachedResult(config); cached {
+		t.Fatalf("Expected no cached result before the image is scanned")
+	}
+	vulnerabilities := []vulnerabilityInfo{{Vulnerability: "CVE-2017-1000", FeatureName: "openssl"}}
+	storeCachedResult(config, []string{"layer"}, []featureInfo{{Name: "openssl"}}, vulnerabilities)
+	result, cached := loadCachedResult(config)
+	if !cached || len(result.Vulnerabilities) != 1 || result.Vulnerabilities[0].Vulnerability != "CVE-2017-1000" || result.Layers[0] != "layer" {
+		t.Fatalf("Expected the cached result of the image, got %v %v", cached, result)
+	}
+
+	if _, cached = loadCachedResult(scannerConfig{imageName: "alpine:3.6", clairURL: clair.URL, clairAPI: "v4", cacheTTL: time.Hour, imageDigests: []string{"sha256:def"}}); cached {
+		t.Errorf("Expected no cached result for another image")
+	}
+	withDockerfile := config
+	withDockerfile.dockerfile = "Dockerfile"
+	if _, cached = loadCachedResult(withDockerfile); cached {
+		t.Errorf("Expected no cached result with a Dockerfile")
+	}
+	update = `{"debian": [{"ref": "2"}]}`
+	if _, cached = loadCachedResult(config); cached {
+		t.Errorf("Expected no cached result once Clair updated its vulnerability data")
+	}
+}
+
+func TestCachedResultExpires(t *testing.T) {
+	initializeLogger("")
+	defer func(dir string) { cacheDir = dir }(cacheDir)
+	cacheDir, _ = ioutil.TempDir("", "clair-scanner-cache")
+	defer os.RemoveAll(cacheDir)
+
+	config := scannerConfig{imageName: "alpine:3.5", clairURL: "http://clair:6060", clairAPI: "v1", cacheTTL: time.Nanosecond, imageDigests: []string{"sha256:abc"}}
+	storeCachedResult(config, []string{"layer"}, []featureInfo{}, []vulnerabilityInfo{})
+	time.Sleep(time.Millisecond)
+	if _, cached := loadCachedResult(config); cached {
+		t.Errorf("Expected no cached result older than the cache TTL")
+	}
+	config.cacheTTL = time.Hour
+	if _, cached := loadCachedResult(config); !cached {
+		t.Errorf("Expected the cached result within the cache TTL")
+	}
+}
+
+func TestParseCacheTTL(t *testing.T) {
+	if ttl := parseCacheTTL(""); ttl != 0 {
+		t.Errorf("Expected no cache TTL, got %v", ttl)
+	}
+	if ttl := parseCacheTTL("24h"); ttl != 24*time.Hour {
+		t.Errorf("Expected a cache TTL of 24h, got %v", ttl)
+	}
+}
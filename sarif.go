@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strconv"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	toolName     = "clair-scanner"
+	toolURI      = "https://github.com/arminc/clair-scanner"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string              `json:"id"`
+	ShortDescription     sarifMessage        `json:"shortDescription"`
+	FullDescription      sarifMessage        `json:"fullDescription"`
+	HelpURI              string              `json:"helpUri,omitempty"`
+	Help                 sarifMessage        `json:"help"`
+	DefaultConfiguration sarifConfiguration  `json:"defaultConfiguration"`
+	Properties           sarifRuleProperties `json:"properties"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifRuleProperties struct {
+	Tags             []string `json:"tags"`
+	Severity         string   `json:"severity"`
+	SecuritySeverity string   `json:"security-severity"`
+	CVSSVector       string   `json:"cvssVector,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID       string             `json:"ruleId"`
+	RuleIndex    int                `json:"ruleIndex"`
+	Level        string             `json:"level"`
+	Message      sarifMessage       `json:"message"`
+	Locations    []sarifLocation    `json:"locations"`
+	Suppressions []sarifSuppression `json:"suppressions,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifSuppression struct {
+	Kind          string `json:"kind"`
+	Justification string `json:"justification"`
+}
+
+// sarifLevels maps a CVE severity to a SARIF result level
+var sarifLevels = map[string]string{
+	"Defcon1":    "error",
+	"Critical":   "error",
+	"High":       "error",
+	"Medium":     "warning",
+	"Low":        "note",
+	"Negligible": "note",
+	"Unknown":    "note",
+}
+
+// sarifSecuritySeverities maps a CVE severity to the score used by GitHub Code Scanning to rank alerts
+var sarifSecuritySeverities = map[string]string{
+	"Defcon1":    "10.0",
+	"Critical":   "9.5",
+	"High":       "8.0",
+	"Medium":     "5.5",
+	"Low":        "2.0",
+	"Negligible": "0.5",
+	"Unknown":    "0.0",
+}
+
+// writeSarifReport writes the report as a SARIF 2.1.0 log
+func writeSarifReport(w io.Writer, report *vulnerabilityReport) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           toolName,
+			InformationURI: toolURI,
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+
+	ruleIndexes := make(map[string]int)
+	for _, vulnerability := range report.Vulnerabilities {
+		index, exists := ruleIndexes[vulnerability.Vulnerability]
+		if !exists {
+			index = len(run.Tool.Driver.Rules)
+			ruleIndexes[vulnerability.Vulnerability] = index
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRuleFor(vulnerability))
+		}
+
+		result := sarifResult{
+			RuleID:    vulnerability.Vulnerability,
+			RuleIndex: index,
+			Level:     sarifLevel(vulnerability.Severity),
+			Message:   sarifMessage{Text: sarifMessageText(vulnerability)},
+			Locations: []sarifLocation{sarifLocationFor(report, vulnerability)},
+		}
+		if vulnerability.Status == "Approved" {
+			result.Suppressions = []sarifSuppression{{Kind: "external", Justification: sarifJustification(vulnerability)}}
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}})
+}
+
+// sarifLocationFor points to the Dockerfile instruction that added the vulnerable package, or to the image when it is unknown
+func sarifLocationFor(report *vulnerabilityReport, vulnerability vulnerabilityInfo) sarifLocation {
+	if report.Dockerfile == "" || vulnerability.InstructionLine == 0 {
+		return sarifLocation{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: report.Image}}}
+	}
+	return sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(report.Dockerfile)},
+		Region:           &sarifRegion{StartLine: vulnerability.InstructionLine},
+	}}
+}
+
+// sarifMessageText describes the vulnerable package, the fixed version and the advisory of a vulnerability
+func sarifMessageText(vulnerability vulnerabilityInfo) string {
+	text := vulnerability.Vulnerability + " in " + vulnerability.FeatureName + " " + vulnerability.FeatureVersion
+	if vulnerability.FixedBy != "" {
+		text += ", fixed in " + vulnerability.FixedBy
+	}
+	if cvss := formatCVSS(vulnerability); cvss != "" {
+		text += ", CVSS " + cvss
+	}
+	if epss := formatEPSS(vulnerability); epss != "" {
+		text += ", EPSS " + epss
+	}
+	if vulnerability.KnownExploited {
+		text += ", known to be exploited"
+	}
+	if vulnerability.Link != "" {
+		text += ", see " + vulnerability.Link
+	}
+	return text
+}
+
+// sarifRuleFor creates the SARIF rule describing a vulnerability
+func sarifRuleFor(vulnerability vulnerabilityInfo) sarifRule {
+	return sarifRule{
+		ID:                   vulnerability.Vulnerability,
+		ShortDescription:     sarifMessage{Text: vulnerability.Severity + " " + vulnerability.Vulnerability},
+		FullDescription:      sarifMessage{Text: vulnerability.Description},
+		HelpURI:              vulnerability.Link,
+		Help:                 sarifMessage{Text: vulnerability.Description + "\n\n" + vulnerability.Link},
+		DefaultConfiguration: sarifConfiguration{Level: sarifLevel(vulnerability.Severity)},
+		Properties: sarifRuleProperties{
+			Tags:             []string{"security", "vulnerability"},
+			Severity:         vulnerability.Severity,
+			SecuritySeverity: sarifSecuritySeverity(vulnerability),
+			CVSSVector:       vulnerability.CVSSVector,
+		},
+	}
+}
+
+// sarifSecuritySeverity returns the CVSS score of a vulnerability or, without CVSS data, a score based on its severity
+func sarifSecuritySeverity(vulnerability vulnerabilityInfo) string {
+	if vulnerability.CVSSScore > 0 {
+		return strconv.FormatFloat(vulnerability.CVSSScore, 'f', 1, 64)
+	}
+	return sarifSecuritySeverities[vulnerability.Severity]
+}
+
+// sarifLevel returns the SARIF level of a CVE severity, unknown severities are reported as notes
+func sarifLevel(severity string) string {
+	if level, exists := sarifLevels[severity]; exists {
+		return level
+	}
+	return "note"
+}
+
+// sarifJustification tells why an approved vulnerability is suppressed, including the reason and owner of its whitelist entry
+func sarifJustification(vulnerability vulnerabilityInfo) string {
+	justification := "Approved by clair-scanner whitelist"
+	if vulnerability.WhitelistReason != "" {
+		justification += ": " + vulnerability.WhitelistReason
+	}
+	if vulnerability.WhitelistOwner != "" {
+		justification += " (owner " + vulnerability.WhitelistOwner + ")"
+	}
+	return justification
+}
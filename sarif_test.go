@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestWriteSarifReport(t *testing.T) {
+	report := &vulnerabilityReport{
+		Image: "alpine:3.5",
+		Vulnerabilities: []vulnerabilityInfo{
+			{FeatureName: "zlib", FeatureVersion: "1.2.8", Vulnerability: "CVE-2016-9840", Severity: "High", Status: "Unapproved"},
+			{FeatureName: "zlib-dev", FeatureVersion: "1.2.8", Vulnerability: "CVE-2016-9840", Severity: "High", Status: "Unapproved"},
+			{FeatureName: "musl", FeatureVersion: "1.1.15", Vulnerability: "CVE-2017-15650", Severity: "Low", Status: "Approved"},
+		},
+	}
+
+	var buffer bytes.Buffer
+	if err := writeSarifReport(&buffer, report); err != nil {
+		t.Fatalf("Could not write SARIF report: %v", err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(buffer.Bytes(), &log); err != nil {
+		t.Fatalf("SARIF report is not JSON: %v", err)
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != 2 {
+		t.Errorf("Expected 2 rules, got %d", len(run.Tool.Driver.Rules))
+	}
+	if len(run.Results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(run.Results))
+	}
+	if run.Results[1].RuleIndex != 0 || run.Results[0].Level != "error" {
+		t.Errorf("Expected the second result to share the first error rule, got %+v", run.Results[1])
+	}
+	if len(run.Results[2].Suppressions) != 1 || run.Results[2].Level != "note" {
+		t.Errorf("Expected the approved result to be a suppressed note, got %+v", run.Results[2])
+	}
+}
@@ -1,64 +1,295 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"os"
+	"path"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
 )
 
 type vulnerabilitiesWhitelist struct {
-	GeneralWhitelist map[string]string            //[key: CVE and value: CVE description]
-	Images           map[string]map[string]string // image name with [key: CVE and value: CVE description]
+	Extends          string                               // path or URL of a base whitelist, relative to this whitelist
+	GeneralWhitelist map[string]whitelistEntry            //[key: CVE and value: CVE description]
+	Images           map[string]map[string]whitelistEntry // image name with [key: CVE and value: CVE description]
+	Packages         map[string]whitelistEntry            //[key: package name and value: description and optional version range]
+	Rules            []whitelistRule                      // accept whole classes of vulnerabilities by severity, namespace and image
 }
 
 const tmpPrefix = "clair-scanner-"
 
+var imageIDPattern = regexp.MustCompile(`^(sha256:)?[0-9a-f]{12,64}$`)
+
 type scannerConfig struct {
 	imageName          string
 	whitelist          vulnerabilitiesWhitelist
 	clairURL           string
+	clairAPI           string
+	clairWait          time.Duration
+	deleteLayers       bool
+	stream             bool
+	ociDir             string
+	tarFile            string
+	platform           string
+	registry           bool
+	clairPull          bool
 	scannerIP          string
+	dockerDesktopHost  string
+	port               int
+	listenAddr         string
+	serverTLS          *tls.Config
+	serverURL          string
 	reportFile         string
+	junitFile          string
+	htmlFile           string
+	sbomFile           string
+	spdxFile           string
+	vexFile            string
+	generateWhitelist  string
+	dockerfile         string
+	baseImage          string
+	baseImageMode      string
+	baselineFile       string
+	updateBaseline     bool
+	format             string
+	ndjson             *vulnerabilityStream // writes the NDJSON output while Clair's response is parsed, nil when it is written with the report
 	whitelistThreshold string
+	minCVSS            float64
+	ignoreSeverities   []string
+	includePackages    []string
+	excludePackages    []string
+	namespaces         []string
 	reportAll          bool
+	onlyFixed          bool
+	nvdEnrich          bool
+	nvdAPIKey          string
+	epss               bool
+	minEPSS            float64
+	kev                bool
+	failOnKEV          bool
 	quiet              bool
 	exitWhenNoFeatures bool
+	imageDigests       []string
+	cacheTTL           time.Duration
+	history            bool
+	historyRetention   time.Duration
+	database           string // PostgreSQL URL the results are inserted into
+	elasticsearch      elasticsearchConfig
+	splunk             splunkConfig
+	datadog            datadogConfig
+	pushgateway        pushgatewayConfig
+	containers         []string
 }
 
 // scan orchestrates the scanning process of an image
-func scan(config scannerConfig) []string {
+func scan(config scannerConfig) *vulnerabilityReport {
+	report := scanImage(config)
+	if report == nil {
+		return nil // exit when no features
+	}
+	report.UnusedWhitelist = unusedWhitelistEntries(imageReferences(report.Image, report.Digests), config.whitelist, report.Vulnerabilities)
+	reportUnusedWhitelistEntries(report.UnusedWhitelist)
+
+	// Report vulnerabilities
+	if config.format == "table" {
+		reportToConsole(config.imageName, report.Vulnerabilities, report.Unapproved, config.reportAll, config.quiet)
+		reportLayerAttribution(report, config.quiet)
+	} else if config.ndjson == nil || !config.ndjson.streamed {
+		reportToStdout(report, config.format)
+	}
+	reportToFile(report, config.reportFile)
+	reportToJUnitFile(report, config.junitFile)
+	reportToHTMLFile(report, config.htmlFile)
+	reportToSBOMFile(report, config.sbomFile)
+	reportToSPDXFile(report, config.spdxFile)
+	reportToVEXFile(report, config.vexFile)
+	reportToWhitelistFile(report, config.generateWhitelist)
+	exportToPostgres(report, config.database)
+	exportToElasticsearch(report, config.elasticsearch)
+	exportToSplunk(report, config.splunk)
+	exportToDatadog(report, config.datadog)
+	pushToGateway(report, config.pushgateway)
+	if config.updateBaseline {
+		reportToBaselineFile(report, config.baselineFile)
+	}
+	if config.history {
+		recordHistory(report, config.historyRetention)
+	}
+
+	return report
+}
+
+// forImage returns the scanner configuration to scan one of several images, the image is added to the names of the report files,
+// report.json of myorg/app:1.0 becomes report-myorg-app-1.0.json
+func forImage(config scannerConfig, imageName string) scannerConfig {
+	config.imageName = imageName
+	name := imageName
+	if i := strings.Index(name, "/"); i >= 0 && (strings.ContainsAny(name[:i], ".:") || name[:i] == "localhost") {
+		name = name[i+1:] // leave out the registry
+	}
+	name = strings.NewReplacer(":", "-", "@", "-").Replace(name)
+	for _, file := range []*string{&config.reportFile, &config.junitFile, &config.htmlFile, &config.sbomFile, &config.spdxFile, &config.vexFile, &config.generateWhitelist} {
+		*file = platformFile(*file, name)
+	}
+	return config
+}
+
+// streamingLayers returns the layers of the image Clair already analyzed when streaming, nil when the image is saved completely
+func streamingLayers(config scannerConfig, imageName string) map[string]bool {
+	if !config.stream {
+		return nil
+	}
+	return knownLayerDigests(config, imageName)
+}
+
+// saveImage saves the image to the temporary folder from the OCI image layout, image archive or registry when asked for, else from the Docker daemon, and returns its digests
+func saveImage(config scannerConfig, imageName string, tmpPath string) []string {
+	span := tracer.startSpan("save image", spanKindInternal, map[string]string{"image": imageName})
+	defer span.finish(nil)
+	if config.ociDir != "" {
+		return saveOCIImage(config.ociDir, imageName, config.platform, tmpPath)
+	} else if config.tarFile != "" {
+		return saveImageArchive(config.tarFile, imageName, config.platform, tmpPath)
+	} else if config.registry {
+		return saveRegistryImage(imageName, config.platform, tmpPath, config.clairPull)
+	}
+	validateDockerPlatform(imageName, config.platform)
+	saveDockerImage(imageName, tmpPath, streamingLayers(config, imageName))
+	return getImageDigests(imageName)
+}
+
+// scanImage analyzes an image with Clair and checks its vulnerabilities against the whitelist
+func scanImage(config scannerConfig) *vulnerabilityReport {
+	started := time.Now()
+	trace := tracer.startScan("scan "+config.imageName, map[string]string{"image": config.imageName, "clair.url": config.clairURL})
+	defer tracer.finishScan(trace, "")
+	if config.clairWait > 0 {
+		waitForClair(config.clairURL, config.clairAPI, config.clairWait)
+	}
+	if config.clairAPI == "auto" {
+		config.clairAPI = detectClairAPI(config.clairURL)
+	}
+	trace.setAttribute("clair.api", config.clairAPI)
+
+	layerIds, features, vulnerabilities := analyzeImage(&config)
+	if vulnerabilities == nil {
+		return nil
+	}
+	vulnerabilities = filterVulnerabilities(config, vulnerabilities)
+	if config.baselineFile != "" && !config.updateBaseline {
+		markBaselineVulnerabilities(config.baselineFile, vulnerabilities)
+	}
+	if config.nvdEnrich {
+		enrichFromNVD(vulnerabilities, config.nvdAPIKey)
+	}
+	if config.epss || config.minEPSS > 0 {
+		enrichWithEPSS(vulnerabilities)
+	}
+	if config.kev || config.failOnKEV {
+		flagKnownExploited(vulnerabilities)
+	}
+
+	//Check vulnerabilities against whitelist
+	unapproved := checkForUnapprovedVulnerabilities(config, vulnerabilities)
+
+	return &vulnerabilityReport{
+		Image:           config.imageName,
+		Platform:        config.platform,
+		Digest:          resolvedDigest(config.imageName, config.imageDigests),
+		Digests:         config.imageDigests,
+		Layers:          layerIds,
+		Features:        features,
+		Unapproved:      unapproved,
+		Vulnerabilities: vulnerabilities,
+		Dockerfile:      config.dockerfile,
+		Containers:      config.containers,
+		started:         started,
+		clairAPI:        config.clairAPI,
+	}
+}
+
+// analyzeImage saves the image, has Clair analyze its layers and returns its layers, features and vulnerabilities,
+// from the result cache when the image was scanned before
+func analyzeImage(config *scannerConfig) ([]string, []featureInfo, []vulnerabilityInfo) {
+	//Images of the Docker daemon are looked up in the result cache by their image ID before they are saved
+	if resultCacheEnabled(*config) && isDaemonImage(*config) {
+		config.imageDigests = getImageDigests(config.imageName)
+		if result, cached := loadCachedResult(*config); cached {
+			return result.Layers, result.Features, result.Vulnerabilities
+		}
+	}
+
 	//Create a temporary folder where the docker image layers are going to be stored
 	tmpPath := createTmpPath(tmpPrefix)
 	defer os.RemoveAll(tmpPath)
 
-	saveDockerImage(config.imageName, tmpPath)
+	var baseLayerIds []string
+	if config.baseImage != "" {
+		//The base image is saved first, the image overwrites its manifest.json and shares its layers
+		saveImage(*config, config.baseImage, tmpPath)
+		baseLayerIds = getImageLayerIds(tmpPath)
+	}
+	config.imageDigests = saveImage(*config, config.imageName, tmpPath)
+	if !isDaemonImage(*config) {
+		if result, cached := loadCachedResult(*config); cached {
+			return result.Layers, result.Features, result.Vulnerabilities
+		}
+	}
+	logger.Infof("Scanning image [%s] with digest %s", config.imageName, resolvedDigest(config.imageName, config.imageDigests))
 	layerIds := getImageLayerIds(tmpPath)
 
-	//Start a server that can serve Docker image layers to Clair
-	server := httpFileServer(tmpPath)
-	defer server.Shutdown(nil)
-
-	//Analyze the layers
-	analyzeLayers(layerIds, config.clairURL, config.scannerIP)
-	vulnerabilities := getVulnerabilities(config, layerIds)
-
-	if vulnerabilities == nil {
-		return nil; // exit when no features
+	//Start a server that can serve Docker image layers to Clair, unless Clair downloads them from the registry
+	if !remoteOnly(append(baseLayerIds, layerIds...)) {
+		if config.scannerIP == "" {
+			config.scannerIP = detectScannerIP(config.clairURL, config.dockerDesktopHost)
+		}
+		token := newServerToken()
+		handler := layerHandler(tmpPath, token, append(baseLayerIds, layerIds...))
+		server, port := httpFileServer(handler, listenAddress(config.listenAddr, config.port), config.serverTLS)
+		defer server.Shutdown(context.Background())
+		config.serverURL = layerServerURL(config.scannerIP, port, token, config.serverTLS != nil)
 	}
 
-	//Check vulnerabilities against whitelist and report
-	unapproved := checkForUnapprovedVulnerabilities(config.imageName, vulnerabilities, config.whitelist, config.whitelistThreshold)
+	var attribution map[string]dockerfileInstruction
+	if config.dockerfile != "" {
+		attribution = attributeLayersToDockerfile(layerIds, getImageHistory(tmpPath), parseDockerfile(config.dockerfile))
+		if config.ndjson != nil {
+			config.ndjson.attribution = attribution
+		}
+	}
 
-	// Report vulnerabilities
-	reportToConsole(config.imageName, vulnerabilities, unapproved, config.reportAll, config.quiet)
-	reportToFile(config.imageName, vulnerabilities, unapproved, config.reportFile)
+	//Analyze the layers
+	analyzeLayers(*config, tmpPath, layerIds)
+	features, vulnerabilities := getVulnerabilities(*config, tmpPath, layerIds)
+	if attribution != nil && vulnerabilities != nil {
+		markDockerfileInstructions(vulnerabilities, attribution)
+	}
+	if config.baseImage != "" && vulnerabilities != nil {
+		markBaseImageVulnerabilities(*config, tmpPath, baseLayerIds, vulnerabilities)
+	}
+	if config.deleteLayers {
+		deleteLayers(*config, tmpPath, layerIds)
+		if baseLayerIds != nil {
+			deleteLayers(*config, tmpPath, baseLayerIds)
+		}
+	}
+	storeCachedResult(*config, layerIds, features, vulnerabilities)
+	return layerIds, features, vulnerabilities
+}
 
-	return unapproved
+// isDaemonImage tells whether the image is saved from the Docker daemon
+func isDaemonImage(config scannerConfig) bool {
+	return config.ociDir == "" && config.tarFile == "" && !config.registry
 }
 
-// checkForUnapprovedVulnerabilities checks if the found vulnerabilities are approved or not in the whitelist
-func checkForUnapprovedVulnerabilities(imageName string, vulnerabilities []vulnerabilityInfo, whitelist vulnerabilitiesWhitelist, whitelistThreshold string) []string {
+// checkForUnapprovedVulnerabilities checks if the found vulnerabilities are approved or not in the whitelist, sets the status of every vulnerability
+// with the reason and owner of the whitelist entry approving it and returns the CVEs with an unapproved vulnerability
+func checkForUnapprovedVulnerabilities(config scannerConfig, vulnerabilities []vulnerabilityInfo) []string {
 	unapproved := []string{}
-	imageVulnerabilities := getImageVulnerabilities(imageName, whitelist.Images)
+	references := imageReferences(config.imageName, config.imageDigests)
 
 	for i := 0; i < len(vulnerabilities); i++ {
 		vulnerability := vulnerabilities[i].Vulnerability
@@ -66,36 +297,142 @@ func checkForUnapprovedVulnerabilities(imageName string, vulnerabilities []vulne
 		vulnerable := true
 
 		//Check if the vulnerability has a severity less than our threshold severity
-		if SeverityMap[severity] > SeverityMap[whitelistThreshold] {
+		if SeverityMap[severity] > SeverityMap[config.whitelistThreshold] {
 			vulnerable = false
 		}
 
-		//Check if the vulnerability exists in the GeneralWhitelist
-		if vulnerable {
-			if _, exists := whitelist.GeneralWhitelist[vulnerability]; exists {
-				vulnerable = false
-			}
+		//Check if the vulnerability has a CVSS score below the minimum CVSS score, vulnerabilities without CVSS data are not approved by it
+		if vulnerable && vulnerabilities[i].CVSSScore > 0 && vulnerabilities[i].CVSSScore < config.minCVSS {
+			vulnerable = false
 		}
 
-		//If not in GeneralWhitelist check if the vulnerability exists in the imageVulnerabilities
-		if vulnerable && len(imageVulnerabilities) > 0 {
-			if _, exists := imageVulnerabilities[vulnerability]; exists {
-				vulnerable = false
-			}
+		//Check if the vulnerability has an EPSS score below the minimum EPSS score, vulnerabilities without EPSS score are not approved by it
+		if vulnerable && vulnerabilities[i].EPSS > 0 && vulnerabilities[i].EPSS < config.minEPSS {
+			vulnerable = false
+		}
+
+		//Check if the vulnerability is inherited from the base image
+		if vulnerable && vulnerabilities[i].InBaseImage {
+			vulnerable = false
+		}
+
+		//Check if the vulnerability was already accepted in the baseline
+		if vulnerable && vulnerabilities[i].InBaseline {
+			vulnerable = false
+		}
+
+		//Check if the vulnerability can be fixed when only fixable vulnerabilities may fail the scan
+		if vulnerable && config.onlyFixed && vulnerabilities[i].FixedBy == "" {
+			vulnerable = false
+		}
+
+		//Check if the vulnerability is approved by the general or image whitelist, the whitelisted packages or a rule, the same CVE can be approved in one package and not in another
+		entry, whitelisted := whitelistEntryFor(references, vulnerabilities[i], config.whitelist)
+		if vulnerable && whitelisted {
+			vulnerable = false
+		}
+
+		//Known exploited vulnerabilities always fail when asked for, even if they are approved
+		if config.failOnKEV && vulnerabilities[i].KnownExploited {
+			vulnerable = true
 		}
 		if vulnerable {
-			unapproved = append(unapproved, vulnerability)
+			vulnerabilities[i].Status = "Unapproved"
+			if !contains(unapproved, vulnerability) {
+				unapproved = append(unapproved, vulnerability)
+			}
+			continue
 		}
+		vulnerabilities[i].Status = "Approved"
+		vulnerabilities[i].Whitelisted = whitelisted
+		vulnerabilities[i].WhitelistReason = entry.justification()
+		vulnerabilities[i].WhitelistOwner = entry.Owner
 	}
 	return unapproved
 }
 
-// getImageVulnerabilities returns image specific whitelist of vulnerabilities from whitelistImageVulnerabilities
-func getImageVulnerabilities(imageName string, whitelistImageVulnerabilities map[string]map[string]string) map[string]string {
-	var imageVulnerabilities map[string]string
-	imageWithoutVersion := strings.Split(imageName, ":") // TODO there is a bug here if it is a private registry with a custom port registry:777/ubuntu:tag
-	if val, exists := whitelistImageVulnerabilities[imageWithoutVersion[0]]; exists {
-		imageVulnerabilities = val
+// whitelistEntryFor returns the whitelist entry that approves the vulnerability for the image
+func whitelistEntryFor(references []string, vulnerability vulnerabilityInfo, whitelist vulnerabilitiesWhitelist) (whitelistEntry, bool) {
+	if entry, exists := lookupWhitelistEntry(whitelist.GeneralWhitelist, vulnerability.Vulnerability); exists {
+		return entry, true
+	}
+	if entry, exists := lookupWhitelistEntry(getImageVulnerabilities(references, whitelist.Images), vulnerability.Vulnerability); exists {
+		return entry, true
+	}
+	if entry, exists := lookupPackageEntry(whitelist.Packages, vulnerability); exists {
+		return entry, true
+	}
+	return lookupWhitelistRule(whitelist.Rules, references, vulnerability)
+}
+
+// getImageVulnerabilities returns image specific whitelist of vulnerabilities from whitelistImageVulnerabilities, the entries of every image name or glob pattern matching one of the image references are combined
+func getImageVulnerabilities(references []string, whitelistImageVulnerabilities map[string]map[string]whitelistEntry) map[string]whitelistEntry {
+	var imageVulnerabilities map[string]whitelistEntry
+	images := make([]string, 0, len(whitelistImageVulnerabilities))
+	for image := range whitelistImageVulnerabilities {
+		images = append(images, image)
+	}
+	//Entries of more specific image names like ubuntu:16.04 override the entries of the repository
+	sort.Slice(images, func(i, j int) bool {
+		return len(images[i]) < len(images[j])
+	})
+	for _, image := range images {
+		if !matchesAnyReference(image, references) {
+			continue
+		}
+		if imageVulnerabilities == nil {
+			imageVulnerabilities = make(map[string]whitelistEntry)
+		}
+		for cve, entry := range whitelistImageVulnerabilities[image] {
+			imageVulnerabilities[cve] = entry
+		}
 	}
 	return imageVulnerabilities
 }
+
+// imageReferences returns the names a whitelist can use for the image: the repository, the repository with tag and the repository with every digest of the image
+func imageReferences(imageName string, digests []string) []string {
+	if isImageID(imageName) {
+		return append([]string{imageName}, digests...)
+	}
+	if i := strings.Index(imageName, "@"); i >= 0 {
+		return append(imageReferences(imageName[:i], digests), imageName)
+	}
+	repository, tag := imageName, "latest"
+	if i := strings.LastIndex(imageName, ":"); i > strings.LastIndex(imageName, "/") {
+		repository, tag = imageName[:i], imageName[i+1:]
+	}
+	references := []string{repository, repository + ":" + tag}
+	for _, digest := range digests {
+		references = append(references, repository+"@"+digest)
+	}
+	return references
+}
+
+// isImageID tells whether the image is referenced by its ID, e.g. 'sha256:3f57d9401f8d' or '3f57d9401f8d', instead of by name
+func isImageID(imageName string) bool {
+	return imageIDPattern.MatchString(imageName)
+}
+
+// resolvedDigest returns the digest of the content of the scanned image: the digest it is referenced by,
+// else its registry digest, else its image ID
+func resolvedDigest(imageName string, digests []string) string {
+	if i := strings.Index(imageName, "@"); i >= 0 {
+		return imageName[i+1:]
+	}
+	if len(digests) > 1 {
+		return digests[1]
+	} else if len(digests) == 1 {
+		return digests[0]
+	}
+	return ""
+}
+
+func matchesAnyReference(image string, references []string) bool {
+	for _, reference := range references {
+		if matched, _ := path.Match(image, reference); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net"
+	"net/url"
+)
+
+const (
+	dockerBridgeInterface = "docker0"
+	dockerDesktopHost     = "host.docker.internal"
+	defaultScannerIP      = "localhost"
+)
+
+// detectScannerIP detects the IP address Clair can download the layers from, the local address of the route to Clair,
+// or when Clair is reached over the loopback interface, e.g. a Clair container with a published port, the host name of
+// the host in Docker Desktop or the address of the Docker bridge
+func detectScannerIP(clairURL string, desktopHost string) string {
+	localIP := localAddressTo(clairURL)
+	if (localIP != nil && !localIP.IsLoopback()) || !isDockerDesktop() {
+		desktopHost = ""
+	}
+	ip := routableIP(localIP, interfaceIP(dockerBridgeInterface), desktopHost)
+	logger.Infof("Detected IP address %s, use --ip when Clair can't download the layers from it", ip)
+	return ip
+}
+
+// routableIP chooses the address Clair can reach the scanner on when the local address is a loopback address,
+// the host name of the host in Docker Desktop or else the bridge address
+func routableIP(localIP net.IP, bridgeIP net.IP, desktopHost string) string {
+	if localIP != nil && !localIP.IsLoopback() {
+		return localIP.String()
+	}
+	if desktopHost != "" {
+		return desktopHost
+	}
+	if bridgeIP != nil {
+		return bridgeIP.String()
+	}
+	return defaultScannerIP
+}
+
+// localAddressTo returns the local address of the route to the host of the URL, no packets are sent
+func localAddressTo(clairURL string) net.IP {
+	parsed, err := url.Parse(clairURL)
+	if err != nil || parsed.Hostname() == "" {
+		return nil
+	}
+	port := parsed.Port()
+	if port == "" {
+		port = "80"
+	}
+	conn, err := net.Dial("udp", net.JoinHostPort(parsed.Hostname(), port))
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP
+}
+
+// interfaceIP returns the IPv4 address of the network interface, nil when it doesn't exist
+func interfaceIP(name string) net.IP {
+	networkInterface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil
+	}
+	addresses, err := networkInterface.Addrs()
+	if err != nil {
+		return nil
+	}
+	for _, address := range addresses {
+		if ipNet, ok := address.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			return ipNet.IP
+		}
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+func TestRoutableIP(t *testing.T) {
+	bridge := net.ParseIP("172.17.0.1")
+	for _, test := range []struct {
+		local, bridge net.IP
+		desktopHost   string
+		expected      string
+	}{
+		{net.ParseIP("10.0.0.5"), bridge, dockerDesktopHost, "10.0.0.5"},
+		{net.ParseIP("127.0.0.1"), bridge, dockerDesktopHost, dockerDesktopHost},
+		{net.ParseIP("127.0.0.1"), bridge, "", "172.17.0.1"},
+		{net.ParseIP("127.0.0.1"), nil, "", "localhost"},
+		{nil, nil, "", "localhost"},
+	} {
+		if ip := routableIP(test.local, test.bridge, test.desktopHost); ip != test.expected {
+			t.Errorf("Expected %s for local address %v and bridge %v, got %s", test.expected, test.local, test.bridge, ip)
+		}
+	}
+}
+
+func TestLocalAddressToLoopback(t *testing.T) {
+	if ip := localAddressTo("http://127.0.0.1:6060"); ip == nil || !ip.IsLoopback() {
+		t.Errorf("Expected a loopback address on the route to localhost, got %v", ip)
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pborman/uuid"
+)
+
+// Statuses of a scan queued with the REST API of the server mode
+const (
+	scanQueued  = "queued"
+	scanRunning = "running"
+	scanDone    = "done"
+	scanFailed  = "failed"
+)
+
+// scanQueueSize is the number of scans that can wait for the scanner, more scans are refused
+const scanQueueSize = 100
+
+// scanHistorySize is the number of finished scans the server keeps, the oldest finished scan is forgotten first
+const scanHistorySize = 1000
+
+// scanJob is a scan queued with the REST API of the server mode
+type scanJob struct {
+	ID       string               `json:"id"`
+	Image    string               `json:"image"`
+	Status   string               `json:"status"`
+	Error    string               `json:"error,omitempty"`
+	ExitCode int                  `json:"exitcode"`
+	Created  time.Time            `json:"created"`
+	Finished *time.Time           `json:"finished,omitempty"`
+	Report   *vulnerabilityReport `json:"report,omitempty"`
+}
+
+// scanFailure is the panic of a fatal error in server mode, it fails the scan instead of exiting the server
+type scanFailure string
+
+// scanService queues the scans requested with the REST API and scans the images one after the other
+type scanService struct {
+	mutex     sync.Mutex
+	jobs      map[string]*scanJob
+	finished  []string // IDs of the finished jobs, from the oldest
+	queue     chan *scanJob
+	newConfig func(imageName string) scannerConfig
+	token     string
+}
+
+func newScanService(newConfig func(imageName string) scannerConfig, token string) *scanService {
+	return &scanService{jobs: make(map[string]*scanJob), queue: make(chan *scanJob, scanQueueSize), newConfig: newConfig, token: token}
+}
+
+// serve serves the REST API on the address and scans the queued images until the server stops
+func (service *scanService) serve(address string) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		logger.Fatalf("Could not start the server: %v", err)
+	}
+	logger.Infof("Serving the REST API on %s", listener.Addr())
+	logger.recoverFatal = true
+	go service.run()
+	if err = http.Serve(listener, service.router()); err != nil {
+		logger.recoverFatal = false
+		logger.Fatalf("Server stopped: %v", err)
+	}
+}
+
+func (service *scanService) router() http.Handler {
+	router := httprouter.New()
+	router.POST("/scan", service.authorized(service.postScan))
+	router.GET("/scans", service.authorized(service.listScans))
+	router.GET("/scans/:id", service.authorized(service.getScan))
+	router.GET("/health", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	router.Handler("GET", "/metrics", service.metricsHandler())
+	return router
+}
+
+// authorized requires the bearer token of the server, when the server has a token
+func (service *scanService) authorized(handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		if service.token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+service.token)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid or missing bearer token"})
+			return
+		}
+		handle(w, r, params)
+	}
+}
+
+// postScan queues the scan of the image of the request, e.g. {"image": "alpine:3.18"}
+func (service *scanService) postScan(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var request struct {
+		Image string `json:"image"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Image == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": `request must be json with the image to scan, e.g. {"image": "alpine:3.18"}`})
+		return
+	}
+	job, queued := service.enqueue(request.Image)
+	if !queued {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": fmt.Sprintf("%d scans are queued already, try again later", scanQueueSize)})
+		return
+	}
+	w.Header().Set("Location", "/scans/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// enqueue queues the scan of the image, false when the queue is full
+func (service *scanService) enqueue(image string) (scanJob, bool) {
+	job := &scanJob{ID: uuid.New(), Image: image, Status: scanQueued, Created: time.Now().UTC()}
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	select {
+	case service.queue <- job:
+		service.jobs[job.ID] = job
+		return *job, true
+	default:
+		return scanJob{}, false
+	}
+}
+
+// listScans returns the scans without their reports, from the newest, only the scans of the image when asked for with ?image=
+func (service *scanService) listScans(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	image := r.URL.Query().Get("image")
+	service.mutex.Lock()
+	scans := []scanJob{}
+	for _, job := range service.jobs {
+		if image == "" || job.Image == image {
+			scan := *job
+			scan.Report = nil
+			scans = append(scans, scan)
+		}
+	}
+	service.mutex.Unlock()
+	sort.Slice(scans, func(i, j int) bool {
+		return scans[i].Created.After(scans[j].Created)
+	})
+	writeJSON(w, http.StatusOK, scans)
+}
+
+// rescan queues a scan of the images at every time of the schedule, so their reports stay up to date with the vulnerability data of Clair
+func (service *scanService) rescan(schedule cronSchedule, images []string) {
+	for {
+		next := schedule.next(time.Now())
+		logger.Infof("Next rescan of %d images at %s", len(images), next.Format(time.RFC3339))
+		time.Sleep(time.Until(next))
+		for _, image := range images {
+			if _, queued := service.enqueue(image); !queued {
+				logger.Warnf("Could not queue the rescan of [%s], %d scans are queued already", image, scanQueueSize)
+			}
+		}
+	}
+}
+
+// getScan returns the status of a scan, with its report once it is done
+func (service *scanService) getScan(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	job, exists := service.jobs[params.ByName("id")]
+	if !exists {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no scan " + params.ByName("id")})
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// run scans the queued images one after the other, the scanner uses a temporary folder and layer server per scan
+func (service *scanService) run() {
+	for job := range service.queue {
+		service.runJob(job)
+	}
+}
+
+func (service *scanService) runJob(job *scanJob) {
+	defer func() {
+		if failure := recover(); failure != nil {
+			message, isFailure := failure.(scanFailure)
+			if !isFailure {
+				panic(failure)
+			}
+			service.finish(job, nil, exitCodeError, string(message))
+		}
+	}()
+	service.mutex.Lock()
+	job.Status = scanRunning
+	service.mutex.Unlock()
+
+	resetLayerCaches()
+	report := scan(serverConfig(service.newConfig(job.Image)))
+	service.finish(job, report, exitCode([]*vulnerabilityReport{report}, false), "")
+}
+
+func (service *scanService) finish(job *scanJob, report *vulnerabilityReport, code int, message string) {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	finished := time.Now().UTC()
+	job.Finished = &finished
+	job.Report = report
+	job.ExitCode = code
+	job.Status = scanDone
+	if message != "" {
+		job.Status = scanFailed
+		job.Error = message
+	}
+	observeScan(report, code, message != "")
+	service.finished = append(service.finished, job.ID)
+	if len(service.finished) > scanHistorySize {
+		delete(service.jobs, service.finished[0])
+		service.finished = service.finished[1:]
+	}
+}
+
+// resetLayerCaches forgets the layers of the previous scan, the server would otherwise keep the layers of every scan it ran
+func resetLayerCaches() {
+	archivedLayers = make(map[string]archivedFile)
+	layerDigests = make(map[string]string)
+	remoteLayers = make(map[string]remoteLayer)
+}
+
+// serverConfig returns the scanner configuration of a scan of the server, the report is only returned by the REST API
+func serverConfig(config scannerConfig) scannerConfig {
+	for _, file := range []*string{&config.reportFile, &config.junitFile, &config.htmlFile, &config.sbomFile, &config.spdxFile, &config.vexFile, &config.generateWhitelist} {
+		*file = ""
+	}
+	config.format = "table"
+	config.quiet = true
+	config.updateBaseline = false
+	return config
+}
+
+// writeJSON writes the value as JSON response with the status code
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
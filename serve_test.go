@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestScanService(t *testing.T) {
+	initializeLogger("")
+	service := newScanService(func(imageName string) scannerConfig {
+		return scannerConfig{imageName: imageName, clairAPI: "v4", ociDir: "/nonexistent", reportFile: "report.json"}
+	}, "secret")
+	server := httptest.NewServer(service.router())
+	defer server.Close()
+
+	request := func(method string, path string, body string, token string) *http.Response {
+		r, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+token)
+		response, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatalf("Could not request %s: %v", path, err)
+		}
+		return response
+	}
+	if response := request("POST", "/scan", `{"image": "alpine:3.18"}`, "wrong"); response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a request without the token to be refused, got %d", response.StatusCode)
+	}
+	if response := request("POST", "/scan", `{}`, "secret"); response.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected a request without image to be refused, got %d", response.StatusCode)
+	}
+	response := request("POST", "/scan", `{"image": "alpine:3.18"}`, "secret")
+	var job scanJob
+	json.NewDecoder(response.Body).Decode(&job)
+	if response.StatusCode != http.StatusAccepted || job.Status != scanQueued || response.Header.Get("Location") != "/scans/"+job.ID {
+		t.Fatalf("Expected the scan to be queued, got %d %+v", response.StatusCode, job)
+	}
+
+	logger.recoverFatal = true
+	defer func() { logger.recoverFatal = false }()
+	layerDigests["/tmp/previous/layer.tar"] = "sha256:abc"
+	service.runJob(<-service.queue)
+	if len(layerDigests) != 0 {
+		t.Errorf("Expected the layers of the previous scan to be forgotten, got %v", layerDigests)
+	}
+	response = request("GET", "/scans/"+job.ID, "", "secret")
+	json.NewDecoder(response.Body).Decode(&job)
+	if job.Status != scanFailed || job.ExitCode != exitCodeError || job.Error == "" || job.Finished == nil {
+		t.Errorf("Expected the scan of the missing OCI layout to fail, got %+v", job)
+	}
+	if response := request("GET", "/scans/unknown", "", "secret"); response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected an unknown scan not to be found, got %d", response.StatusCode)
+	}
+
+	service.enqueue("other:1.0")
+	var scans []scanJob
+	json.NewDecoder(request("GET", "/scans?image=alpine:3.18", "", "secret").Body).Decode(&scans)
+	if len(scans) != 1 || scans[0].ID != job.ID {
+		t.Errorf("Expected the scans of the image, got %+v", scans)
+	}
+}
+
+func TestScanHistorySize(t *testing.T) {
+	initializeLogger("")
+	service := newScanService(nil, "")
+	var first string
+	for i := 0; i <= scanHistorySize; i++ {
+		job := &scanJob{ID: fmt.Sprintf("scan-%d", i), Status: scanRunning}
+		service.jobs[job.ID] = job
+		service.finish(job, nil, exitCodeError, "failed")
+		if i == 0 {
+			first = job.ID
+		}
+	}
+	if _, exists := service.jobs[first]; exists || len(service.jobs) != scanHistorySize {
+		t.Errorf("Expected the oldest of %d finished scans to be forgotten, got %d scans", scanHistorySize+1, len(service.jobs))
+	}
+}
+
+func TestServerConfig(t *testing.T) {
+	config := serverConfig(scannerConfig{reportFile: "report.json", format: "json", updateBaseline: true})
+	if config.reportFile != "" || config.format != "table" || !config.quiet || config.updateBaseline {
+		t.Errorf("Expected the server to write no reports, got %+v", config)
+	}
+}
@@ -1,23 +1,183 @@
 package main
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"math/big"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
 )
 
 const (
-	httpPort = "9279"
+	httpPort = 9279
 )
 
-// httpFileServer servers files from a specified folder
-// TODO if port can't be opened is not handled
-func httpFileServer(path string) *http.Server {
-	server := &http.Server{Addr: ":" + httpPort}
-	http.Handle("/", http.FileServer(http.Dir(path)))
+// httpFileServer serves the handler on the listen address, over HTTPS when a TLS configuration is given, port 0 picks a free port, and returns the port it listens on
+func httpFileServer(handler http.Handler, address string, tlsConfig *tls.Config) (*http.Server, string) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		logger.Fatalf("Could not serve the layers to Clair: %v", err)
+	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	server := &http.Server{Handler: handler}
 	go func() {
-		server.ListenAndServe()
+		server.Serve(listener)
 	}()
-	time.Sleep(100 * time.Millisecond) // It takes some time to open the port, just to be sure we wait a bit
-	logger.Infof("Server listening on port %s", httpPort)
-	return server
+
+	_, listenPort, _ := net.SplitHostPort(listener.Addr().String())
+	logger.Infof("Server listening on port %s", listenPort)
+	return server, listenPort
+}
+
+// layerHandler serves the layer files of the scanned layers from a specified folder below the token path, any other request gets a 404
+func layerHandler(path string, token string, layerIds []string) http.Handler {
+	layerFiles := make(map[string]string, len(layerIds))
+	servedLayers := make(map[string]string, len(layerIds))
+	for _, layerID := range layerIds {
+		layerFiles[layerURL("/"+token, layerID)] = path + "/" + layerID + "/" + layerFileName
+		servedLayers[layerURL("/"+token, layerID)] = layerID
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, exists := layerFiles[r.URL.Path]
+		if !exists || (r.Method != "GET" && r.Method != "HEAD") {
+			http.NotFound(w, r)
+			return
+		}
+		layer, err := openLayerFile(path)
+		if err != nil {
+			logger.Errorf("Could not serve layer %s: %v", path, err)
+			http.NotFound(w, r)
+			return
+		}
+		defer layer.Close()
+		span := tracer.startSpan("serve layer", spanKindServer, map[string]string{"layer": servedLayers[r.URL.Path], "http.method": r.Method})
+		defer span.finish(nil)
+		http.ServeContent(w, r, layerFileName, time.Time{}, layer)
+	})
+}
+
+// newServerToken generates the random token in the path of all layer URLs of a scan, requests without it get a 404
+func newServerToken() string {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		logger.Fatalf("Could not generate a layer server token: %v", err)
+	}
+	return hex.EncodeToString(token)
+}
+
+// listenAddress returns the address the layer server listens on, the listen address when given or else the port on all interfaces
+func listenAddress(listenAddr string, port int) string {
+	if listenAddr != "" {
+		return listenAddr
+	}
+	return ":" + strconv.Itoa(port)
+}
+
+// layerServerURL returns the URL Clair downloads the layers from, IPv6 addresses are put in brackets
+func layerServerURL(scannerIP string, port string, token string, overTLS bool) string {
+	scheme := "http"
+	if overTLS {
+		scheme = "https"
+	}
+	return scheme + "://" + net.JoinHostPort(scannerIP, port) + "/" + token
+}
+
+// Validate that the given listen address is a host and port
+func validateListenAddress(listenAddr string) {
+	if listenAddr == "" {
+		return
+	}
+	if _, port, err := net.SplitHostPort(listenAddr); err != nil || port == "" {
+		logger.Fatalf("Invalid listen address %s given, use 'host:port'", listenAddr)
+	}
+}
+
+// layerURL returns the URL Clair downloads the layer from, the blob URL of the registry for remote layers
+func layerURL(serverURL string, layerID string) string {
+	if remote, exists := remoteLayers[layerID]; exists {
+		return remote.registry.baseURL() + "/blobs/" + remote.digest
+	}
+	return serverURL + "/" + layerID + "/" + layerFileName
+}
+
+// layerHeaders returns the headers Clair downloads the layer with, the authorization of the registry for remote layers
+func layerHeaders(layerID string) map[string]string {
+	remote, exists := remoteLayers[layerID]
+	if !exists {
+		return nil
+	}
+	if authorization := remote.registry.freshAuthorization(); authorization != "" {
+		return map[string]string{"Authorization": authorization}
+	}
+	return nil
+}
+
+// remoteOnly tells whether Clair downloads all layers from their registry, so no layer server is needed
+func remoteOnly(layerIds []string) bool {
+	for _, layerID := range layerIds {
+		if _, exists := remoteLayers[layerID]; !exists {
+			return false
+		}
+	}
+	return true
+}
+
+// newServerTLSConfig loads the certificate of the layer server, or generates a self-signed certificate for the host when none is given
+func newServerTLSConfig(certFile string, keyFile string, host string) *tls.Config {
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			logger.Fatalf("A layer server certificate requires both --layer-cert and --layer-key")
+		}
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			logger.Fatalf("Could not load layer server certificate: %v", err)
+		}
+		return &tls.Config{Certificates: []tls.Certificate{certificate}}
+	}
+
+	certificate, err := selfSignedCertificate(host, time.Now())
+	if err != nil {
+		logger.Fatalf("Could not generate a self-signed layer server certificate: %v", err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{certificate}}
+}
+
+// selfSignedCertificate generates a certificate for the host, valid for a day
+func selfSignedCertificate(host string, now time.Time) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: host},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else if host != "" {
+		template.DNSNames = []string{host}
+	}
+
+	certificate, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{certificate}, PrivateKey: key}, nil
 }
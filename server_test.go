@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestHTTPFileServerPicksFreePort(t *testing.T) {
+	initializeLogger("")
+	tmpPath := createLayerFiles(t, map[string]string{"layer": "content"})
+	defer os.RemoveAll(tmpPath)
+
+	first, firstPort := httpFileServer(layerHandler(tmpPath, "token", []string{"layer"}), listenAddress("", 0), nil)
+	defer first.Close()
+	second, secondPort := httpFileServer(layerHandler(tmpPath, "token", []string{"layer"}), listenAddress("127.0.0.1:0", httpPort), nil)
+	defer second.Close()
+	if firstPort == secondPort {
+		t.Errorf("Expected concurrent servers on different ports, got %s twice", firstPort)
+	}
+
+	response, err := http.Get(layerURL("http://localhost:"+secondPort+"/token", "layer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if body, _ := ioutil.ReadAll(response.Body); string(body) != "content" {
+		t.Errorf("Expected the layer to be served, got %s", body)
+	}
+}
+
+func TestHTTPFileServerOverTLS(t *testing.T) {
+	initializeLogger("")
+	tmpPath := createLayerFiles(t, map[string]string{"layer": "content"})
+	defer os.RemoveAll(tmpPath)
+
+	server, port := httpFileServer(layerHandler(tmpPath, "token", []string{"layer"}), listenAddress("127.0.0.1:0", httpPort), newServerTLSConfig("", "", "127.0.0.1"))
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	response, err := client.Get(layerURL("https://127.0.0.1:"+port+"/token", "layer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if certificates := response.TLS.PeerCertificates; len(certificates) != 1 || !certificates[0].IPAddresses[0].Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("Expected a self-signed certificate for 127.0.0.1, got %v", certificates)
+	}
+}
+
+func TestLayerServerURL(t *testing.T) {
+	if url := layerServerURL("172.17.0.1", "9279", "token", false); url != "http://172.17.0.1:9279/token" {
+		t.Errorf("Expected the URL of the IPv4 address, got %s", url)
+	}
+	if url := layerServerURL("fd00::5", "9279", "token", true); url != "https://[fd00::5]:9279/token" {
+		t.Errorf("Expected the IPv6 address in brackets, got %s", url)
+	}
+}
+
+func TestLayerHandlerServesOnlyLayers(t *testing.T) {
+	initializeLogger("")
+	tmpPath := createLayerFiles(t, map[string]string{"layer": "content"})
+	defer os.RemoveAll(tmpPath)
+
+	token := newServerToken()
+	server, port := httpFileServer(layerHandler(tmpPath, token, []string{"layer"}), listenAddress("127.0.0.1:0", httpPort), nil)
+	defer server.Close()
+
+	for url, expected := range map[string]int{
+		layerURL("http://127.0.0.1:"+port+"/"+token, "layer"): http.StatusOK,
+		layerURL("http://127.0.0.1:"+port, "layer"):           http.StatusNotFound,
+		layerURL("http://127.0.0.1:"+port+"/wrong", "layer"):  http.StatusNotFound,
+		layerURL("http://127.0.0.1:"+port+"/"+token, "other"): http.StatusNotFound,
+		"http://127.0.0.1:" + port + "/" + token + "/":        http.StatusNotFound,
+		"http://127.0.0.1:" + port + "/" + token + "/layer/":  http.StatusNotFound,
+	} {
+		response, err := http.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != expected {
+			t.Errorf("Expected response %d for %s, got %d", expected, url, response.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/pborman/uuid"
+)
+
+const (
+	spdxVersion     = "SPDX-2.3"
+	spdxNoAssertion = "NOASSERTION"
+)
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name                  string            `json:"name"`
+	SPDXID                string            `json:"SPDXID"`
+	VersionInfo           string            `json:"versionInfo,omitempty"`
+	DownloadLocation      string            `json:"downloadLocation"`
+	FilesAnalyzed         bool              `json:"filesAnalyzed"`
+	SourceInfo            string            `json:"sourceInfo,omitempty"`
+	PrimaryPackagePurpose string            `json:"primaryPackagePurpose,omitempty"`
+	ExternalRefs          []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// writeSPDXReport writes the features of the image as an SPDX 2.3 document
+func writeSPDXReport(w io.Writer, report *vulnerabilityReport) error {
+	const imageID = "SPDXRef-Image"
+
+	document := spdxDocument{
+		SPDXVersion:       spdxVersion,
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              report.Image,
+		DocumentNamespace: toolURI + "/spdx/" + url.PathEscape(report.Image) + "-" + uuid.New(),
+		CreationInfo: spdxCreationInfo{
+			Created:  time.Now().UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: " + toolName + "-" + version},
+		},
+		Packages: []spdxPackage{{
+			Name:                  report.Image,
+			SPDXID:                imageID,
+			DownloadLocation:      spdxNoAssertion,
+			PrimaryPackagePurpose: "CONTAINER",
+		}},
+		Relationships: []spdxRelationship{{"SPDXRef-DOCUMENT", "DESCRIBES", imageID}},
+	}
+
+	namespaceIDs := make(map[string]string)
+	for i, feature := range report.Features {
+		if _, exists := namespaceIDs[feature.Namespace]; !exists && feature.Namespace != "" {
+			id := fmt.Sprintf("SPDXRef-OperatingSystem-%d", len(namespaceIDs)+1)
+			namespaceIDs[feature.Namespace] = id
+			document.Packages = append(document.Packages, spdxPackage{
+				Name:                  feature.Namespace,
+				SPDXID:                id,
+				DownloadLocation:      spdxNoAssertion,
+				PrimaryPackagePurpose: "OPERATING-SYSTEM",
+			})
+			document.Relationships = append(document.Relationships, spdxRelationship{imageID, "CONTAINS", id})
+		}
+
+		id := fmt.Sprintf("SPDXRef-Package-%d", i+1)
+		spdx := spdxPackage{
+			Name:                  feature.Name,
+			SPDXID:                id,
+			VersionInfo:           feature.Version,
+			DownloadLocation:      spdxNoAssertion,
+			PrimaryPackagePurpose: "LIBRARY",
+			ExternalRefs: []spdxExternalRef{{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  packageURL(feature.Name, feature.Version, feature.Namespace),
+			}},
+		}
+		if feature.Namespace != "" {
+			spdx.SourceInfo = "package installed in namespace " + feature.Namespace
+		}
+		document.Packages = append(document.Packages, spdx)
+		document.Relationships = append(document.Relationships, spdxRelationship{imageID, "CONTAINS", id})
+		if namespaceID, exists := namespaceIDs[feature.Namespace]; exists {
+			document.Relationships = append(document.Relationships, spdxRelationship{namespaceID, "CONTAINS", id})
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(document)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"testing"
+)
+
+func TestWriteSPDXReport(t *testing.T) {
+	report := &vulnerabilityReport{
+		Image: "alpine:3.5",
+		Features: []featureInfo{
+			{Name: "zlib", Version: "1.2.8-r2", Namespace: "alpine:v3.5"},
+			{Name: "musl", Version: "1.1.15-r5", Namespace: "alpine:v3.5"},
+		},
+	}
+
+	var buffer bytes.Buffer
+	if err := writeSPDXReport(&buffer, report); err != nil {
+		t.Fatalf("Could not write SPDX report: %v", err)
+	}
+	var document spdxDocument
+	if err := json.Unmarshal(buffer.Bytes(), &document); err != nil {
+		t.Fatalf("SPDX report is not JSON: %v", err)
+	}
+	if document.SPDXVersion != spdxVersion || document.DataLicense != "CC0-1.0" || document.SPDXID != "SPDXRef-DOCUMENT" || len(document.CreationInfo.Creators) != 1 {
+		t.Errorf("Expected the required SPDX 2.3 document fields, got %+v", document)
+	}
+	if namespace, err := url.Parse(document.DocumentNamespace); err != nil || namespace.Scheme != "https" {
+		t.Errorf("Expected an absolute URI as document namespace, got %s", document.DocumentNamespace)
+	}
+
+	// the image, the operating system and the 2 packages
+	if len(document.Packages) != 4 {
+		t.Fatalf("Expected 4 packages, got %+v", document.Packages)
+	}
+	ids := map[string]bool{"SPDXRef-DOCUMENT": true}
+	for _, spdx := range document.Packages {
+		if !regexp.MustCompile(`^SPDXRef-[A-Za-z0-9.-]+$`).MatchString(spdx.SPDXID) || ids[spdx.SPDXID] {
+			t.Errorf("Expected a valid and unique SPDX identifier, got %s", spdx.SPDXID)
+		}
+		ids[spdx.SPDXID] = true
+		if spdx.DownloadLocation == "" {
+			t.Errorf("Expected the required download location of %s", spdx.Name)
+		}
+	}
+	for _, relationship := range document.Relationships {
+		if !ids[relationship.SPDXElementID] || !ids[relationship.RelatedSPDXElement] {
+			t.Errorf("Expected the relationship between elements of the document, got %+v", relationship)
+		}
+	}
+	if zlib := document.Packages[2]; zlib.Name != "zlib" || zlib.ExternalRefs[0].ReferenceLocator != "pkg:apk/alpine/zlib@1.2.8-r2?distro=alpine-v3.5" {
+		t.Errorf("Expected zlib with its package URL, got %+v", zlib)
+	}
+}
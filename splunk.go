@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pborman/uuid"
+)
+
+const (
+	splunkEventURI  = "/services/collector/event"
+	splunkBatchSize = 500
+)
+
+// splunkConfig is the Splunk HTTP Event Collector every scan is sent to
+type splunkConfig struct {
+	url   string
+	token string
+	index string
+}
+
+// splunkEvent is the envelope of an event of the HTTP Event Collector
+type splunkEvent struct {
+	Time       float64     `json:"time"`
+	Source     string      `json:"source"`
+	SourceType string      `json:"sourcetype"`
+	Index      string      `json:"index,omitempty"`
+	Event      interface{} `json:"event"`
+}
+
+// splunkScan is the event of a scan, with the vulnerabilities of the image by severity
+type splunkScan struct {
+	ScanID string `json:"scanid"`
+	historyEntry
+}
+
+type splunkResponse struct {
+	Text string `json:"text"`
+	Code int    `json:"code"`
+}
+
+// splunkEvents returns the event of the scan followed by an event for every vulnerability of the report
+func splunkEvents(report *vulnerabilityReport, index string, scanned time.Time) []splunkEvent {
+	scanID := uuid.New()
+	timestamp := float64(scanned.UnixNano()/int64(time.Millisecond)) / 1000
+	events := []splunkEvent{{Time: timestamp, Source: "clair-scanner", SourceType: "clair-scanner:scan", Index: index, Event: splunkScan{scanID, newHistoryEntry(report, scanned)}}}
+	for _, finding := range reportFindings(report, scanID, scanned) {
+		events = append(events, splunkEvent{Time: timestamp, Source: "clair-scanner", SourceType: "clair-scanner:vulnerability", Index: index, Event: finding})
+	}
+	return events
+}
+
+// exportToSplunk sends the scan and its vulnerabilities as events to the HTTP Event Collector, failures are logged as warnings
+func exportToSplunk(report *vulnerabilityReport, splunk splunkConfig) {
+	if splunk.url == "" {
+		return
+	}
+	events := splunkEvents(report, splunk.index, time.Now())
+	for start := 0; start < len(events); start += splunkBatchSize {
+		end := start + splunkBatchSize
+		if end > len(events) {
+			end = len(events)
+		}
+		if err := sendSplunkEvents(splunk, events[start:end]); err != nil {
+			logger.Warnf("Could not send the scan of image [%s] to Splunk: %v", report.Image, err)
+			return
+		}
+	}
+	logger.Infof("Sent the scan of image [%s] with %d vulnerabilities to Splunk", report.Image, len(events)-1)
+}
+
+// sendSplunkEvents sends the events with a single request to the HTTP Event Collector, which takes the events one after the other
+func sendSplunkEvents(splunk splunkConfig, events []splunkEvent) error {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+
+	url := strings.TrimSuffix(splunk.url, "/")
+	if !strings.Contains(url, "/services/collector") {
+		url += splunkEventURI
+	}
+	content, err := upload("POST", url, body.Bytes(), map[string]string{"Authorization": "Splunk " + splunk.token, "Content-Type": "application/json"})
+	if err != nil {
+		return err
+	}
+	var response splunkResponse
+	if err = json.Unmarshal(content, &response); err == nil && response.Code != 0 {
+		return fmt.Errorf("the HTTP Event Collector responded with code %d: %s", response.Code, response.Text)
+	}
+	return nil
+}
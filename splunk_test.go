@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSplunkEvents(t *testing.T) {
+	report := &vulnerabilityReport{Image: "alpine:3.5", Unapproved: []string{"CVE-2017-1"}, Vulnerabilities: []vulnerabilityInfo{
+		{Vulnerability: "CVE-2017-1", FeatureName: "openssl", Severity: "High"},
+	}}
+	events := splunkEvents(report, "security", time.Unix(1506247001, 500000000))
+	if len(events) != 2 || events[0].SourceType != "clair-scanner:scan" || events[1].SourceType != "clair-scanner:vulnerability" {
+		t.Fatalf("Expected a scan and a vulnerability event, got %v", events)
+	}
+	if events[0].Time != 1506247001.5 || events[0].Index != "security" {
+		t.Errorf("Expected the time and index of the scan, got %v %s", events[0].Time, events[0].Index)
+	}
+	scan := events[0].Event.(splunkScan)
+	vulnerability := events[1].Event.(finding)
+	if scan.Severities["High"] != 1 || scan.Unapproved != 1 || scan.ScanID != vulnerability.ScanID {
+		t.Errorf("Expected the scan to count 1 unapproved High vulnerability and share its scan ID, got %v", scan)
+	}
+}
+
+func TestExportToSplunk(t *testing.T) {
+	initializeLogger("")
+	var events []splunkEvent
+	var authorization string
+	splunk := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != splunkEventURI {
+			t.Errorf("Expected a request to the event endpoint, got %s", r.URL.Path)
+		}
+		authorization = r.Header.Get("Authorization")
+		decoder := json.NewDecoder(r.Body)
+		for decoder.More() {
+			var event splunkEvent
+			decoder.Decode(&event)
+			events = append(events, event)
+		}
+		w.Write([]byte(`{"text": "Success", "code": 0}`))
+	}))
+	defer splunk.Close()
+
+	exportToSplunk(&vulnerabilityReport{Image: "alpine:3.5", Vulnerabilities: []vulnerabilityInfo{{Vulnerability: "CVE-2017-1"}}}, splunkConfig{url: splunk.URL, token: "secret"})
+	if authorization != "Splunk secret" {
+		t.Errorf("Expected the token to be sent, got %s", authorization)
+	}
+	if len(events) != 2 || events[0].Source != "clair-scanner" {
+		t.Errorf("Expected 2 events from clair-scanner, got %v", events)
+	}
+}
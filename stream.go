@@ -0,0 +1,55 @@
+package main
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+)
+
+// knownLayerDigests returns the digests of the layers of the image Clair already analyzed, they don't need to be saved
+func knownLayerDigests(config scannerConfig, imageName string) map[string]bool {
+	digests := getImageDiffIDs(imageName)
+	known := make(map[string]bool)
+	switch config.clairAPI {
+	case "v4":
+		if manifestIndexed(config.clairURL, clairV4Manifest{Hash: manifestHash(digests)}) {
+			for _, digest := range digests {
+				known[digest] = true
+			}
+		}
+	case "v3":
+		logger.Warnf("Clair 3 can't tell which layers it analyzed, all layers are saved")
+	default:
+		for i, layerName := range chainIDs(digests) {
+			if layerExists(config.clairURL, layerName) {
+				known[digests[i]] = true
+			}
+		}
+	}
+	logger.Infof("Clair already analyzed %d of %d layers of [%s], they are not saved", len(known), len(digests), imageName)
+	return known
+}
+
+// untarStreaming untars the image on the fly to a target folder, layer files are hashed while they are written and removed again when Clair already analyzed them
+func untarStreaming(imageReader io.Reader, target string, knownLayers map[string]bool) error {
+	return extractTar(imageReader, target, func(path string, _ *tar.Header, layer io.Reader) error {
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		hash := sha256.New()
+		_, err = io.Copy(io.MultiWriter(file, hash), layer)
+		file.Close()
+		if err != nil {
+			return err
+		}
+
+		layerDigests[path] = "sha256:" + hex.EncodeToString(hash.Sum(nil))
+		if knownLayers[layerDigests[path]] {
+			return os.Remove(path)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// summaryReport rolls up the reports of several scanned images
+type summaryReport struct {
+	Images                []imageSummary        `json:"images"`
+	SharedVulnerabilities []sharedVulnerability `json:"sharedvulnerabilities"`
+}
+
+type imageSummary struct {
+	Image      string         `json:"image"`
+	Platform   string         `json:"platform,omitempty"`
+	Digest     string         `json:"digest,omitempty"`
+	NoFeatures bool           `json:"nofeatures,omitempty"`
+	Severities map[string]int `json:"severities"`
+	Total      int            `json:"total"`
+	Unapproved int            `json:"unapproved"`
+}
+
+// sharedVulnerability is a vulnerability found in more than one image
+type sharedVulnerability struct {
+	Vulnerability string   `json:"vulnerability"`
+	Severity      string   `json:"severity"`
+	Images        []string `json:"images"`
+}
+
+// summarizeReports counts the vulnerabilities of every image by severity and finds the vulnerabilities the images share,
+// the reports are those of the configs, nil for images without features
+func summarizeReports(configs []scannerConfig, reports []*vulnerabilityReport) summaryReport {
+	summary := summaryReport{Images: []imageSummary{}, SharedVulnerabilities: []sharedVulnerability{}}
+	shared := make(map[string]*sharedVulnerability)
+	var order []string
+	for i, report := range reports {
+		image := imageSummary{Image: configs[i].imageName, Platform: configs[i].platform, Severities: map[string]int{}, NoFeatures: report == nil}
+		if report != nil {
+			image.Digest = report.Digest
+			image.Total = len(report.Vulnerabilities)
+			image.Unapproved = len(report.Unapproved)
+			name := summaryImageName(image)
+			for _, vulnerability := range report.Vulnerabilities {
+				image.Severities[vulnerability.Severity]++
+				if _, exists := shared[vulnerability.Vulnerability]; !exists {
+					shared[vulnerability.Vulnerability] = &sharedVulnerability{Vulnerability: vulnerability.Vulnerability, Severity: vulnerability.Severity}
+					order = append(order, vulnerability.Vulnerability)
+				}
+				if images := shared[vulnerability.Vulnerability].Images; len(images) == 0 || images[len(images)-1] != name {
+					shared[vulnerability.Vulnerability].Images = append(images, name)
+				}
+			}
+		}
+		summary.Images = append(summary.Images, image)
+	}
+	for _, cve := range order {
+		if len(shared[cve].Images) > 1 {
+			summary.SharedVulnerabilities = append(summary.SharedVulnerabilities, *shared[cve])
+		}
+	}
+	sort.SliceStable(summary.SharedVulnerabilities, func(i, j int) bool {
+		a, b := summary.SharedVulnerabilities[i], summary.SharedVulnerabilities[j]
+		if SeverityMap[a.Severity] != SeverityMap[b.Severity] {
+			return SeverityMap[a.Severity] < SeverityMap[b.Severity]
+		}
+		return len(a.Images) > len(b.Images)
+	})
+	return summary
+}
+
+// summaryImageName names the image with its platform when one of several platforms is scanned
+func summaryImageName(image imageSummary) string {
+	if image.Platform != "" {
+		return image.Image + " (" + image.Platform + ")"
+	}
+	return image.Image
+}
+
+// summarySeverities returns the severities from the most to the least severe
+func summarySeverities() []string {
+	severities := make([]string, 0, len(SeverityMap))
+	for severity := range SeverityMap {
+		severities = append(severities, severity)
+	}
+	sort.Slice(severities, func(i, j int) bool {
+		return SeverityMap[severities[i]] < SeverityMap[severities[j]]
+	})
+	return severities
+}
+
+// summaryToConsole prints the vulnerabilities of every image by severity and the vulnerabilities the images share
+func summaryToConsole(summary summaryReport) {
+	severities := summarySeverities()
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader(append(append([]string{"Image"}, severities...), "Total", "Unapproved"))
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	for _, image := range summary.Images {
+		row := []string{summaryImageName(image)}
+		for _, severity := range severities {
+			row = append(row, strconv.Itoa(image.Severities[severity]))
+		}
+		if image.NoFeatures {
+			row = append(row, "no features", "")
+		} else {
+			row = append(row, strconv.Itoa(image.Total), strconv.Itoa(image.Unapproved))
+		}
+		table.Append(row)
+	}
+	table.Render()
+
+	if len(summary.SharedVulnerabilities) == 0 {
+		return
+	}
+	logger.Infof("%d vulnerabilities are found in more than one image", len(summary.SharedVulnerabilities))
+	shared := tablewriter.NewWriter(os.Stdout)
+	shared.SetHeader([]string{"CVE", "Severity", "Images"})
+	shared.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	shared.SetAlignment(tablewriter.ALIGN_LEFT)
+	shared.SetRowLine(true)
+	for _, vulnerability := range summary.SharedVulnerabilities {
+		shared.Append([]string{vulnerability.Vulnerability, vulnerability.Severity, strings.Join(vulnerability.Images, "\n")})
+	}
+	shared.Render()
+}
+
+// summaryToFile writes the summary to file as JSON
+func summaryToFile(summary summaryReport, file string) {
+	if file == "" {
+		return
+	}
+	content, err := json.MarshalIndent(summary, "", "    ")
+	if err != nil {
+		logger.Fatalf("Could not create a summary report: %v", err)
+	}
+	if err = ioutil.WriteFile(file, append(content, '\n'), 0644); err != nil {
+		logger.Fatalf("Could not create a summary report: could not write to file %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSummarizeReports(t *testing.T) {
+	configs := []scannerConfig{{imageName: "api:1.0"}, {imageName: "worker:1.0"}, {imageName: "empty:1.0"}}
+	reports := []*vulnerabilityReport{
+		{Vulnerabilities: []vulnerabilityInfo{
+			{Vulnerability: "CVE-2021-1", Severity: "Low", FeatureName: "zlib"},
+			{Vulnerability: "CVE-2021-1", Severity: "Low", FeatureName: "zlib-dev"},
+			{Vulnerability: "CVE-2021-2", Severity: "High"},
+		}, Unapproved: []string{"CVE-2021-2"}},
+		{Vulnerabilities: []vulnerabilityInfo{
+			{Vulnerability: "CVE-2021-1", Severity: "Low"},
+			{Vulnerability: "CVE-2021-3", Severity: "Critical"},
+		}},
+		nil,
+	}
+	summary := summarizeReports(configs, reports)
+	if api := summary.Images[0]; api.Total != 3 || api.Unapproved != 1 || api.Severities["Low"] != 2 || api.Severities["High"] != 1 {
+		t.Errorf("Expected the vulnerabilities of api by severity, got %+v", api)
+	}
+	if !summary.Images[2].NoFeatures {
+		t.Errorf("Expected the image without features to be marked, got %+v", summary.Images[2])
+	}
+	expected := []sharedVulnerability{{Vulnerability: "CVE-2021-1", Severity: "Low", Images: []string{"api:1.0", "worker:1.0"}}}
+	if !reflect.DeepEqual(summary.SharedVulnerabilities, expected) {
+		t.Errorf("Expected CVE-2021-1 to be shared by api and worker, got %+v", summary.SharedVulnerabilities)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// saveImageArchive saves the image of an archive written with 'docker save' to the temporary folder and returns its digests,
+// the layers of an uncompressed archive are not copied, they are read from the archive itself
+func saveImageArchive(archivePath string, imageName string, platform string, tmpPath string) []string {
+	archivePath, _ = filepath.Abs(archivePath)
+	file, err := os.Open(archivePath)
+	if err != nil {
+		logger.Fatalf("Could not read image archive: %v", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	if magic, _ := reader.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		var gzipReader *gzip.Reader
+		if gzipReader, err = gzip.NewReader(reader); err == nil {
+			err = untar(gzipReader, tmpPath)
+		}
+	} else {
+		counter := &countingReader{reader: reader}
+		err = extractTar(counter, tmpPath, func(path string, header *tar.Header, _ io.Reader) error {
+			archivedLayers[path] = archivedFile{archive: archivePath, offset: counter.offset, size: header.Size}
+			return nil
+		})
+	}
+	if err != nil {
+		logger.Fatalf("Could not read image archive: could not untar %s: %v", archivePath, err)
+	}
+
+	// Archives of the containerd image store, and of buildx, can be an OCI layout without manifest.json
+	if _, err = os.Stat(filepath.Join(tmpPath, "manifest.json")); os.IsNotExist(err) {
+		return saveOCIImage(tmpPath, imageName, platform, tmpPath)
+	}
+	selectArchivedImage(tmpPath, imageName)
+	normalizeImageLayers(tmpPath)
+	return []string{imageID(tmpPath)}
+}
+
+// isLayerFile tells whether a file of a saved image is a layer: the <id>/layer.tar of 'docker save',
+// a <digest>.tar, <digest>.tar.gz or <digest>.tgz as written by Kaniko, Jib and Bazel, or a blob of an OCI layout that may be a layer
+func isLayerFile(path string) bool {
+	if strings.HasPrefix(filepath.ToSlash(path), "blobs/") {
+		return true
+	}
+	for _, suffix := range []string{".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeImageLayers links every layer listed in the manifest.json to <id>/layer.tar, as 'docker save' writes them,
+// so images of other builders are read like images saved by Docker
+func normalizeImageLayers(tmpPath string) {
+	manifest := readManifestFile(tmpPath)
+	for i, layer := range manifest[0].Layers {
+		layerID := layerIDFromPath(layer)
+		if layer == layerID+"/"+layerFileName {
+			continue
+		}
+		path := filepath.Join(tmpPath, layerID, layerFileName)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			logger.Fatalf("Could not read image layers: %v", err)
+		}
+		if err := linkLayerFile(path, filepath.Join(tmpPath, layer)); err != nil {
+			logger.Fatalf("Could not read image layers: %v", err)
+		}
+		manifest[0].Layers[i] = layerID + "/" + layerFileName
+	}
+	content, _ := json.Marshal(manifest)
+	if err := ioutil.WriteFile(filepath.Join(tmpPath, "manifest.json"), content, 0644); err != nil {
+		logger.Fatalf("Could not read image layers: %v", err)
+	}
+}
+
+// layerIDFromPath derives the layer ID from the path of a layer in the manifest.json, e.g. 'sha256:abc.tar.gz' and 'blobs/sha256/abc' are layer 'abc'
+func layerIDFromPath(layer string) string {
+	if strings.HasSuffix(layer, "/"+layerFileName) {
+		return strings.TrimSuffix(layer, "/"+layerFileName)
+	}
+	layerID := filepath.Base(layer)
+	for _, suffix := range []string{".tar.gz", ".tgz", ".tar"} {
+		layerID = strings.TrimSuffix(layerID, suffix)
+	}
+	return strings.TrimPrefix(layerID, "sha256:")
+}
+
+// imageID returns the ID of the saved image, the digest of its configuration file
+func imageID(tmpPath string) string {
+	manifest := readManifestFile(tmpPath)
+	config, err := readImageFile(filepath.Join(tmpPath, manifest[0].Config))
+	if err != nil {
+		logger.Fatalf("Could not read image configuration: %v", err)
+	}
+	hash := sha256.Sum256(config)
+	return "sha256:" + hex.EncodeToString(hash[:])
+}
+
+// selectArchivedImage selects the image by its tag or image ID from the manifest.json of an archive with several images, only its manifest is kept,
+// without image name the archive must contain a single image
+func selectArchivedImage(tmpPath string, imageName string) manifestJSON {
+	content, err := ioutil.ReadFile(filepath.Join(tmpPath, "manifest.json"))
+	if err != nil {
+		logger.Fatalf("Could not read image archive: %v", err)
+	}
+	var manifests []manifestJSON
+	if err = json.Unmarshal(content, &manifests); err != nil {
+		logger.Fatalf("Could not read image archive: manifest.json is not json: %v", err)
+	}
+
+	selected := -1
+	for i, manifest := range manifests {
+		if isImageID(imageName) && strings.HasPrefix(layerIDFromPath(manifest.Config), strings.TrimPrefix(imageName, "sha256:")) {
+			selected = i
+		}
+		for _, tag := range manifest.RepoTags {
+			if tag == imageName || tag == imageName+":latest" {
+				selected = i
+			}
+		}
+	}
+	if selected < 0 && len(manifests) == 1 && (imageName == "" || len(manifests[0].RepoTags) == 0) {
+		selected = 0
+	}
+	if selected < 0 && imageName == "" {
+		logger.Fatalf("Could not read image archive: the archive contains %d images, give the name of the image to scan", len(manifests))
+	} else if selected < 0 {
+		logger.Fatalf("Could not read image archive: no image [%s] in the archive", imageName)
+	}
+
+	content, _ = json.Marshal(manifests[selected : selected+1])
+	if err = ioutil.WriteFile(filepath.Join(tmpPath, "manifest.json"), content, 0644); err != nil {
+		logger.Fatalf("Could not read image archive: %v", err)
+	}
+	return manifests[selected]
+}
+
+// archivedImageName returns the tag of the single image in an archive written with 'docker save', or the archive path when it has none
+func archivedImageName(archivePath string) string {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		logger.Fatalf("Could not read image archive: %v", err)
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if gzipReader, err := gzip.NewReader(file); err == nil {
+		reader = gzipReader
+	} else if _, err = file.Seek(0, io.SeekStart); err != nil {
+		logger.Fatalf("Could not read image archive: %v", err)
+	}
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err != nil {
+			logger.Fatalf("Could not read image archive: no manifest.json found in %s: %v", archivePath, err)
+		}
+		if header.Name != "manifest.json" {
+			continue
+		}
+		var manifests []manifestJSON
+		if err = json.NewDecoder(tarReader).Decode(&manifests); err != nil {
+			logger.Fatalf("Could not read image archive: manifest.json is not json: %v", err)
+		}
+		if len(manifests) != 1 {
+			logger.Fatalf("Could not read image archive: the archive contains %d images, give the name of the image to scan", len(manifests))
+		}
+		if len(manifests[0].RepoTags) == 0 {
+			return archivePath
+		}
+		return manifests[0].RepoTags[0]
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestSaveImageArchive(t *testing.T) {
+	initializeLogger("")
+	for _, compressed := range []bool{false, true} {
+		archive, err := ioutil.TempFile("", "image-*.tar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(archive.Name())
+		archive.Write(imageArchive(t, compressed))
+		archive.Close()
+
+		if name := archivedImageName(archive.Name()); name != "myapp:1.0" {
+			t.Errorf("Expected the tag of the image as name, got %s", name)
+		}
+		tmpPath := createTmpPath("clair-tar")
+		defer os.RemoveAll(tmpPath)
+		digests := saveImageArchive(archive.Name(), "myapp:1.0", "", tmpPath)
+		config := sha256.Sum256([]byte(`{}`))
+		if !reflect.DeepEqual(digests, []string{"sha256:" + hex.EncodeToString(config[:])}) {
+			t.Errorf("Expected the digest of the config file as image ID, got %v", digests)
+		}
+		layer, err := openLayerFile(tmpPath + "/a/layer.tar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := ioutil.ReadAll(layer)
+		layer.Close()
+		if string(content) != "layer content" {
+			t.Errorf("Expected the layer to be read from the archive, got %q", content)
+		}
+	}
+}
+
+func TestNormalizeImageLayers(t *testing.T) {
+	initializeLogger("")
+	// Kaniko and Jib write compressed layers named by their digest next to the manifest.json
+	tmpPath := createLayerFiles(t, map[string]string{})
+	defer os.RemoveAll(tmpPath)
+	for name, content := range map[string]string{
+		"sha256:abc.tar.gz": "compressed layer",
+		"def.tar":           "layer",
+		"manifest.json":     `[{"Config": "config.json", "Layers": ["sha256:abc.tar.gz", "def.tar", "ghi/layer.tar"]}]`,
+	} {
+		if err := ioutil.WriteFile(tmpPath+"/"+name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	normalizeImageLayers(tmpPath)
+	if layerIds := getImageLayerIds(tmpPath); !reflect.DeepEqual(layerIds, []string{"abc", "def", "ghi"}) {
+		t.Errorf("Expected the layer IDs abc, def and ghi, got %v", layerIds)
+	}
+	if content, _ := ioutil.ReadFile(tmpPath + "/abc/layer.tar"); string(content) != "compressed layer" {
+		t.Errorf("Expected abc/layer.tar to link to the layer of Kaniko, got %q", content)
+	}
+}
+
+// imageArchive returns an archive written with 'docker save' of the image myapp:1.0 with the layer a
+func imageArchive(t *testing.T, compressed bool) []byte {
+	var archive bytes.Buffer
+	writer := tar.NewWriter(&archive)
+	for _, file := range []struct {
+		header  tar.Header
+		content string
+	}{
+		{tar.Header{Name: "a/", Typeflag: tar.TypeDir, Mode: 0755}, ""},
+		{tar.Header{Name: "a/layer.tar", Typeflag: tar.TypeReg, Mode: 0644}, "layer content"},
+		{tar.Header{Name: "abc.json", Typeflag: tar.TypeReg, Mode: 0644}, `{}`},
+		{tar.Header{Name: "manifest.json", Typeflag: tar.TypeReg, Mode: 0644}, `[{"Config": "abc.json", "RepoTags": ["myapp:1.0"], "Layers": ["a/layer.tar"]}]`},
+	} {
+		file.header.Size = int64(len(file.content))
+		if err := writer.WriteHeader(&file.header); err != nil {
+			t.Fatal(err)
+		}
+		writer.Write([]byte(file.content))
+	}
+	writer.Close()
+	if !compressed {
+		return archive.Bytes()
+	}
+	var gzipped bytes.Buffer
+	gzipWriter := gzip.NewWriter(&gzipped)
+	gzipWriter.Write(archive.Bytes())
+	gzipWriter.Close()
+	return gzipped.Bytes()
+}
+
+func TestSaveImageArchiveOCIFormat(t *testing.T) {
+	initializeLogger("")
+	layer := sha256.Sum256([]byte("layer content"))
+	layerDigest := hex.EncodeToString(layer[:])
+	config := sha256.Sum256([]byte(`{}`))
+	configDigest := hex.EncodeToString(config[:])
+	manifest := `{"config": {"digest": "sha256:` + configDigest + `"}, "layers": [{"digest": "sha256:` + layerDigest + `", "size": 13}]}`
+	manifestHash := sha256.Sum256([]byte(manifest))
+	manifestDigest := hex.EncodeToString(manifestHash[:])
+	files := [][2]string{
+		{"blobs/sha256/" + layerDigest, "layer content"},
+		{"blobs/sha256/" + configDigest, `{}`},
+		{"blobs/sha256/" + manifestDigest, manifest},
+		{"index.json", `{"manifests": [{"digest": "sha256:` + manifestDigest + `"}]}`},
+	}
+	// Docker with the containerd image store writes a manifest.json next to the OCI layout, buildx does not
+	withManifest := append(files, [2]string{"manifest.json", `[{"Config": "blobs/sha256/` + configDigest + `", "Layers": ["blobs/sha256/` + layerDigest + `"]}]`})
+	for _, files := range [][][2]string{withManifest, files} {
+		archive, err := ioutil.TempFile("", "image-*.tar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(archive.Name())
+		archive.Write(tarArchive(t, files))
+		archive.Close()
+
+		tmpPath := createTmpPath("clair-tar")
+		defer os.RemoveAll(tmpPath)
+		digests := saveImageArchive(archive.Name(), "", "", tmpPath)
+		if digests[0] != "sha256:"+configDigest {
+			t.Errorf("Expected the config digest as image ID, got %v", digests)
+		}
+		layerIds := getImageLayerIds(tmpPath)
+		if !reflect.DeepEqual(layerIds, []string{layerDigest}) {
+			t.Errorf("Expected the layer blob as layer, got %v", layerIds)
+		}
+		if _, err := os.Stat(tmpPath + "/blobs/sha256/" + layerDigest); !os.IsNotExist(err) {
+			t.Errorf("Expected the layer blob not to be extracted, got %v", err)
+		}
+		if digest := layerDigestOf(t, tmpPath+"/"+layerIds[0]+"/layer.tar"); digest != layerDigest {
+			t.Errorf("Expected the layer to be read from the archive, got digest %s", digest)
+		}
+	}
+}
+
+// layerDigestOf returns the hex encoded sha256 digest of the layer file
+func layerDigestOf(t *testing.T, path string) string {
+	content, err := readImageFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
+
+// tarArchive returns a tar of the files, given as name and content
+func tarArchive(t *testing.T, files [][2]string) []byte {
+	var archive bytes.Buffer
+	writer := tar.NewWriter(&archive)
+	for _, file := range files {
+		if err := writer.WriteHeader(&tar.Header{Name: file[0], Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(file[1]))}); err != nil {
+			t.Fatal(err)
+		}
+		writer.Write([]byte(file[1]))
+	}
+	writer.Close()
+	return archive.Bytes()
+}
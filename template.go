@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// reportTemplate is the user supplied template used by the template output format
+var reportTemplate *template.Template
+
+// templateFuncs are the helper functions available in report templates
+var templateFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// parseTemplateFile reads the template file and parses it
+func parseTemplateFile(templateFile string) *template.Template {
+	templateBytes, err := ioutil.ReadFile(templateFile)
+	if err != nil {
+		logger.Fatalf("Could not parse template file, could not read file %v", err)
+	}
+	tmpl, err := template.New(filepath.Base(templateFile)).Funcs(templateFuncs).Parse(string(templateBytes))
+	if err != nil {
+		logger.Fatalf("Could not parse template file %v", err)
+	}
+	return tmpl
+}
+
+// writeTemplateReport renders the report through the user supplied template
+func writeTemplateReport(w io.Writer, report *vulnerabilityReport) error {
+	return reportTemplate.Execute(w, report)
+}
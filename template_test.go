@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteTemplateReport(t *testing.T) {
+	initializeLogger("")
+	dir, _ := ioutil.TempDir("", "template")
+	defer os.RemoveAll(dir)
+	templateFile := filepath.Join(dir, "report.tmpl")
+	ioutil.WriteFile(templateFile, []byte(`{{.Image}}: {{join .Unapproved ","}}
+{{range .Vulnerabilities}}{{upper .Severity}} {{json .FeatureName}}
+{{end}}`), 0644)
+	reportTemplate = parseTemplateFile(templateFile)
+	defer func() { reportTemplate = nil }()
+
+	report := &vulnerabilityReport{
+		Image:      "alpine:3.5",
+		Unapproved: []string{"CVE-2016-9840", "CVE-2016-9841"},
+		Vulnerabilities: []vulnerabilityInfo{
+			{FeatureName: "zlib", Severity: "High"},
+			{FeatureName: "musl", Severity: "Low"},
+		},
+	}
+	var buffer bytes.Buffer
+	if err := writeTemplateReport(&buffer, report); err != nil {
+		t.Fatalf("Could not write template report: %v", err)
+	}
+	expected := "alpine:3.5: CVE-2016-9840,CVE-2016-9841\nHIGH \"zlib\"\nLOW \"musl\"\n"
+	if buffer.String() != expected {
+		t.Errorf("Expected the report rendered through the template, got %q", buffer.String())
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const otlpTracesURI = "/v1/traces"
+
+// Kinds and status codes of OTLP spans
+const (
+	spanKindInternal = 1
+	spanKindServer   = 2
+	spanKindClient   = 3
+	spanStatusOK     = 1
+	spanStatusError  = 2
+)
+
+// tracer exports a trace of every scan to an OpenTelemetry collector, nil when tracing is not enabled
+var tracer *otlpTracer
+
+// otlpTracer collects the spans of the scan in progress and exports them with OTLP over HTTP once the scan is done
+type otlpTracer struct {
+	endpoint string
+	headers  map[string]string
+	mutex    sync.Mutex
+	root     *span
+	spans    []*span
+}
+
+// span is a timed operation of a scan, the methods of a nil span do nothing so callers don't check whether tracing is enabled
+type span struct {
+	traceID    string
+	spanID     string
+	parentID   string
+	name       string
+	kind       int
+	start      time.Time
+	end        time.Time
+	attributes map[string]string
+	err        string
+}
+
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes"`
+	Status            struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	} `json:"status"`
+}
+
+// newTracer returns the tracer exporting to the OTLP/HTTP endpoint, with the headers given like OTEL_EXPORTER_OTLP_HEADERS as 'key=value,key=value',
+// nil without endpoint
+func newTracer(endpoint string, headers string) *otlpTracer {
+	if endpoint == "" {
+		return nil
+	}
+	tracer := &otlpTracer{endpoint: strings.TrimSuffix(endpoint, "/"), headers: map[string]string{"Content-Type": "application/json"}}
+	for _, header := range strings.Split(headers, ",") {
+		if parts := strings.SplitN(header, "=", 2); len(parts) == 2 && strings.TrimSpace(parts[0]) != "" {
+			tracer.headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	return tracer
+}
+
+// traceID returns a random ID of the given number of bytes, hex encoded
+func traceID(size int) string {
+	id := make([]byte, size)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// startScan starts the trace of a scan, the spans started until the scan finishes are part of it
+func (tracer *otlpTracer) startScan(name string, attributes map[string]string) *span {
+	if tracer == nil {
+		return nil
+	}
+	root := &span{traceID: traceID(16), spanID: traceID(8), name: name, kind: spanKindInternal, start: time.Now(), attributes: attributes}
+	tracer.mutex.Lock()
+	defer tracer.mutex.Unlock()
+	tracer.root = root
+	tracer.spans = []*span{root}
+	return root
+}
+
+// startSpan starts a span of the scan in progress, nil when no scan is traced
+func (tracer *otlpTracer) startSpan(name string, kind int, attributes map[string]string) *span {
+	if tracer == nil {
+		return nil
+	}
+	tracer.mutex.Lock()
+	defer tracer.mutex.Unlock()
+	if tracer.root == nil {
+		return nil
+	}
+	if attributes == nil {
+		attributes = map[string]string{}
+	}
+	child := &span{traceID: tracer.root.traceID, spanID: traceID(8), parentID: tracer.root.spanID, name: name, kind: kind, start: time.Now(), attributes: attributes}
+	tracer.spans = append(tracer.spans, child)
+	return child
+}
+
+// setAttribute sets an attribute of the span
+func (span *span) setAttribute(key string, value string) {
+	if span != nil {
+		span.attributes[key] = value
+	}
+}
+
+// finish ends the span, failed when there is an error
+func (span *span) finish(err error) {
+	if span == nil {
+		return
+	}
+	span.end = time.Now()
+	if err != nil {
+		span.err = err.Error()
+	}
+}
+
+// finishScan ends the trace of the scan and exports its spans, failures are logged as warnings
+func (tracer *otlpTracer) finishScan(root *span, failure string) {
+	if tracer == nil || root == nil {
+		return
+	}
+	tracer.mutex.Lock()
+	if tracer.root != root {
+		tracer.mutex.Unlock()
+		return // exported already
+	}
+	root.end = time.Now()
+	root.err = failure
+	spans := tracer.spans
+	tracer.root, tracer.spans = nil, nil
+	tracer.mutex.Unlock()
+
+	content, _ := json.Marshal(otlpTraces(spans))
+	if _, err := upload("POST", tracer.endpoint+otlpTracesURI, content, tracer.headers); err != nil {
+		logger.Warnf("Could not export the trace of the scan: %v", err)
+	}
+}
+
+// abort ends the trace of the scan in progress as failed, so the trace of a scan that exits on an error is exported as well
+func (tracer *otlpTracer) abort(failure string) {
+	if tracer == nil {
+		return
+	}
+	tracer.mutex.Lock()
+	root := tracer.root
+	tracer.mutex.Unlock()
+	tracer.finishScan(root, failure)
+}
+
+// otlpTraces returns the OTLP JSON request of the spans, spans that did not end are ended with the scan
+func otlpTraces(spans []*span) map[string]interface{} {
+	converted := make([]otlpSpan, len(spans))
+	for i, span := range spans {
+		end := span.end
+		if end.IsZero() {
+			end = spans[0].end
+		}
+		converted[i] = otlpSpan{
+			TraceID:           span.traceID,
+			SpanID:            span.spanID,
+			ParentSpanID:      span.parentID,
+			Name:              span.name,
+			Kind:              span.kind,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(end.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.attributes),
+		}
+		converted[i].Status.Code = spanStatusOK
+		if span.err != "" {
+			converted[i].Status.Code = spanStatusError
+			converted[i].Status.Message = span.err
+		}
+	}
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource":   map[string]interface{}{"attributes": otlpAttributes(map[string]string{"service.name": "clair-scanner"})},
+			"scopeSpans": []interface{}{map[string]interface{}{"scope": map[string]string{"name": "clair-scanner"}, "spans": converted}},
+		}},
+	}
+}
+
+// otlpAttributes returns the attributes as OTLP string attributes, sorted by key
+func otlpAttributes(attributes map[string]string) []otlpAttribute {
+	converted := make([]otlpAttribute, 0, len(attributes))
+	for key, value := range attributes {
+		attribute := otlpAttribute{Key: key}
+		attribute.Value.StringValue = value
+		converted = append(converted, attribute)
+	}
+	sort.Slice(converted, func(i, j int) bool {
+		return converted[i].Key < converted[j].Key
+	})
+	return converted
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTracer(t *testing.T) {
+	initializeLogger("")
+	var exported struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []otlpSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	var authorization string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != otlpTracesURI {
+			t.Errorf("Expected the traces to be exported to %s, got %s", otlpTracesURI, r.URL.Path)
+		}
+		authorization = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&exported)
+	}))
+	defer collector.Close()
+
+	tracer := newTracer(collector.URL+"/", "Authorization=Bearer secret")
+	root := tracer.startScan("scan alpine:3.5", map[string]string{"image": "alpine:3.5"})
+	tracer.startSpan("save image", spanKindInternal, nil).finish(nil)
+	clairRequest := tracer.startSpan("clair GET /v1/layers", spanKindClient, nil)
+	clairRequest.setAttribute("http.status_code", "500")
+	clairRequest.finish(errors.New("got response 500"))
+	tracer.finishScan(root, "")
+
+	if authorization != "Bearer secret" {
+		t.Errorf("Expected the headers to be sent, got %s", authorization)
+	}
+	spans := exported.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 3 || spans[0].Name != "scan alpine:3.5" || spans[0].ParentSpanID != "" || len(spans[0].TraceID) != 32 {
+		t.Fatalf("Expected the scan and 2 spans, got %+v", spans)
+	}
+	for _, span := range spans[1:] {
+		if span.TraceID != spans[0].TraceID || span.ParentSpanID != spans[0].SpanID {
+			t.Errorf("Expected span %s to be a child of the scan, got %+v", span.Name, span)
+		}
+	}
+	if spans[2].Kind != spanKindClient || spans[2].Status.Code != spanStatusError || spans[2].Attributes[0].Value.StringValue != "500" {
+		t.Errorf("Expected a failed client span, got %+v", spans[2])
+	}
+	if tracer.startSpan("after", spanKindInternal, nil) != nil {
+		t.Errorf("Expected no span without a scan in progress")
+	}
+}
+
+func TestTracerDisabled(t *testing.T) {
+	tracer := newTracer("", "")
+	root := tracer.startScan("scan", nil)
+	span := tracer.startSpan("save image", spanKindInternal, nil)
+	span.setAttribute("image", "alpine:3.5")
+	span.finish(nil)
+	tracer.finishScan(root, "")
+	tracer.abort("failed")
+	if tracer != nil || root != nil || span != nil {
+		t.Errorf("Expected tracing to be disabled without endpoint")
+	}
+}
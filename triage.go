@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// triageVulnerabilities walks through the unapproved vulnerabilities and asks to accept or reject every CVE, accepted CVEs are added to the whitelist file of the image repository and approved in the report, the rejected CVEs are returned
+func triageVulnerabilities(report *vulnerabilityReport, whitelistFile string, in io.Reader, out io.Writer) []string {
+	details := make(map[string][]vulnerabilityInfo)
+	cves := []string{}
+	for _, vulnerability := range report.Vulnerabilities {
+		if vulnerability.Status != "Unapproved" {
+			continue
+		}
+		if _, exists := details[vulnerability.Vulnerability]; !exists {
+			cves = append(cves, vulnerability.Vulnerability)
+		}
+		details[vulnerability.Vulnerability] = append(details[vulnerability.Vulnerability], vulnerability)
+	}
+
+	input := bufio.NewScanner(in)
+	accepted := &yaml.Node{Kind: yaml.MappingNode}
+	reasons := make(map[string]string)
+	rejected := []string{}
+triage:
+	for i, cve := range cves {
+		printTriageDetails(out, i+1, len(cves), details[cve])
+		answer, ok := prompt(input, out, "[a]ccept, [r]eject, [q]uit: ", "a", "r", "q")
+		switch {
+		case answer == "a":
+			reason, _ := prompt(input, out, "Reason: ")
+			if reason == "" {
+				reason = "TODO"
+			}
+			entry := &yaml.Node{Kind: yaml.MappingNode}
+			setMappingValue(entry, "reason", scalarNode(reason))
+			if owner := os.Getenv("USER"); owner != "" {
+				setMappingValue(entry, "owner", scalarNode(owner))
+			}
+			setMappingValue(accepted, cve, entry)
+			reasons[cve] = reason
+		case answer == "r":
+			rejected = append(rejected, cve)
+		case answer == "q" || !ok:
+			rejected = append(rejected, cves[i:]...)
+			break triage
+		}
+	}
+
+	if len(accepted.Content) > 0 {
+		if err := addToWhitelistFile(whitelistFile, imageReferences(report.Image, nil)[0], accepted); err != nil {
+			logger.Fatalf("Could not update whitelist file [%s]: %v", whitelistFile, err)
+		}
+		logger.Infof("Added %d accepted vulnerabilities to whitelist [%s]", len(accepted.Content)/2, whitelistFile)
+	}
+	for i, vulnerability := range report.Vulnerabilities {
+		if reason, exists := reasons[vulnerability.Vulnerability]; exists && vulnerability.Status == "Unapproved" {
+			report.Vulnerabilities[i].Status = "Approved"
+			report.Vulnerabilities[i].Whitelisted = true
+			report.Vulnerabilities[i].WhitelistReason = reason
+			report.Vulnerabilities[i].WhitelistOwner = os.Getenv("USER")
+		}
+	}
+	return rejected
+}
+
+// printTriageDetails prints the details of a CVE and every package it affects
+func printTriageDetails(out io.Writer, number int, total int, vulnerabilities []vulnerabilityInfo) {
+	vulnerability := vulnerabilities[0]
+	fmt.Fprintf(out, "\n[%d/%d] %s %s\n", number, total, vulnerability.Severity, vulnerability.Vulnerability)
+	if cvss := formatCVSS(vulnerability); cvss != "" {
+		fmt.Fprintf(out, "CVSS: %s\n", cvss)
+	}
+	for _, affected := range vulnerabilities {
+		fixedBy := affected.FixedBy
+		if fixedBy == "" {
+			fixedBy = "no fix available"
+		}
+		fmt.Fprintf(out, "Package: %s %s (%s)\n", affected.FeatureName, affected.FeatureVersion, fixedBy)
+	}
+	fmt.Fprintf(out, "%s\n%s\n", vulnerability.Description, vulnerability.Link)
+}
+
+// prompt asks a question until one of the valid answers is given, any answer is valid when none are given, false is returned when the input ends
+func prompt(input *bufio.Scanner, out io.Writer, question string, answers ...string) (string, bool) {
+	for {
+		fmt.Fprint(out, question)
+		if !input.Scan() {
+			return "", false
+		}
+		answer := strings.TrimSpace(input.Text())
+		if len(answers) == 0 {
+			return answer, true
+		}
+		if answer != "" && contains(answers, strings.ToLower(answer[:1])) {
+			return strings.ToLower(answer[:1]), true
+		}
+	}
+}
+
+// addToWhitelistFile adds the entries, a mapping of CVEs to whitelist entries, to the whitelist of the image in the whitelist file.
+// The other content of the file and its comments are kept, but the file is indented with two spaces
+func addToWhitelistFile(file string, image string, entries *yaml.Node) error {
+	var document yaml.Node
+	content, err := ioutil.ReadFile(file)
+	if err == nil {
+		err = yaml.Unmarshal(content, &document)
+	} else if os.IsNotExist(err) {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	if document.Kind == 0 {
+		document = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	if document.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("the whitelist is not a mapping")
+	}
+
+	images := mappingValue(document.Content[0], "images")
+	imageEntries := mappingValue(images, image)
+	for i := 0; i+1 < len(entries.Content); i += 2 {
+		setMappingValue(imageEntries, entries.Content[i].Value, entries.Content[i+1])
+	}
+
+	var output bytes.Buffer
+	encoder := yaml.NewEncoder(&output)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(&document); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, output.Bytes(), 0644)
+}
+
+// mappingValue returns the mapping under the key of the mapping, which is added when the key is missing or has no mapping yet
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			if mapping.Content[i+1].Kind != yaml.MappingNode {
+				mapping.Content[i+1] = &yaml.Node{Kind: yaml.MappingNode}
+			}
+			return mapping.Content[i+1]
+		}
+	}
+	value := &yaml.Node{Kind: yaml.MappingNode}
+	mapping.Content = append(mapping.Content, scalarNode(key), value)
+	return value
+}
+
+// setMappingValue sets the value of the key in the mapping, keeping the comments of an existing key
+func setMappingValue(mapping *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content[i+1] = value
+			return
+		}
+	}
+	mapping.Content = append(mapping.Content, scalarNode(key), value)
+}
+
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTriageVulnerabilities(t *testing.T) {
+	initializeLogger("")
+	dir, err := ioutil.TempDir("", "triage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	whitelistFile := filepath.Join(dir, "whitelist.yaml")
+	ioutil.WriteFile(whitelistFile, []byte("# Reviewed by the security team\ngeneralwhitelist:\n  CVE-0: existing # until the next release\nimages:\n  debian:\n    CVE-5: jessie only\n"), 0644)
+
+	report := &vulnerabilityReport{
+		Image: "debian:jessie",
+		Vulnerabilities: []vulnerabilityInfo{
+			{Vulnerability: "CVE-1", FeatureName: "openssl", Severity: "High", Status: "Unapproved"},
+			{Vulnerability: "CVE-2", FeatureName: "zlib", Severity: "Low", Status: "Unapproved"},
+			{Vulnerability: "CVE-3", FeatureName: "bash", Severity: "Low", Status: "Unapproved"},
+			{Vulnerability: "CVE-4", FeatureName: "bash", Severity: "Low", Status: "Approved"},
+		},
+	}
+	rejected := triageVulnerabilities(report, whitelistFile, strings.NewReader("x\naccept\nNot reachable\nr\nq\n"), ioutil.Discard)
+	if len(rejected) != 2 || rejected[0] != "CVE-2" || rejected[1] != "CVE-3" {
+		t.Errorf("Expected CVE-2 and CVE-3 to be rejected, got %v", rejected)
+	}
+
+	if report.Vulnerabilities[0].Status != "Approved" || report.Vulnerabilities[0].WhitelistReason != "Not reachable" || report.Vulnerabilities[1].Status != "Unapproved" {
+		t.Errorf("Expected only the accepted CVE-1 to be approved in the report, got %v", report.Vulnerabilities)
+	}
+
+	whitelist := parseWhitelistFile(whitelistFile, "")
+	if whitelist.GeneralWhitelist["CVE-0"].Description != "existing" {
+		t.Errorf("Expected the existing entries to be kept, got %v", whitelist.GeneralWhitelist)
+	}
+	if whitelist.Images["debian"]["CVE-1"].Reason != "Not reachable" || whitelist.Images["debian"]["CVE-5"].Description != "jessie only" {
+		t.Errorf("Expected CVE-1 to be accepted for debian, got %v", whitelist.Images)
+	}
+	content, _ := ioutil.ReadFile(whitelistFile)
+	if !strings.Contains(string(content), "# Reviewed by the security team\n") || !strings.Contains(string(content), "CVE-0: existing # until the next release\n") {
+		t.Errorf("Expected the comments of the whitelist file to be kept, got:\n%s", content)
+	}
+}
+
+func TestIsGrypeConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "triage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	grype := filepath.Join(dir, ".grype.yaml")
+	ioutil.WriteFile(grype, []byte("ignore:\n  - vulnerability: CVE-2008-4318\n"), 0644)
+	whitelist := filepath.Join(dir, "whitelist.yaml")
+	ioutil.WriteFile(whitelist, []byte("generalwhitelist:\n  CVE-1: XML\n"), 0644)
+	if !isGrypeConfigFile(grype) || isGrypeConfigFile(whitelist) || isGrypeConfigFile(filepath.Join(dir, "missing.yaml")) {
+		t.Errorf("Expected only the Grype configuration to be recognized")
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 
@@ -53,9 +55,32 @@ func createTmpPath(tmpPrefix string) string {
 	return tmpPath
 }
 
-// untar uses a Reader that represents a tar to untar it on the fly to a target folder
+// untar uses a Reader that represents a tar to untar it on the fly to a target folder,
+// layer files are not extracted, the tar is saved in the target folder and they are read from it
 func untar(imageReader io.ReadCloser, target string) error {
-	tarReader := tar.NewReader(imageReader)
+	archive, err := ioutil.TempFile(target, "image-*.tar")
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+	counter := &countingReader{reader: io.TeeReader(imageReader, archive)}
+
+	err = extractTar(counter, target, func(path string, header *tar.Header, _ io.Reader) error {
+		archivedLayers[path] = archivedFile{archive: archive.Name(), offset: counter.offset, size: header.Size}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Write what follows the end of the tar as well, the archive is complete when the image reader is drained
+	_, err = io.Copy(ioutil.Discard, counter)
+	return err
+}
+
+// extractTar untars the tar read from the reader on the fly to a target folder, the layer files are handed to saveLayer instead
+func extractTar(reader io.Reader, target string, saveLayer func(path string, header *tar.Header, layer io.Reader) error) error {
+	tarReader := tar.NewReader(reader)
+	layerLinks := make(map[string]string)
 
 	for {
 		header, err := tarReader.Next()
@@ -69,6 +94,15 @@ func untar(imageReader io.ReadCloser, target string) error {
 		if !strings.HasPrefix(path, filepath.Clean(target) + string(os.PathSeparator)) {
 			return fmt.Errorf("%s: illegal file path", header.Name)
 		}
+		if isLayerFile(header.Name) && header.Typeflag == tar.TypeSymlink {
+			layerLinks[path] = filepath.Join(filepath.Dir(path), header.Linkname)
+			continue
+		} else if isLayerFile(header.Name) && header.Typeflag == tar.TypeReg {
+			if err = saveLayer(path, header, tarReader); err != nil {
+				return err
+			}
+			continue
+		}
 		info := header.FileInfo()
 		if info.IsDir() {
 			if err = os.MkdirAll(path, info.Mode()); err != nil {
@@ -86,21 +120,94 @@ func untar(imageReader io.ReadCloser, target string) error {
 			return err
 		}
 	}
+
+	// Layers with the same content are saved once, the other layers link to it
+	for path, linked := range layerLinks {
+		if err := linkLayerFile(path, linked); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// parseWhitelistFile reads the whitelist file and parses it
-func parseWhitelistFile(whitelistFile string) vulnerabilitiesWhitelist {
+// parseWhitelistFile reads the whitelist file, or downloads it when it is an http(s) URL, and parses it including the whitelists it extends
+func parseWhitelistFile(whitelistFile string, header string) vulnerabilitiesWhitelist {
+	return parseExtendedWhitelistFile(whitelistFile, header, "", 0)
+}
+
+// parseExtendedWhitelistFile parses the whitelist file and the whitelists it extends, the header is given for origin, the first downloaded whitelist,
+// and is only sent to whitelists with the same scheme and host so an extended whitelist on another host doesn't get the credentials
+func parseExtendedWhitelistFile(whitelistFile string, header string, origin string, depth int) vulnerabilitiesWhitelist {
 	whitelistTmp := vulnerabilitiesWhitelist{}
+	if depth > maxWhitelistExtends {
+		logger.Fatalf("Could not parse whitelist file, %s extends more than %d whitelists, is there a cycle?", whitelistFile, maxWhitelistExtends)
+	}
 
+	fileHeader := header
+	if strings.HasPrefix(whitelistFile, "http://") || strings.HasPrefix(whitelistFile, "https://") {
+		if origin == "" {
+			origin = whitelistFile
+		}
+		if !sameOrigin(origin, whitelistFile) {
+			fileHeader = ""
+		}
+	}
+	whitelistBytes := readWhitelistFile(whitelistFile, fileHeader)
+	if isTrivyIgnoreFile(whitelistFile) {
+		return parseTrivyIgnore(whitelistBytes)
+	}
+	if grypeWhitelist, isGrype := parseGrypeIgnore(whitelistBytes); isGrype {
+		return grypeWhitelist
+	}
+	if err := yaml.Unmarshal(whitelistBytes, &whitelistTmp); err != nil {
+		logger.Fatalf("Could not parse whitelist file, could not unmarshal %v", err)
+	}
+	if whitelistTmp.Extends != "" {
+		base := parseExtendedWhitelistFile(resolveWhitelistLocation(whitelistFile, whitelistTmp.Extends), header, origin, depth+1)
+		whitelistTmp = mergeWhitelists(base, whitelistTmp)
+	}
+	return whitelistTmp
+}
+
+// readWhitelistFile reads the whitelist file, or downloads it when it is an http(s) URL
+func readWhitelistFile(whitelistFile string, header string) []byte {
+	if strings.HasPrefix(whitelistFile, "http://") || strings.HasPrefix(whitelistFile, "https://") {
+		whitelistBytes, err := download(whitelistFile, parseHeader(header))
+		if err != nil {
+			logger.Fatalf("Could not parse whitelist file, could not download %s: %v", whitelistFile, err)
+		}
+		return whitelistBytes
+	}
 	whitelistBytes, err := ioutil.ReadFile(whitelistFile)
 	if err != nil {
 		logger.Fatalf("Could not parse whitelist file, could not read file %v", err)
 	}
-	if err = yaml.Unmarshal(whitelistBytes, &whitelistTmp); err != nil {
-		logger.Fatalf("Could not parse whitelist file, could not unmarshal %v", err)
+	return whitelistBytes
+}
+
+// sameOrigin tells if both URLs have the same scheme and host
+func sameOrigin(first string, second string) bool {
+	firstURL, err := url.Parse(first)
+	if err != nil {
+		return false
 	}
-	return whitelistTmp
+	secondURL, err := url.Parse(second)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(firstURL.Scheme, secondURL.Scheme) && strings.EqualFold(firstURL.Host, secondURL.Host)
+}
+
+// parseHeader parses an HTTP request header given as "Name: value"
+func parseHeader(header string) map[string]string {
+	if header == "" {
+		return nil
+	}
+	parts := strings.SplitN(header, ":", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+		logger.Fatalf("Invalid header %s given, use 'Name: value'", header)
+	}
+	return map[string]string{strings.TrimSpace(parts[0]): strings.TrimSpace(parts[1])}
 }
 
 // Validate that the given CVE severity threshold is a valid severity
@@ -112,3 +219,38 @@ func validateThreshold(threshold string) {
 	}
 	logger.Fatalf("Invalid CVE severity threshold %s given", threshold)
 }
+
+// Validate that the given CVE severities to ignore are valid severities
+func validateSeverities(severities []string) {
+	for _, severity := range severities {
+		if _, exists := SeverityMap[severity]; !exists {
+			logger.Fatalf("Invalid CVE severity %s given to ignore", severity)
+		}
+	}
+}
+
+// parseCVSS parses and validates a CVSS base score
+func parseCVSS(value string) float64 {
+	score, err := strconv.ParseFloat(value, 64)
+	if err != nil || score < 0 || score > 10 {
+		logger.Fatalf("Invalid CVSS score %s given, must be between 0 and 10", value)
+	}
+	return score
+}
+
+// parseEPSS parses and validates an EPSS probability
+func parseEPSS(value string) float64 {
+	score, err := strconv.ParseFloat(value, 64)
+	if err != nil || score < 0 || score > 1 {
+		logger.Fatalf("Invalid EPSS score %s given, must be between 0 and 1", value)
+	}
+	return score
+}
+
+// Validate that the given output format is supported
+func validateFormat(format string) {
+	if _, exists := reportFormatters[format]; exists || format == "table" {
+		return
+	}
+	logger.Fatalf("Invalid output format %s given", format)
+}
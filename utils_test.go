@@ -26,3 +26,14 @@ func testListenOnSignal(t *testing.T, testSignal syscall.Signal) {
 	})
 	<-done
 }
+
+func TestParseHeader(t *testing.T) {
+	initializeLogger("")
+	header := parseHeader("Authorization: Bearer secret:token")
+	if header["Authorization"] != "Bearer secret:token" {
+		t.Errorf("Expected the Authorization header, got %v", header)
+	}
+	if parseHeader("") != nil {
+		t.Errorf("Expected no headers")
+	}
+}
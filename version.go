@@ -0,0 +1,108 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// compareVersions compares two package versions segment by segment, numeric segments are compared as numbers and other segments as text
+func compareVersions(a string, b string) int {
+	segmentsA, segmentsB := versionSegments(a), versionSegments(b)
+	for i := 0; i < len(segmentsA) && i < len(segmentsB); i++ {
+		numberA, errA := strconv.Atoi(segmentsA[i])
+		numberB, errB := strconv.Atoi(segmentsB[i])
+		switch {
+		case errA == nil && errB == nil && numberA != numberB:
+			if numberA < numberB {
+				return -1
+			}
+			return 1
+		case (errA != nil || errB != nil) && segmentsA[i] != segmentsB[i]:
+			if segmentsA[i] < segmentsB[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case len(segmentsA) < len(segmentsB):
+		return -1
+	case len(segmentsA) > len(segmentsB):
+		return 1
+	}
+	return 0
+}
+
+// versionSegments splits a version in runs of digits and runs of letters, separators are dropped
+func versionSegments(version string) []string {
+	segments := []string{}
+	current := ""
+	for _, r := range version {
+		if !unicode.IsDigit(r) && !unicode.IsLetter(r) {
+			if current != "" {
+				segments = append(segments, current)
+			}
+			current = ""
+			continue
+		}
+		if current != "" && unicode.IsDigit(r) != unicode.IsDigit(rune(current[len(current)-1])) {
+			segments = append(segments, current)
+			current = ""
+		}
+		current += string(r)
+	}
+	if current != "" {
+		segments = append(segments, current)
+	}
+	return segments
+}
+
+// versionInRange tells if the version satisfies every comma separated constraint of the range, e.g. ">=1.0, <1.1"
+func versionInRange(version string, versionRange string) bool {
+	for _, constraint := range parseList(versionRange) {
+		operator, constraintVersion := splitVersionConstraint(constraint)
+		comparison := compareVersions(version, constraintVersion)
+		satisfied := false
+		switch operator {
+		case "<":
+			satisfied = comparison < 0
+		case "<=":
+			satisfied = comparison <= 0
+		case ">":
+			satisfied = comparison > 0
+		case ">=":
+			satisfied = comparison >= 0
+		case "!=":
+			satisfied = comparison != 0
+		case "", "=", "==":
+			satisfied = comparison == 0
+		}
+		if !satisfied {
+			return false
+		}
+	}
+	return true
+}
+
+// validateVersionRange checks that every constraint of the version range has a supported operator and a version
+func validateVersionRange(versionRange string) bool {
+	for _, constraint := range parseList(versionRange) {
+		operator, constraintVersion := splitVersionConstraint(constraint)
+		switch operator {
+		case "", "<", "<=", ">", ">=", "!=", "=", "==":
+		default:
+			return false
+		}
+		if constraintVersion == "" || !unicode.IsLetter(rune(constraintVersion[0])) && !unicode.IsDigit(rune(constraintVersion[0])) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitVersionConstraint splits a constraint like ">= 1.0" in its operator and version
+func splitVersionConstraint(constraint string) (string, string) {
+	version := strings.TrimLeft(constraint, "<>=!")
+	return constraint[:len(constraint)-len(version)], strings.TrimSpace(version)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	getUpdateOperationsURI = "/matcher/api/v1/internal/update_operations"
+	clairWaitInterval      = 5 * time.Second
+)
+
+// waitForClair polls Clair until it is ready to scan, it gives up when Clair is not ready within the timeout
+func waitForClair(clairURL string, api string, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for {
+		ready, reason := clairReady(clairURL, api)
+		if ready {
+			logger.Info("Clair is ready")
+			return
+		}
+		if time.Now().After(deadline) {
+			logger.Fatalf("Clair is not ready after %v: %s", timeout, reason)
+		}
+		logger.Infof("Waiting for Clair: %s", reason)
+		time.Sleep(clairWaitInterval)
+	}
+}
+
+// clairReady tells whether Clair serves its API and its updaters have fetched vulnerabilities, or else why not
+func clairReady(clairURL string, api string) (bool, string) {
+	status, err := getClairStatus(clairURL+getIndexStateURI, nil)
+	if err != nil {
+		return false, fmt.Sprintf("Clair is unreachable: %v", err)
+	}
+	if status == 200 && (api == "auto" || api == "v4") {
+		var updateOperations map[string]json.RawMessage
+		if status, err = getClairStatus(clairURL+getUpdateOperationsURI, &updateOperations); err != nil || status != 200 {
+			return false, fmt.Sprintf("the matcher is not ready, got response %d %v", status, err)
+		} else if len(updateOperations) == 0 {
+			return false, "the updaters have not fetched any vulnerabilities yet"
+		}
+		return true, ""
+	} else if api == "v4" {
+		return false, fmt.Sprintf("the indexer is not ready, got response %d", status)
+	}
+
+	if api == "auto" || api == "v1" {
+		var namespaces struct{ Namespaces []json.RawMessage }
+		if status, err = getClairStatus(clairURL+getNamespacesURI, &namespaces); err == nil && status == 200 {
+			if len(namespaces.Namespaces) == 0 {
+				return false, "the updaters have not fetched any vulnerabilities yet"
+			}
+			return true, ""
+		} else if api == "v1" {
+			return false, fmt.Sprintf("the v1 API is not ready, got response %d %v", status, err)
+		}
+	}
+	return true, "" // the Clair v3 REST gateway has no status endpoint, it is ready when it responds
+}
+
+// getClairStatus sends a single GET request to Clair and decodes the response when it is successful
+func getClairStatus(url string, v interface{}) (int, error) {
+	response, err := sendClairRequest("GET", url, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == 200 && v != nil {
+		err = json.NewDecoder(response.Body).Decode(v)
+	}
+	return response.StatusCode, err
+}
+
+// parseWait parses and validates the time to wait for Clair
+func parseWait(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	wait, err := time.ParseDuration(value)
+	if err != nil || wait < 0 {
+		logger.Fatalf("Invalid wait %s given, use a duration like '5m'", value)
+	}
+	return wait
+}
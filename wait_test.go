@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClairReady(t *testing.T) {
+	initializeLogger("")
+	responses := map[string]string{}
+	clair := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if response, exists := responses[r.URL.Path]; exists {
+			w.Write([]byte(response))
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer clair.Close()
+
+	responses[getNamespacesURI] = `{"Namespaces": []}`
+	if ready, _ := clairReady(clair.URL, "auto"); ready {
+		t.Errorf("Expected Clair 2 without namespaces not to be ready")
+	}
+	responses[getNamespacesURI] = `{"Namespaces": [{"Name": "debian:9"}]}`
+	if ready, reason := clairReady(clair.URL, "auto"); !ready {
+		t.Errorf("Expected Clair 2 with namespaces to be ready, got %s", reason)
+	}
+	if ready, _ := clairReady(clair.URL, "v4"); ready {
+		t.Errorf("Expected Clair 4 without indexer not to be ready")
+	}
+
+	responses[getIndexStateURI] = `{"state": "abc"}`
+	responses[getUpdateOperationsURI] = `{}`
+	if ready, _ := clairReady(clair.URL, "auto"); ready {
+		t.Errorf("Expected Clair 4 without update operations not to be ready")
+	}
+	responses[getUpdateOperationsURI] = `{"debian": [{"ref": "1"}]}`
+	if ready, reason := clairReady(clair.URL, "v4"); !ready {
+		t.Errorf("Expected Clair 4 with update operations to be ready, got %s", reason)
+	}
+}
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	whitelistDateFormat    = "2006-01-02"
+	whitelistExpiryWarning = 30 * 24 * time.Hour // entries expiring within this period are logged as a warning
+	maxWhitelistExtends    = 10
+)
+
+// whitelistEntry is the acceptance of a CVE or package, in the whitelist file it is either only a description or a mapping with a description, expiration date, owner and reason
+type whitelistEntry struct {
+	Description string `yaml:"description"`
+	Expires     string `yaml:"expires"`
+	Owner       string `yaml:"owner"`
+	Reason      string `yaml:"reason"`
+	Versions    string `yaml:"versions"` // version range of a package entry, e.g. "<4.9"
+}
+
+// whitelistRule accepts every vulnerability with one of the severities, in one of the namespaces and of one of the images, criteria that are not given match everything
+type whitelistRule struct {
+	Severities      []string `yaml:"severities"`
+	Namespaces      []string `yaml:"namespaces"`      // Clair namespaces or glob patterns, e.g. "debian:*"
+	Images          []string `yaml:"images"`          // image names or glob patterns
+	Vulnerabilities []string `yaml:"vulnerabilities"` // CVEs, glob patterns or regular expressions between slashes
+	Packages        []string `yaml:"packages"`        // package names or glob patterns
+	Versions        string   `yaml:"versions"`        // version range of the packages, e.g. "<4.9"
+	Description     string   `yaml:"description"`
+	Expires         string   `yaml:"expires"`
+	Owner           string   `yaml:"owner"`
+	Reason          string   `yaml:"reason"`
+}
+
+// entry returns the rule as whitelist entry, so it is reported and expires like the other entries
+func (r whitelistRule) entry() whitelistEntry {
+	return whitelistEntry{Description: r.Description, Expires: r.Expires, Owner: r.Owner, Reason: r.Reason}
+}
+
+// String describes the rule in log messages
+func (r whitelistRule) String() string {
+	criteria := []string{}
+	for _, criterion := range [][]string{r.Severities, r.Namespaces, r.Images, r.Vulnerabilities, r.Packages, {r.Versions}} {
+		if len(criterion) > 0 && criterion[0] != "" {
+			criteria = append(criteria, strings.Join(criterion, ","))
+		}
+	}
+	return "rule [" + strings.Join(criteria, " ") + "]"
+}
+
+// matches tells if the rule accepts the vulnerability of the image
+func (r whitelistRule) matches(references []string, vulnerability vulnerabilityInfo) bool {
+	if len(r.Severities) > 0 && !contains(r.Severities, vulnerability.Severity) {
+		return false
+	}
+	if len(r.Namespaces) > 0 && !matchesAny(r.Namespaces, vulnerability.Namespace) {
+		return false
+	}
+	if len(r.Vulnerabilities) > 0 && !matchesAnyKey(r.Vulnerabilities, vulnerability.Vulnerability) {
+		return false
+	}
+	if len(r.Packages) > 0 && !matchesAny(r.Packages, vulnerability.FeatureName) {
+		return false
+	}
+	if r.Versions != "" && !versionInRange(vulnerability.FeatureVersion, r.Versions) {
+		return false
+	}
+	if len(r.Images) == 0 {
+		return true
+	}
+	for _, image := range r.Images {
+		if matchesAnyReference(image, references) {
+			return true
+		}
+	}
+	return false
+}
+
+// UnmarshalYAML reads an entry given as a plain description as well as an entry given as a mapping
+func (e *whitelistEntry) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var description string
+	if err := unmarshal(&description); err == nil {
+		e.Description = description
+		return nil
+	}
+	type plainEntry whitelistEntry
+	return unmarshal((*plainEntry)(e))
+}
+
+// justification returns why the CVE is accepted, the description is used for entries without a reason
+func (e whitelistEntry) justification() string {
+	if e.Reason != "" {
+		return e.Reason
+	}
+	return e.Description
+}
+
+// expiresAt returns the moment the entry expires, which is the end of the expiration day, and false if the entry never expires
+func (e whitelistEntry) expiresAt() (time.Time, bool) {
+	if e.Expires == "" {
+		return time.Time{}, false
+	}
+	date, err := time.Parse(whitelistDateFormat, e.Expires)
+	if err != nil {
+		logger.Fatalf("Could not parse whitelist file, invalid expiration date %s, use YYYY-MM-DD", e.Expires)
+	}
+	return date.AddDate(0, 0, 1), true
+}
+
+// removeExpiredWhitelistEntries drops the expired entries from the whitelist so their vulnerabilities are unapproved again, entries expiring soon are logged as a warning
+func removeExpiredWhitelistEntries(whitelist vulnerabilitiesWhitelist, now time.Time) vulnerabilitiesWhitelist {
+	removeExpiredEntries("general whitelist", whitelist.GeneralWhitelist, now)
+	removeExpiredEntries("package whitelist", whitelist.Packages, now)
+	for image, entries := range whitelist.Images {
+		removeExpiredEntries("whitelist of image "+image, entries, now)
+	}
+	rules := []whitelistRule{}
+	for _, rule := range whitelist.Rules {
+		expiresAt, expires := rule.entry().expiresAt()
+		if expires && !now.Before(expiresAt) {
+			logger.Warnf("Acceptance of the %s expired on %s, it is unapproved again", rule, rule.Expires)
+			continue
+		} else if expires && expiresAt.Sub(now) < whitelistExpiryWarning {
+			logger.Warnf("Acceptance of the %s expires on %s", rule, rule.Expires)
+		}
+		rules = append(rules, rule)
+	}
+	whitelist.Rules = rules
+	return whitelist
+}
+
+func removeExpiredEntries(section string, entries map[string]whitelistEntry, now time.Time) {
+	cves := make([]string, 0, len(entries))
+	for cve := range entries {
+		cves = append(cves, cve)
+	}
+	sort.Strings(cves)
+
+	for _, cve := range cves {
+		expiresAt, expires := entries[cve].expiresAt()
+		if !expires {
+			continue
+		}
+		if !now.Before(expiresAt) {
+			logger.Warnf("Acceptance of %s in the %s expired on %s, it is unapproved again", cve, section, entries[cve].Expires)
+			delete(entries, cve)
+		} else if expiresAt.Sub(now) < whitelistExpiryWarning {
+			logger.Warnf("Acceptance of %s in the %s expires on %s", cve, section, entries[cve].Expires)
+		}
+	}
+}
+
+// validateWhitelistJustification checks that every whitelist entry has an owner and a reason, so it is clear who accepted which CVE and why
+func validateWhitelistJustification(whitelist vulnerabilitiesWhitelist) {
+	missing := missingJustifications("general whitelist", whitelist.GeneralWhitelist)
+	missing = append(missing, missingJustifications("package whitelist", whitelist.Packages)...)
+	for image, entries := range whitelist.Images {
+		missing = append(missing, missingJustifications("whitelist of image "+image, entries)...)
+	}
+	for _, rule := range whitelist.Rules {
+		if rule.Owner == "" || rule.Reason == "" {
+			missing = append(missing, rule.String())
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		logger.Fatalf("Whitelist entries without owner or reason: %s", strings.Join(missing, ", "))
+	}
+}
+
+func missingJustifications(section string, entries map[string]whitelistEntry) []string {
+	missing := []string{}
+	for cve, entry := range entries {
+		if entry.Owner == "" || entry.Reason == "" {
+			missing = append(missing, cve+" in the "+section)
+		}
+	}
+	return missing
+}
+
+// unusedWhitelistEntries returns the entries of the general whitelist and the whitelist of the image that match no vulnerability of the image
+func unusedWhitelistEntries(references []string, whitelist vulnerabilitiesWhitelist, vulnerabilities []vulnerabilityInfo) []string {
+	unused := []string{}
+	for _, entries := range []map[string]whitelistEntry{whitelist.GeneralWhitelist, getImageVulnerabilities(references, whitelist.Images)} {
+		for key := range entries {
+			if !matchesAnyVulnerability(key, vulnerabilities) {
+				unused = append(unused, key)
+			}
+		}
+	}
+	for name, entry := range whitelist.Packages {
+		if !matchesAnyPackage(name, entry, vulnerabilities) {
+			unused = append(unused, name)
+		}
+	}
+	for _, rule := range whitelist.Rules {
+		if !matchesAnyRuleVulnerability(rule, references, vulnerabilities) {
+			unused = append(unused, rule.String())
+		}
+	}
+	sort.Strings(unused)
+	return unused
+}
+
+func matchesAnyPackage(name string, entry whitelistEntry, vulnerabilities []vulnerabilityInfo) bool {
+	for _, vulnerability := range vulnerabilities {
+		if packageEntryMatches(name, entry, vulnerability) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAnyRuleVulnerability(rule whitelistRule, references []string, vulnerabilities []vulnerabilityInfo) bool {
+	for _, vulnerability := range vulnerabilities {
+		if rule.matches(references, vulnerability) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAnyVulnerability(key string, vulnerabilities []vulnerabilityInfo) bool {
+	for _, vulnerability := range vulnerabilities {
+		if whitelistKeyMatches(key, vulnerability.Vulnerability) {
+			return true
+		}
+	}
+	return false
+}
+
+// reportUnusedWhitelistEntries logs the unused whitelist entries, so stale acceptances get cleaned up
+func reportUnusedWhitelistEntries(unused []string) {
+	for _, cve := range unused {
+		logger.Warnf("Whitelist entry %s matches no vulnerability, it can be removed", cve)
+	}
+}
+
+// lookupWhitelistEntry finds the entry approving the CVE, an exact entry has precedence over glob patterns like CVE-2016-* and regular expressions like /^CVE-2016-\d+$/
+func lookupWhitelistEntry(entries map[string]whitelistEntry, cve string) (whitelistEntry, bool) {
+	if entry, exists := entries[cve]; exists {
+		return entry, true
+	}
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if whitelistKeyMatches(key, cve) {
+			return entries[key], true
+		}
+	}
+	return whitelistEntry{}, false
+}
+
+// lookupPackageEntry finds the package entry approving every vulnerability of the package, optionally only for the versions in its version range
+func lookupPackageEntry(entries map[string]whitelistEntry, vulnerability vulnerabilityInfo) (whitelistEntry, bool) {
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if packageEntryMatches(name, entries[name], vulnerability) {
+			return entries[name], true
+		}
+	}
+	return whitelistEntry{}, false
+}
+
+// lookupWhitelistRule finds the first rule accepting the vulnerability of the image
+func lookupWhitelistRule(rules []whitelistRule, references []string, vulnerability vulnerabilityInfo) (whitelistEntry, bool) {
+	for _, rule := range rules {
+		if rule.matches(references, vulnerability) {
+			return rule.entry(), true
+		}
+	}
+	return whitelistEntry{}, false
+}
+
+// packageEntryMatches tells if the package name or glob pattern matches the package of the vulnerability and its version is in the version range of the entry
+func packageEntryMatches(name string, entry whitelistEntry, vulnerability vulnerabilityInfo) bool {
+	if matched, _ := path.Match(name, vulnerability.FeatureName); !matched {
+		return false
+	}
+	return entry.Versions == "" || versionInRange(vulnerability.FeatureVersion, entry.Versions)
+}
+
+// whitelistKeyMatches tells if a whitelist key matches the CVE, a key between slashes is a regular expression, otherwise it is a glob pattern
+func whitelistKeyMatches(key string, cve string) bool {
+	if isWhitelistRegexp(key) {
+		matched, _ := regexp.MatchString(key[1:len(key)-1], cve)
+		return matched
+	}
+	matched, _ := path.Match(key, cve)
+	return matched
+}
+
+// matchesAnyKey tells if the CVE matches one of the whitelist keys
+func matchesAnyKey(keys []string, cve string) bool {
+	for _, key := range keys {
+		if whitelistKeyMatches(key, cve) {
+			return true
+		}
+	}
+	return false
+}
+
+func isWhitelistRegexp(key string) bool {
+	return len(key) > 2 && strings.HasPrefix(key, "/") && strings.HasSuffix(key, "/")
+}
+
+// validateWhitelistPatterns checks that the regular expressions and glob patterns of the whitelist are valid
+func validateWhitelistPatterns(whitelist vulnerabilitiesWhitelist) {
+	sections := map[string]map[string]whitelistEntry{"": whitelist.GeneralWhitelist}
+	for image, entries := range whitelist.Images {
+		if _, err := path.Match(image, ""); err != nil {
+			logger.Fatalf("Could not parse whitelist file, invalid image pattern %s: %v", image, err)
+		}
+		sections[image] = entries
+	}
+	for name, entry := range whitelist.Packages {
+		if _, err := path.Match(name, ""); err != nil {
+			logger.Fatalf("Could not parse whitelist file, invalid package pattern %s: %v", name, err)
+		}
+		if !validateVersionRange(entry.Versions) {
+			logger.Fatalf("Could not parse whitelist file, invalid version range %s of package %s", entry.Versions, name)
+		}
+	}
+	for _, rule := range whitelist.Rules {
+		if len(rule.Severities) == 0 && len(rule.Namespaces) == 0 && len(rule.Images) == 0 && len(rule.Vulnerabilities) == 0 && len(rule.Packages) == 0 {
+			logger.Fatalf("Could not parse whitelist file, a rule needs severities, namespaces, images, vulnerabilities or packages")
+		}
+		if !validateVersionRange(rule.Versions) {
+			logger.Fatalf("Could not parse whitelist file, invalid version range %s in %s", rule.Versions, rule)
+		}
+		for _, severity := range rule.Severities {
+			if _, exists := SeverityMap[severity]; !exists {
+				logger.Fatalf("Could not parse whitelist file, invalid CVE severity %s in %s", severity, rule)
+			}
+		}
+		for _, pattern := range append(append(append([]string{}, rule.Namespaces...), rule.Images...), rule.Packages...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				logger.Fatalf("Could not parse whitelist file, invalid pattern %s in %s: %v", pattern, rule, err)
+			}
+		}
+		for _, key := range rule.Vulnerabilities {
+			validateWhitelistKey(key)
+		}
+	}
+	for _, entries := range sections {
+		for key := range entries {
+			validateWhitelistKey(key)
+		}
+	}
+}
+
+// validateWhitelistKey checks that the CVE, glob pattern or regular expression of a whitelist entry is valid
+func validateWhitelistKey(key string) {
+	var err error
+	if isWhitelistRegexp(key) {
+		_, err = regexp.Compile(key[1 : len(key)-1])
+	} else {
+		_, err = path.Match(key, "")
+	}
+	if err != nil {
+		logger.Fatalf("Could not parse whitelist file, invalid pattern %s: %v", key, err)
+	}
+}
+
+// resolveWhitelistLocation resolves the location of an extended whitelist relative to the whitelist extending it
+func resolveWhitelistLocation(whitelistFile string, extends string) string {
+	if strings.HasPrefix(extends, "http://") || strings.HasPrefix(extends, "https://") {
+		return extends
+	}
+	if strings.HasPrefix(whitelistFile, "http://") || strings.HasPrefix(whitelistFile, "https://") {
+		base, err := url.Parse(whitelistFile)
+		reference, referenceErr := url.Parse(extends)
+		if err != nil || referenceErr != nil {
+			logger.Fatalf("Could not parse whitelist file, invalid extends %s", extends)
+		}
+		return base.ResolveReference(reference).String()
+	}
+	if filepath.IsAbs(extends) {
+		return extends
+	}
+	return filepath.Join(filepath.Dir(whitelistFile), extends)
+}
+
+// mergeWhitelists adds the entries of the project whitelist to the base whitelist, project entries override base entries of the same CVE, package or image
+func mergeWhitelists(base vulnerabilitiesWhitelist, project vulnerabilitiesWhitelist) vulnerabilitiesWhitelist {
+	merged := vulnerabilitiesWhitelist{
+		GeneralWhitelist: mergeWhitelistEntries(base.GeneralWhitelist, project.GeneralWhitelist),
+		Packages:         mergeWhitelistEntries(base.Packages, project.Packages),
+		Images:           make(map[string]map[string]whitelistEntry),
+		Rules:            append(append([]whitelistRule{}, project.Rules...), base.Rules...),
+	}
+	for image, entries := range base.Images {
+		merged.Images[image] = mergeWhitelistEntries(nil, entries)
+	}
+	for image, entries := range project.Images {
+		merged.Images[image] = mergeWhitelistEntries(merged.Images[image], entries)
+	}
+	return merged
+}
+
+func mergeWhitelistEntries(base map[string]whitelistEntry, project map[string]whitelistEntry) map[string]whitelistEntry {
+	merged := make(map[string]whitelistEntry, len(base)+len(project))
+	for key, entry := range base {
+		merged[key] = entry
+	}
+	for key, entry := range project {
+		merged[key] = entry
+	}
+	return merged
+}
+
+// reportToWhitelistFile writes the unapproved vulnerabilities to file as a whitelist, so they can be accepted after review
+func reportToWhitelistFile(report *vulnerabilityReport, file string) {
+	writeReportFile(report, file, "whitelist", writeWhitelistYAML)
+}
+
+// writeWhitelistYAML writes the unapproved vulnerabilities as the whitelist of the image repository, with a placeholder description to fill in for every CVE
+func writeWhitelistYAML(w io.Writer, report *vulnerabilityReport) error {
+	unapproved := make(map[string][]string)
+	for _, vulnerability := range report.Vulnerabilities {
+		if vulnerability.Status == "Unapproved" {
+			unapproved[vulnerability.Vulnerability] = append(unapproved[vulnerability.Vulnerability], fmt.Sprintf("%s %s (%s)", vulnerability.FeatureName, vulnerability.FeatureVersion, vulnerability.Severity))
+		}
+	}
+	cves := make([]string, 0, len(unapproved))
+	for cve := range unapproved {
+		cves = append(cves, cve)
+	}
+	sort.Strings(cves)
+
+	buffer := &bytes.Buffer{}
+	fmt.Fprintf(buffer, "# Generated by clair-scanner from the unapproved vulnerabilities of %s, replace every TODO with the reason the CVE is accepted\n", report.Image)
+	if len(cves) == 0 {
+		fmt.Fprintln(buffer, "images: {}")
+	} else {
+		fmt.Fprintln(buffer, "images:")
+		fmt.Fprintf(buffer, "  %s:\n", strconv.Quote(imageReferences(report.Image, nil)[0]))
+		for _, cve := range cves {
+			fmt.Fprintf(buffer, "    %s: %s\n", cve, strconv.Quote("TODO "+strings.Join(unapproved[cve], ", ")))
+		}
+	}
+	_, err := w.Write(buffer.Bytes())
+	return err
+}
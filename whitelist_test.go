@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestWhitelistEntries(t *testing.T) {
+	initializeLogger("")
+	var whitelist vulnerabilitiesWhitelist
+	err := yaml.Unmarshal([]byte(`
+generalwhitelist:
+  CVE-1: plain
+  CVE-2:
+    description: expired
+    expires: 2017-09-01
+  CVE-3:
+    description: expires soon
+    expires: 2017-09-20
+images:
+  ubuntu:
+    CVE-4:
+      description: expires today
+      expires: 2017-09-10
+`), &whitelist)
+	if err != nil {
+		t.Fatalf("Could not unmarshal whitelist: %v", err)
+	}
+	if whitelist.GeneralWhitelist["CVE-1"].Description != "plain" {
+		t.Errorf("Expected a plain description, got %v", whitelist.GeneralWhitelist["CVE-1"])
+	}
+
+	now := time.Date(2017, 9, 10, 12, 0, 0, 0, time.UTC)
+	whitelist = removeExpiredWhitelistEntries(whitelist, now)
+	for cve, expected := range map[string]bool{"CVE-1": true, "CVE-2": false, "CVE-3": true} {
+		if _, exists := whitelist.GeneralWhitelist[cve]; exists != expected {
+			t.Errorf("Expected %s in whitelist to be %t", cve, expected)
+		}
+	}
+	if _, exists := whitelist.Images["ubuntu"]["CVE-4"]; !exists {
+		t.Errorf("Expected CVE-4 to be approved until the end of the day")
+	}
+}
+
+func TestMissingJustifications(t *testing.T) {
+	entries := map[string]whitelistEntry{
+		"CVE-1": {Owner: "security", Reason: "not reachable"},
+		"CVE-2": {Owner: "security"},
+		"CVE-3": {Description: "only a description"},
+	}
+	missing := missingJustifications("general whitelist", entries)
+	if len(missing) != 2 {
+		t.Errorf("Expected CVE-2 and CVE-3 to miss a justification, got %v", missing)
+	}
+	if (whitelistEntry{Description: "description"}).justification() != "description" {
+		t.Errorf("Expected the description to be used as justification")
+	}
+}
+
+func TestUnusedWhitelistEntries(t *testing.T) {
+	whitelist := vulnerabilitiesWhitelist{
+		GeneralWhitelist: map[string]whitelistEntry{"CVE-1": {}, "CVE-2": {}},
+		Images: map[string]map[string]whitelistEntry{
+			"ubuntu": {"CVE-3": {}, "CVE-4": {}},
+			"alpine": {"CVE-5": {}},
+		},
+	}
+	vulnerabilities := []vulnerabilityInfo{{Vulnerability: "CVE-1"}, {Vulnerability: "CVE-3"}}
+	unused := unusedWhitelistEntries(imageReferences("ubuntu:16.04", nil), whitelist, vulnerabilities)
+	if len(unused) != 2 || unused[0] != "CVE-2" || unused[1] != "CVE-4" {
+		t.Errorf("Expected CVE-2 and CVE-4 to be unused, got %v", unused)
+	}
+}
+
+func TestWhitelistPatterns(t *testing.T) {
+	whitelist := vulnerabilitiesWhitelist{
+		GeneralWhitelist: map[string]whitelistEntry{
+			"CVE-2016-*":         {Description: "glob"},
+			`/^CVE-2015-\d{4}$/`: {Description: "regexp"},
+			"CVE-2017-1000":      {Description: "exact"},
+			"CVE-2017-1*":        {Description: "glob 2017"},
+		},
+		Images: map[string]map[string]whitelistEntry{
+			"registry.example.com/*": {"CVE-2018-1": {Description: "registry"}},
+		},
+	}
+	tests := []struct {
+		image    string
+		cve      string
+		expected string
+	}{
+		{"debian:jessie", "CVE-2016-1234", "glob"},
+		{"debian:jessie", "CVE-2015-1234", "regexp"},
+		{"debian:jessie", "CVE-2015-12345", ""},
+		{"debian:jessie", "CVE-2017-1000", "exact"},
+		{"registry.example.com/app:1.0", "CVE-2018-1", "registry"},
+		{"debian:jessie", "CVE-2018-1", ""},
+	}
+	for _, test := range tests {
+		entry, _ := whitelistEntryFor(imageReferences(test.image, nil), vulnerabilityInfo{Vulnerability: test.cve}, whitelist)
+		if entry.Description != test.expected {
+			t.Errorf("Expected %s in %s to be approved by %q, got %q", test.cve, test.image, test.expected, entry.Description)
+		}
+	}
+}
+
+func TestPackageWhitelist(t *testing.T) {
+	whitelist := vulnerabilitiesWhitelist{
+		Packages: map[string]whitelistEntry{
+			"linux-*": {Description: "kernel"},
+			"openssl": {Description: "old openssl", Versions: ">=1.0.1, <1.0.2"},
+		},
+	}
+	tests := []struct {
+		vulnerability vulnerabilityInfo
+		expected      bool
+	}{
+		{vulnerabilityInfo{Vulnerability: "CVE-1", FeatureName: "linux-libc-dev", FeatureVersion: "3.16.43-2"}, true},
+		{vulnerabilityInfo{Vulnerability: "CVE-2", FeatureName: "openssl", FeatureVersion: "1.0.1t-1+deb8u6"}, true},
+		{vulnerabilityInfo{Vulnerability: "CVE-3", FeatureName: "openssl", FeatureVersion: "1.0.2l-1"}, false},
+		{vulnerabilityInfo{Vulnerability: "CVE-4", FeatureName: "zlib", FeatureVersion: "1.2.8"}, false},
+	}
+	for _, test := range tests {
+		if _, exists := whitelistEntryFor(imageReferences("debian:jessie", nil), test.vulnerability, whitelist); exists != test.expected {
+			t.Errorf("Expected %s of %s %s to be whitelisted %t", test.vulnerability.Vulnerability, test.vulnerability.FeatureName, test.vulnerability.FeatureVersion, test.expected)
+		}
+	}
+}
+
+func TestUnapprovedVulnerabilitiesByPackage(t *testing.T) {
+	config := scannerConfig{
+		imageName:          "debian:jessie",
+		whitelistThreshold: "Unknown",
+		whitelist: vulnerabilitiesWhitelist{
+			Packages: map[string]whitelistEntry{"libssl1.0.0": {Reason: "not linked", Owner: "platform"}},
+		},
+	}
+	vulnerabilities := []vulnerabilityInfo{
+		{Vulnerability: "CVE-2017-3735", FeatureName: "openssl", FeatureVersion: "1.0.1t-1", Severity: "Medium"},
+		{Vulnerability: "CVE-2017-3735", FeatureName: "libssl1.0.0", FeatureVersion: "1.0.1t-1", Severity: "Medium"},
+		{Vulnerability: "CVE-2017-3735", FeatureName: "openssl-dev", FeatureVersion: "1.0.1t-1", Severity: "Medium"},
+	}
+	unapproved := checkForUnapprovedVulnerabilities(config, vulnerabilities)
+	if len(unapproved) != 1 || unapproved[0] != "CVE-2017-3735" {
+		t.Errorf("Expected CVE-2017-3735 to be unapproved once, got %v", unapproved)
+	}
+	if vulnerabilities[0].Status != "Unapproved" || vulnerabilities[2].Status != "Unapproved" || vulnerabilities[0].Whitelisted {
+		t.Errorf("Expected CVE-2017-3735 in openssl to be unapproved, got %v", vulnerabilities)
+	}
+	if approved := vulnerabilities[1]; approved.Status != "Approved" || !approved.Whitelisted || approved.WhitelistReason != "not linked" || approved.WhitelistOwner != "platform" {
+		t.Errorf("Expected CVE-2017-3735 in libssl1.0.0 to be approved by the package whitelist, got %v", approved)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.0.2", "1.0.10", -1},
+		{"1.0.1t", "1.0.1", 1},
+		{"2.0", "2.0", 0},
+		{"1.2.8.dfsg-2", "1.2.8", 1},
+	}
+	for _, test := range tests {
+		if result := compareVersions(test.a, test.b); result != test.expected {
+			t.Errorf("Expected comparing %s with %s to be %d, got %d", test.a, test.b, test.expected, result)
+		}
+	}
+	if validateVersionRange("~>1.0") || !validateVersionRange(">= 1.0, <2") {
+		t.Errorf("Expected only valid version ranges to be accepted")
+	}
+}
+
+func TestResolvedDigest(t *testing.T) {
+	tests := []struct {
+		image    string
+		digests  []string
+		expected string
+	}{
+		{"ubuntu@sha256:1", []string{"sha256:id", "sha256:1"}, "sha256:1"},
+		{"ubuntu:16.04", []string{"sha256:id", "sha256:2"}, "sha256:2"},
+		{"3f57d9401f8d", []string{"sha256:id"}, "sha256:id"},
+	}
+	for _, test := range tests {
+		if digest := resolvedDigest(test.image, test.digests); digest != test.expected {
+			t.Errorf("Expected %s to resolve to %s, got %s", test.image, test.expected, digest)
+		}
+	}
+	if !isImageID("sha256:3f57d9401f8d") || !isImageID("3f57d9401f8d") || isImageID("ubuntu:16.04") {
+		t.Errorf("Expected only image IDs to be recognized as image ID")
+	}
+}
+
+func TestImageReferences(t *testing.T) {
+	whitelist := map[string]map[string]whitelistEntry{
+		"registry:777/ubuntu":          {"CVE-1": {Description: "repository"}},
+		"registry:777/ubuntu:16.04":    {"CVE-1": {Description: "tag"}},
+		"registry:777/ubuntu@sha256:1": {"CVE-2": {Description: "digest"}},
+	}
+	tests := []struct {
+		image    string
+		digests  []string
+		cve      string
+		expected string
+	}{
+		{"registry:777/ubuntu:16.04", nil, "CVE-1", "tag"},
+		{"registry:777/ubuntu:17.04", nil, "CVE-1", "repository"},
+		{"registry:777/ubuntu", []string{"sha256:1"}, "CVE-2", "digest"},
+		{"registry:777/ubuntu@sha256:1", nil, "CVE-2", "digest"},
+		{"registry:777/ubuntu:16.04", []string{"sha256:2"}, "CVE-2", ""},
+		{"3f57d9401f8d", []string{"sha256:1"}, "CVE-1", ""},
+	}
+	for _, test := range tests {
+		entry := getImageVulnerabilities(imageReferences(test.image, test.digests), whitelist)[test.cve]
+		if entry.Description != test.expected {
+			t.Errorf("Expected %s in %s to be approved by %q, got %q", test.cve, test.image, test.expected, entry.Description)
+		}
+	}
+}
+
+func TestWhitelistRules(t *testing.T) {
+	whitelist := vulnerabilitiesWhitelist{
+		Rules: []whitelistRule{
+			{Severities: []string{"Negligible", "Unknown"}, Namespaces: []string{"debian:*"}, Description: "low risk"},
+			{Severities: []string{"Low"}, Images: []string{"legacy/*"}, Description: "legacy"},
+		},
+	}
+	tests := []struct {
+		image         string
+		vulnerability vulnerabilityInfo
+		expected      string
+	}{
+		{"debian:jessie", vulnerabilityInfo{Vulnerability: "CVE-1", Severity: "Negligible", Namespace: "debian:8"}, "low risk"},
+		{"debian:jessie", vulnerabilityInfo{Vulnerability: "CVE-2", Severity: "Negligible", Namespace: "alpine:v3.6"}, ""},
+		{"debian:jessie", vulnerabilityInfo{Vulnerability: "CVE-3", Severity: "High", Namespace: "debian:8"}, ""},
+		{"legacy/app:1.0", vulnerabilityInfo{Vulnerability: "CVE-4", Severity: "Low", Namespace: "debian:8"}, "legacy"},
+		{"debian:jessie", vulnerabilityInfo{Vulnerability: "CVE-5", Severity: "Low", Namespace: "debian:8"}, ""},
+	}
+	for _, test := range tests {
+		entry, _ := whitelistEntryFor(imageReferences(test.image, nil), test.vulnerability, whitelist)
+		if entry.Description != test.expected {
+			t.Errorf("Expected %s in %s to be approved by %q, got %q", test.vulnerability.Vulnerability, test.image, test.expected, entry.Description)
+		}
+	}
+}
+
+func TestWhitelistExtends(t *testing.T) {
+	initializeLogger("")
+	dir, err := ioutil.TempDir("", "whitelist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "org"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "org", "base.yaml"), []byte(`
+generalwhitelist:
+  CVE-1: base
+  CVE-2: base
+images:
+  ubuntu:
+    CVE-3: base
+`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "project.yaml"), []byte(`
+extends: org/base.yaml
+generalwhitelist:
+  CVE-2: project
+images:
+  ubuntu:
+    CVE-4: project
+`), 0644)
+
+	whitelist := parseWhitelistFile(filepath.Join(dir, "project.yaml"), "")
+	expected := map[string]string{"CVE-1": "base", "CVE-2": "project"}
+	for cve, description := range expected {
+		if whitelist.GeneralWhitelist[cve].Description != description {
+			t.Errorf("Expected %s to be approved by %s, got %v", cve, description, whitelist.GeneralWhitelist[cve])
+		}
+	}
+	if len(whitelist.Images["ubuntu"]) != 2 {
+		t.Errorf("Expected the image whitelists to be combined, got %v", whitelist.Images["ubuntu"])
+	}
+	if location := resolveWhitelistLocation("https://example.com/teams/app.yaml", "../base.yaml"); location != "https://example.com/base.yaml" {
+		t.Errorf("Expected the base whitelist URL to be resolved, got %s", location)
+	}
+}
+
+func TestWhitelistExtendsHeader(t *testing.T) {
+	initializeLogger("")
+	headers := make(map[string]string)
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers["other"+r.URL.Path] = r.Header.Get("Authorization")
+		fmt.Fprint(w, "generalwhitelist:\n  CVE-1: other\n")
+	}))
+	defer other.Close()
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers[r.URL.Path] = r.Header.Get("Authorization")
+		if r.URL.Path == "/project.yaml" {
+			fmt.Fprint(w, "extends: teams/team.yaml\ngeneralwhitelist:\n  CVE-3: project\n")
+			return
+		}
+		fmt.Fprintf(w, "extends: %s/base.yaml\ngeneralwhitelist:\n  CVE-2: team\n", other.URL)
+	}))
+	defer origin.Close()
+
+	whitelist := parseWhitelistFile(origin.URL+"/project.yaml", "Authorization: Bearer secret")
+	if len(whitelist.GeneralWhitelist) != 3 {
+		t.Errorf("Expected the entries of all whitelists, got %v", whitelist.GeneralWhitelist)
+	}
+	if headers["/project.yaml"] != "Bearer secret" || headers["/teams/team.yaml"] != "Bearer secret" {
+		t.Errorf("Expected the header to be sent to the host of the whitelist, got %v", headers)
+	}
+	if authorization, downloaded := headers["other/base.yaml"]; !downloaded || authorization != "" {
+		t.Errorf("Expected the header not to be sent to another host, got %v", headers)
+	}
+	if !sameOrigin("https://example.com/a.yaml", "HTTPS://Example.com/b/c.yaml") || sameOrigin("https://example.com/a.yaml", "http://example.com/a.yaml") || sameOrigin("https://example.com/a.yaml", "https://example.com:8443/a.yaml") {
+		t.Errorf("Expected URLs to have the same origin only with the same scheme and host")
+	}
+}
+
+func TestWriteWhitelistYAML(t *testing.T) {
+	report := &vulnerabilityReport{
+		Image: "registry:777/app:1.0",
+		Vulnerabilities: []vulnerabilityInfo{
+			{Vulnerability: "CVE-2", FeatureName: "openssl", FeatureVersion: "1.0.1t", Severity: "High", Status: "Unapproved"},
+			{Vulnerability: "CVE-2", FeatureName: "libssl", FeatureVersion: "1.0.1t", Severity: "High", Status: "Unapproved"},
+			{Vulnerability: "CVE-1", FeatureName: "zlib", FeatureVersion: "1.2.8", Severity: "Low", Status: "Approved"},
+		},
+	}
+	var buffer bytes.Buffer
+	if err := writeWhitelistYAML(&buffer, report); err != nil {
+		t.Fatalf("Could not write whitelist: %v", err)
+	}
+
+	var whitelist vulnerabilitiesWhitelist
+	if err := yaml.Unmarshal(buffer.Bytes(), &whitelist); err != nil {
+		t.Fatalf("Generated whitelist is not valid: %v\n%s", err, buffer.String())
+	}
+	entries := whitelist.Images["registry:777/app"]
+	if len(entries) != 1 || entries["CVE-2"].Description != "TODO openssl 1.0.1t (High), libssl 1.0.1t (High)" {
+		t.Errorf("Expected only CVE-2 with a placeholder description, got %v", entries)
+	}
+}